@@ -1,7 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"glm-tool/config"
+	"glm-tool/internal/cache"
 	"glm-tool/internal/handler"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +20,12 @@ import (
 func main() {
 	config.LoadConfig()
 
+	// 缓存后端不可用时回退到进程内缓存（回退失败则不使用缓存），不影响服务启动
+	if err := cache.Init(); err != nil {
+		log.Warnf("初始化图片缓存失败: %v", err)
+	}
+	defer cache.Close()
+
 	r := gin.Default()
 
 	h := handler.NewHandler()
@@ -23,10 +38,62 @@ func main() {
 		v1.GET("/models", h.ListModels)
 		v1.POST("/messages", h.AnthropicMessages)
 		v1.POST("/messages/count_tokens", h.AnthropicCountTokens)
+		v1.POST("/responses", h.Responses)
+		v1.GET("/responses/:id", h.GetResponse)
+		v1.DELETE("/responses/:id", h.DeleteResponse)
+	}
+
+	// 管理接口：仅在配置了 ADMIN_TOKEN 时开放
+	if config.AppConfig.AdminToken != "" {
+		admin := r.Group("/admin", handler.AdminAuth())
+		{
+			admin.GET("/cache/stats", h.CacheStats)
+			admin.GET("/cache/entries", h.ListCacheEntries)
+			admin.DELETE("/cache/entries", h.PurgeCache)
+			admin.GET("/cache/entries/:key", h.GetCacheEntry)
+			admin.DELETE("/cache/entries/:key", h.DeleteCacheEntry)
+		}
+	}
+
+	// 所有请求的 context 都派生自 baseCtx，关闭超时后取消它以中止仍在进行的上游请求
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	srv := &http.Server{
+		Addr:        ":" + config.AppConfig.Port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
-	log.Infof("服务启动在端口: %s", config.AppConfig.Port)
-	if err := r.Run(":" + config.AppConfig.Port); err != nil {
-		log.Errorf("服务启动失败: %v", err)
+	go func() {
+		log.Infof("服务启动在端口: %s", config.AppConfig.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("服务启动失败: %v", err)
+			cancelRequests()
+		}
+	}()
+
+	// 等待退出信号（或服务启动失败）
+	signalCtx, stop := signal.NotifyContext(baseCtx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+	stop()
+
+	log.Infof("正在关闭服务...")
+	timeout := time.Duration(config.AppConfig.ShutdownTimeoutSeconds) * time.Second
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+
+	// 超时后取消所有进行中的请求，避免长时间的流式响应阻塞退出
+	go func() {
+		<-shutdownCtx.Done()
+		cancelRequests()
+	}()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Warnf("等待请求完成超时，已取消剩余请求: %v", err)
+		cancelRequests()
+		srv.Close()
 	}
+	log.Infof("服务已关闭")
 }
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/gophertool/tool/log"
@@ -12,11 +14,127 @@ type Config struct {
 	Port            string
 	TargetAPIURL    string
 	AnthropicAPIURL string
+	// TargetAPIURLs / AnthropicAPIURLs 上游节点列表（url 或 url|weight），未配置时使用单个地址
+	TargetAPIURLs    []string
+	AnthropicAPIURLs []string
 	LogLevel        string
 	Debug           bool
 	DebugLogFile    string
 	CachePath       string
 	CacheTTLHours   int
+
+	// AdminToken 管理接口（/admin）的访问令牌，为空时不开放管理接口
+	AdminToken string
+
+	// CacheBackend 识别结果缓存后端：buntdb、memory 或 redis
+	CacheBackend string
+	// CacheMemoryMaxItems / CacheMemoryMaxMB 内存缓存的条目数和容量上限，0 表示不限制
+	CacheMemoryMaxItems int
+	CacheMemoryMaxMB    int
+	// CacheRedisAddr 等 Redis 缓存连接配置，CacheRedisKeyPrefix 为键前缀
+	CacheRedisAddr      string
+	CacheRedisPassword  string
+	CacheRedisDB        int
+	CacheRedisKeyPrefix string
+
+	// ChatCompletionsUpstream /v1/chat/completions 的默认上游：openai 或 anthropic
+	ChatCompletionsUpstream string
+	// ChatAnthropicModels 通过 Anthropic 上游处理 OpenAI 请求的模型名模式（支持通配符）
+	ChatAnthropicModels []string
+	// MessagesUpstream /v1/messages 的默认上游：anthropic 或 openai
+	MessagesUpstream string
+	// MessagesOpenAIModels 通过 OpenAI 上游处理 Anthropic 请求的模型名模式（支持通配符）
+	MessagesOpenAIModels []string
+	// ResponsesStoreMaxEntries Responses API 保存的响应数量上限（用于 previous_response_id）
+	ResponsesStoreMaxEntries int
+	// ResponsesStoreTTLHours Responses API 保存的响应有效期（小时）
+	ResponsesStoreTTLHours int
+	// UpstreamMaxRetries 上游请求失败（连接错误、429、5xx）时的最大重试次数
+	UpstreamMaxRetries int
+	// UpstreamRetryBaseMs 首次重试前的基础等待时间（毫秒），之后指数增长
+	UpstreamRetryBaseMs int
+	// UpstreamRetryMaxMs 单次重试等待时间上限（毫秒）
+	UpstreamRetryMaxMs int
+	// UpstreamRetryDeadlineSeconds 包含重试在内的总时长上限（秒）
+	UpstreamRetryDeadlineSeconds int
+	// UpstreamBalance 上游负载均衡策略：round_robin（加权轮询）或 least_inflight（最少在途）
+	UpstreamBalance string
+	// UpstreamEjectFailures 节点连续失败多少次后被摘除，0 表示不摘除
+	UpstreamEjectFailures int
+	// UpstreamEjectSeconds 节点被摘除的时长（秒）
+	UpstreamEjectSeconds int
+	// UpstreamHealthCheckSeconds 主动探测间隔（秒），0 表示不探测；只有一个节点的池不探测
+	UpstreamHealthCheckSeconds int
+	// AnthropicDefaultMaxTokens OpenAI 请求未指定 max_tokens 时转换使用的默认值
+	AnthropicDefaultMaxTokens int
+	// ImageFetchEnabled 是否下载 http(s) 图片地址进行识别
+	ImageFetchEnabled bool
+	// ImageFetchMaxBytes 远程图片的最大字节数
+	ImageFetchMaxBytes int64
+	// ImageFetchTimeoutSeconds 远程图片下载超时（秒）
+	ImageFetchTimeoutSeconds int
+	// ImageFetchAllowedHosts 允许下载的域名模式（支持通配符），为空时不限制
+	ImageFetchAllowedHosts []string
+	// ImageFetchAllowPrivate 是否允许下载私有网络和回环地址上的图片
+	ImageFetchAllowPrivate bool
+	// VisionBaseURL 视觉模型的 OpenAI 兼容接口地址
+	VisionBaseURL string
+	// VisionModel 视觉模型名称
+	VisionModel string
+	// VisionTemperature / VisionTopP / VisionMaxTokens 视觉模型采样参数
+	VisionTemperature float64
+	VisionTopP        float64
+	VisionMaxTokens   int
+	// VisionThinking 视觉模型是否开启深度思考
+	VisionThinking bool
+	// VisionTimeoutSeconds 单次图片识别超时（秒）
+	VisionTimeoutSeconds int
+	// VisionAPIKey 视觉模型专用 API Key，为空时使用请求中的 Key
+	VisionAPIKey string
+	// VisionProfile 默认的识别提示词模板（general/ocr/ui/diagram/chart/code/auto）
+	VisionProfile string
+	// VisionClassifyModel 自动选择提示词模板时分类使用的模型，为空时使用 VisionModel
+	VisionClassifyModel string
+	// VisionMaxConcurrency 全局同时进行的视觉模型调用上限，0 表示不限制
+	VisionMaxConcurrency int
+	// VisionQueueTimeoutSeconds 等待识别名额的超时时间（秒），0 表示不限制
+	VisionQueueTimeoutSeconds int
+	// VisionNativeModels 支持图片输入的目标模型名模式（支持通配符），这些模型的请求不做图片识别（PDF 文档仍然展开）
+	VisionNativeModels []string
+	// ImageFailurePolicy 图片识别失败时的处理策略（keep/placeholder/drop/fail）
+	ImageFailurePolicy string
+	// ImageContextPrompt 是否根据图片周围的文字构建有针对性的识别提示词
+	ImageContextPrompt bool
+	// ImageContextIncludeSystem 上下文提示词是否包含系统提示词
+	ImageContextIncludeSystem bool
+	// ImageContextMaxChars 上下文（用户文字、系统提示词）各自的最大字符数
+	ImageContextMaxChars int
+	// PDFEnabled 是否将 PDF 文档展开为逐页文本（无文字层的页面提取图片进行识别）
+	PDFEnabled bool
+	// PDFMaxPages 每个 PDF 最多处理的页数，0 表示不限制
+	PDFMaxPages int
+	// PDFMinTextChars 文字少于该字符数的页面视为扫描页，提取其中的图片进行识别
+	PDFMinTextChars int
+	// PDFMaxImagesPerPage 扫描页最多识别的图片数量，0 表示不限制
+	PDFMaxImagesPerPage int
+	// ImageAnimationMaxFrames 动画图片（GIF/APNG/WebP）最多识别的帧数，0 或 1 表示只识别第一帧
+	ImageAnimationMaxFrames int
+	// ImageAnimationSampling 动画抽帧方式（keyframes/interval）
+	ImageAnimationSampling string
+	// ImageAnimationFrameInterval interval 抽帧间隔，0 表示在整个动画中均匀抽取
+	ImageAnimationFrameInterval int
+	// ImageMaxDimension 发送给视觉模型前图片最长边上限（像素），0 表示不限制
+	ImageMaxDimension int
+	// ImageMaxPixels 发送给视觉模型前图片总像素上限，0 表示不限制
+	ImageMaxPixels int
+	// ImageOutputFormat 预处理输出格式：空（保持 JPEG，其他转 PNG）或 jpeg
+	ImageOutputFormat string
+	// ImageJPEGQuality JPEG 编码质量（1-100）
+	ImageJPEGQuality int
+	// ImageStripMetadata 是否重新编码图片以去除 EXIF 等元数据
+	ImageStripMetadata bool
+	// ShutdownTimeoutSeconds 服务关闭时等待进行中请求完成的时间（秒），超时后取消所有上游请求
+	ShutdownTimeoutSeconds int
 }
 
 var AppConfig *Config
@@ -36,13 +154,94 @@ func LoadConfig() {
 		DebugLogFile:    getEnv("DEBUG_LOG_FILE", "debug.json"),
 		CachePath:       getEnv("CACHE_PATH", "image_cache.db"),
 		CacheTTLHours:   getIntEnv("CACHE_TTL_HOURS", 24),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		CacheBackend:        strings.ToLower(getEnv("CACHE_BACKEND", "buntdb")),
+		CacheMemoryMaxItems: getIntEnv("CACHE_MEMORY_MAX_ITEMS", 10000),
+		CacheMemoryMaxMB:    getIntEnv("CACHE_MEMORY_MAX_MB", 256),
+		CacheRedisAddr:      getEnv("CACHE_REDIS_ADDR", "localhost:6379"),
+		CacheRedisPassword:  getEnv("CACHE_REDIS_PASSWORD", ""),
+		CacheRedisDB:        getIntEnv("CACHE_REDIS_DB", 0),
+		CacheRedisKeyPrefix: getEnv("CACHE_REDIS_KEY_PREFIX", "glm-tool:image:"),
+
+		ChatCompletionsUpstream:   getEnv("CHAT_COMPLETIONS_UPSTREAM", "openai"),
+		ChatAnthropicModels:       getListEnv("CHAT_ANTHROPIC_MODELS"),
+		MessagesUpstream:          getEnv("MESSAGES_UPSTREAM", "anthropic"),
+		MessagesOpenAIModels:      getListEnv("MESSAGES_OPENAI_MODELS"),
+		AnthropicDefaultMaxTokens: getIntEnv("ANTHROPIC_DEFAULT_MAX_TOKENS", 8192),
+		ResponsesStoreMaxEntries:  getIntEnv("RESPONSES_STORE_MAX_ENTRIES", 1000),
+		ResponsesStoreTTLHours:    getIntEnv("RESPONSES_STORE_TTL_HOURS", 24),
+
+		UpstreamMaxRetries:           getIntEnv("UPSTREAM_MAX_RETRIES", 2),
+		UpstreamRetryBaseMs:          getIntEnv("UPSTREAM_RETRY_BASE_MS", 500),
+		UpstreamRetryMaxMs:           getIntEnv("UPSTREAM_RETRY_MAX_MS", 8000),
+		UpstreamRetryDeadlineSeconds: getIntEnv("UPSTREAM_RETRY_DEADLINE_SECONDS", 60),
+
+		UpstreamBalance:            getEnv("UPSTREAM_BALANCE", "round_robin"),
+		UpstreamEjectFailures:      getIntEnv("UPSTREAM_EJECT_FAILURES", 3),
+		UpstreamEjectSeconds:       getIntEnv("UPSTREAM_EJECT_SECONDS", 30),
+		UpstreamHealthCheckSeconds: getIntEnv("UPSTREAM_HEALTH_CHECK_SECONDS", 30),
+
+		ImageFetchEnabled:        getBoolEnv("IMAGE_FETCH_ENABLED", true),
+		ImageFetchMaxBytes:       int64(getIntEnv("IMAGE_FETCH_MAX_BYTES", 10*1024*1024)),
+		ImageFetchTimeoutSeconds: getIntEnv("IMAGE_FETCH_TIMEOUT_SECONDS", 10),
+		ImageFetchAllowedHosts:   getListEnv("IMAGE_FETCH_ALLOWED_HOSTS"),
+		ImageFetchAllowPrivate:   getBoolEnv("IMAGE_FETCH_ALLOW_PRIVATE", false),
+
+		VisionBaseURL:        getEnv("VISION_BASE_URL", "https://open.bigmodel.cn/api/paas/v4"),
+		VisionModel:          getEnv("VISION_MODEL", "glm-4.6v"),
+		VisionTemperature:    getFloatEnv("VISION_TEMPERATURE", 0.8),
+		VisionTopP:           getFloatEnv("VISION_TOP_P", 0.6),
+		VisionMaxTokens:      getIntEnv("VISION_MAX_TOKENS", 16384),
+		VisionThinking:       getBoolEnv("VISION_THINKING", true),
+		VisionTimeoutSeconds: getIntEnv("VISION_TIMEOUT_SECONDS", 300),
+		VisionAPIKey:         getEnv("VISION_API_KEY", ""),
+		VisionProfile:        strings.ToLower(getEnv("VISION_PROFILE", "general")),
+		VisionClassifyModel:  getEnv("VISION_CLASSIFY_MODEL", ""),
+
+		VisionMaxConcurrency:      getIntEnv("VISION_MAX_CONCURRENCY", 8),
+		VisionQueueTimeoutSeconds: getIntEnv("VISION_QUEUE_TIMEOUT_SECONDS", 60),
+
+		VisionNativeModels: getListEnvDefault("VISION_NATIVE_MODELS", "glm-4.6v*,glm-4.5v*,glm-4v*"),
+		ImageFailurePolicy: strings.ToLower(getEnv("IMAGE_FAILURE_POLICY", "keep")),
+
+		ImageContextPrompt:        getBoolEnv("IMAGE_CONTEXT_PROMPT", false),
+		ImageContextIncludeSystem: getBoolEnv("IMAGE_CONTEXT_INCLUDE_SYSTEM", false),
+		ImageContextMaxChars:      getIntEnv("IMAGE_CONTEXT_MAX_CHARS", 2000),
+
+		PDFEnabled:          getBoolEnv("PDF_ENABLED", true),
+		PDFMaxPages:         getIntEnv("PDF_MAX_PAGES", 50),
+		PDFMinTextChars:     getIntEnv("PDF_MIN_TEXT_CHARS", 10),
+		PDFMaxImagesPerPage: getIntEnv("PDF_MAX_IMAGES_PER_PAGE", 4),
+
+		ImageAnimationMaxFrames:     getIntEnv("IMAGE_ANIMATION_MAX_FRAMES", 6),
+		ImageAnimationSampling:      strings.ToLower(getEnv("IMAGE_ANIMATION_SAMPLING", "keyframes")),
+		ImageAnimationFrameInterval: getIntEnv("IMAGE_ANIMATION_FRAME_INTERVAL", 0),
+
+		ImageMaxDimension:  getIntEnv("IMAGE_MAX_DIMENSION", 2048),
+		ImageMaxPixels:     getIntEnv("IMAGE_MAX_PIXELS", 0),
+		ImageOutputFormat:  strings.ToLower(getEnv("IMAGE_OUTPUT_FORMAT", "")),
+		ImageJPEGQuality:   getIntEnv("IMAGE_JPEG_QUALITY", 85),
+		ImageStripMetadata: getBoolEnv("IMAGE_STRIP_METADATA", false),
+
+		ShutdownTimeoutSeconds: getIntEnv("SHUTDOWN_TIMEOUT_SECONDS", 10),
+	}
+
+	AppConfig.TargetAPIURLs = getListEnv("TARGET_API_URLS")
+	if len(AppConfig.TargetAPIURLs) == 0 {
+		AppConfig.TargetAPIURLs = []string{AppConfig.TargetAPIURL}
+	}
+	AppConfig.AnthropicAPIURLs = getListEnv("ANTHROPIC_API_URLS")
+	if len(AppConfig.AnthropicAPIURLs) == 0 {
+		AppConfig.AnthropicAPIURLs = []string{AppConfig.AnthropicAPIURL}
 	}
 
 	// 设置日志级别
 	setLogLevel(AppConfig.LogLevel)
 
-	log.Infof("配置加载完成: Port=%s, TargetAPIURL=%s, AnthropicAPIURL=%s, Debug=%v, LogLevel=%s",
-		AppConfig.Port, AppConfig.TargetAPIURL, AppConfig.AnthropicAPIURL, AppConfig.Debug, AppConfig.LogLevel)
+	log.Infof("配置加载完成: Port=%s, TargetAPIURLs=%v, AnthropicAPIURLs=%v, Debug=%v, LogLevel=%s",
+		AppConfig.Port, AppConfig.TargetAPIURLs, AppConfig.AnthropicAPIURLs, AppConfig.Debug, AppConfig.LogLevel)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -70,6 +269,51 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv 读取逗号分隔的列表，忽略空项
+func getListEnv(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+// getListEnvDefault 读取逗号分隔的列表，未设置时使用默认值（设置为空表示空列表）
+func getListEnvDefault(key, defaultValue string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		value = defaultValue
+	}
+	return splitList(value)
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MatchModel 判断模型名是否匹配任一模式（支持 * 和 ? 通配符，不区分大小写）
+func MatchModel(patterns []string, model string) bool {
+	model = strings.ToLower(model)
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), model); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
 // setLogLevel 根据字符串设置日志级别
 func setLogLevel(level string) {
 	switch level {
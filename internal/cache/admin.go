@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotInitialized 缓存未初始化
+var ErrNotInitialized = errors.New("图片缓存未初始化")
+
+// adminScanCount 管理操作遍历缓存时每批的条目数
+const adminScanCount = 200
+
+// Entry 缓存条目概要
+type Entry struct {
+	Key    string
+	Hash   string        // 图片哈希（键中 ":" 之前的部分）
+	Size   int           // 识别结果的字节数
+	TTL    time.Duration // 剩余有效期，0 表示不过期
+	Hits   int64         // 命中次数
+	Result Result        // 格式无法识别的条目 Result.Version 为 0，Description 为原始值
+
+	stored int64 // 条目占用的字节数（键和值），删除时用于更新统计
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Backend string
+	Entries int
+	Bytes   int64 // 识别结果条目占用的字节数（键和值）
+	Hits    int64 // 进程启动以来的命中次数
+	Misses  int64 // 进程启动以来的未命中次数
+}
+
+// ListEntries 从 cursor（第一页为空）开始列出最多 limit 条识别结果，返回下一页的游标（为空表示没有更多）
+// 按键的顺序返回（Redis 后端无固定顺序，每页的条目数可能略多于 limit）
+func ListEntries(cursor string, limit int) ([]Entry, string, error) {
+	cache := getCache()
+	if cache == nil {
+		return nil, "", ErrNotInitialized
+	}
+
+	var entries []Entry
+	for {
+		items, next, err := cache.Scan("", cursor, limit-len(entries))
+		if err != nil {
+			return nil, "", err
+		}
+		for _, item := range items {
+			if isResultKey(item.Key) {
+				entries = append(entries, newEntry(item))
+			}
+		}
+		cursor = next
+		if cursor == "" || len(entries) >= limit {
+			break
+		}
+	}
+	if err := loadHits(cache, entries); err != nil {
+		return nil, "", err
+	}
+	return entries, cursor, nil
+}
+
+// GetEntry 读取单个识别结果
+func GetEntry(key string) (Entry, error) {
+	cache := getCache()
+	if cache == nil {
+		return Entry{}, ErrNotInitialized
+	}
+	if !isResultKey(key) {
+		return Entry{}, ErrNotFound
+	}
+
+	value, err := cache.Get(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	ttl, _ := cache.TTL(key)
+	entries := []Entry{newEntry(Item{Key: key, Value: value, TTL: ttl})}
+	if err := loadHits(cache, entries); err != nil {
+		return Entry{}, err
+	}
+	return entries[0], nil
+}
+
+// DeleteEntries 删除图片哈希（或完整的键）对应的所有识别结果，返回删除的条目数
+func DeleteEntries(hashOrKey string) (int, error) {
+	cache := getCache()
+	if cache == nil {
+		return 0, ErrNotInitialized
+	}
+
+	// 只遍历该图片的条目：完整的键本身就是前缀，图片哈希加上分隔符
+	prefix := hashOrKey
+	if !strings.Contains(hashOrKey, ":") {
+		prefix += ":"
+	}
+	return deleteMatching(cache, prefix, func(entry Entry) bool {
+		return entry.Key == hashOrKey || entry.Hash == hashOrKey
+	})
+}
+
+// PurgeEntries 删除创建时间早于 olderThan 之前的识别结果，olderThan 为 0 时删除全部
+// 创建时间未知的条目（从没有元数据的旧格式转换而来）按最早的条目处理
+func PurgeEntries(olderThan time.Duration) (int, error) {
+	cache := getCache()
+	if cache == nil {
+		return 0, ErrNotInitialized
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	return deleteMatching(cache, "", func(entry Entry) bool {
+		return olderThan <= 0 || entry.Result.Created.Before(cutoff)
+	})
+}
+
+// GetStats 返回缓存条目数量和命中统计，条目数量来自统计计数器，不遍历缓存
+func GetStats() (Stats, error) {
+	cache := getCache()
+	if cache == nil {
+		return Stats{}, ErrNotInitialized
+	}
+
+	pruneCounters(cache, time.Now())
+	entries, bytes, err := readStats(cache)
+	if err != nil {
+		return Stats{}, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return Stats{
+		Backend: backend,
+		Entries: entries,
+		Bytes:   bytes,
+		Hits:    hits.Load(),
+		Misses:  misses.Load(),
+	}, nil
+}
+
+// loadHits 按过期时间分组批量读取条目的命中次数
+func loadHits(cache Cache, entries []Entry) error {
+	for bucket, group := range groupByBucket(entries) {
+		keys := make([]string, len(group))
+		for i, entry := range group {
+			keys[i] = entry.Key
+		}
+		counters, err := cache.Counters(hitsGroup(bucket), keys...)
+		if err != nil {
+			return err
+		}
+		for _, entry := range group {
+			entry.Hits = counters[entry.Key]
+		}
+	}
+	return nil
+}
+
+// groupByBucket 按统计分组（过期时间）分组条目
+func groupByBucket(entries []Entry) map[int64][]*Entry {
+	now := time.Now()
+	groups := make(map[int64][]*Entry)
+	for i := range entries {
+		bucket := statsBucket(entries[i].TTL, now)
+		groups[bucket] = append(groups[bucket], &entries[i])
+	}
+	return groups
+}
+
+// deleteMatching 分批遍历以 prefix 开头的识别结果，删除 match 返回 true 的条目，返回删除的条目数
+func deleteMatching(cache Cache, prefix string, match func(Entry) bool) (int, error) {
+	deleted := 0
+	cursor := ""
+	for {
+		items, next, err := cache.Scan(prefix, cursor, adminScanCount)
+		if err != nil {
+			return deleted, err
+		}
+		var matched []Entry
+		for _, item := range items {
+			if !isResultKey(item.Key) {
+				continue
+			}
+			if entry := newEntry(item); match(entry) {
+				matched = append(matched, entry)
+			}
+		}
+		if err := deleteEntries(cache, matched); err != nil {
+			return deleted, err
+		}
+		deleted += len(matched)
+		if next == "" {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// deleteEntries 批量删除识别结果及其命中次数计数器，并从统计中扣除
+func deleteEntries(cache Cache, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	if err := cache.Delete(keys...); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		countEntry(cache, entry.stored, entry.TTL, -1)
+	}
+	for bucket, group := range groupByBucket(entries) {
+		keys := make([]string, len(group))
+		for i, entry := range group {
+			keys[i] = entry.Key
+		}
+		if err := cache.DeleteCounters(hitsGroup(bucket), keys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEntry 根据缓存条目生成条目概要
+func newEntry(item Item) Entry {
+	hash, _, _ := strings.Cut(item.Key, ":")
+	result, ok := decodeResult(item.Value)
+	if !ok {
+		result = Result{Description: item.Value}
+	}
+	return Entry{
+		Key:    item.Key,
+		Hash:   hash,
+		Size:   len(result.Description),
+		TTL:    item.TTL,
+		Result: result,
+		stored: entrySize(item.Key, item.Value),
+	}
+}
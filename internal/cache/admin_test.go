@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"glm-tool/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// initTestCache 使用指定后端初始化全局缓存
+func initTestCache(t *testing.T, cfg config.Config) {
+	t.Helper()
+	cfg.CacheTTLHours = 24
+	cfg.VisionModel = "glm-4.5v"
+	useConfig(t, cfg)
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkStats 检查统计计数器中的条目数
+func checkStats(t *testing.T, want int) {
+	t.Helper()
+	stats, err := GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != want {
+		t.Errorf("统计条目数 = %d，期望 %d", stats.Entries, want)
+	}
+	if (want == 0) != (stats.Bytes == 0) {
+		t.Errorf("统计字节数 = %d，条目数 %d", stats.Bytes, want)
+	}
+}
+
+func TestAdminEntries(t *testing.T) {
+	server := miniredis.RunT(t)
+	backends := map[string]config.Config{
+		BackendMemory: {CacheBackend: BackendMemory},
+		BackendBuntDB: {CacheBackend: BackendBuntDB, CachePath: filepath.Join(t.TempDir(), "cache.db")},
+		BackendRedis:  {CacheBackend: BackendRedis, CacheRedisAddr: server.Addr(), CacheRedisKeyPrefix: "glm:"},
+	}
+	for name, cfg := range backends {
+		t.Run(name, func(t *testing.T) {
+			initTestCache(t, cfg)
+			for i := 0; i < 5; i++ {
+				hash := ComputeHash(fmt.Sprint(i))
+				SetImageResult(ResultKey(hash, "glm-4.5v", ""), Result{Description: "image", Model: "glm-4.5v"})
+				SetImageResult(ResultKey(hash, "glm-4.5v", "ocr"), Result{Description: "text", Model: "glm-4.5v"})
+			}
+			// 覆盖写入不重复计数
+			first := ResultKey(ComputeHash("0"), "glm-4.5v", "")
+			SetImageResult(first, Result{Description: "image again"})
+			checkStats(t, 10)
+
+			// 分页遍历全部条目，统计计数器不出现在列表中
+			seen := make(map[string]bool)
+			cursor := ""
+			for {
+				entries, next, err := ListEntries(cursor, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, entry := range entries {
+					if !isResultKey(entry.Key) || entry.Result.Version != SchemaVersion {
+						t.Errorf("列表中出现非识别结果的键 %s", entry.Key)
+					}
+					if entry.TTL <= 0 {
+						t.Errorf("%s 缺少剩余有效期", entry.Key)
+					}
+					seen[entry.Key] = true
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if len(seen) != 10 {
+				t.Errorf("分页遍历到 %d 个条目，期望 10 个", len(seen))
+			}
+
+			deleted, err := DeleteEntries(ComputeHash("0"))
+			if err != nil || deleted != 2 {
+				t.Errorf("按图片哈希删除 = %d, %v，期望 2", deleted, err)
+			}
+			deleted, err = DeleteEntries(ResultKey(ComputeHash("1"), "glm-4.5v", "ocr"))
+			if err != nil || deleted != 1 {
+				t.Errorf("按完整的键删除 = %d, %v，期望 1", deleted, err)
+			}
+			checkStats(t, 7)
+
+			if deleted, err := PurgeEntries(time.Hour); err != nil || deleted != 0 {
+				t.Errorf("清理一小时前的条目 = %d, %v，期望 0", deleted, err)
+			}
+			if deleted, err := PurgeEntries(0); err != nil || deleted != 7 {
+				t.Errorf("清空 = %d, %v，期望 7", deleted, err)
+			}
+			checkStats(t, 0)
+		})
+	}
+}
+
+func TestGetEntryRejectsInternalKeys(t *testing.T) {
+	initTestCache(t, config.Config{CacheBackend: BackendBuntDB, CachePath: filepath.Join(t.TempDir(), "cache.db")})
+	key := ResultKey(ComputeHash("image"), "glm-4.5v", "")
+	SetImageResult(key, Result{Description: "cat"})
+
+	if entry, err := GetEntry(key); err != nil || entry.Result.Description != "cat" || entry.TTL <= 0 {
+		t.Errorf("GetEntry = %+v, %v", entry, err)
+	}
+	for _, internal := range []string{schemaVersionKey, counterKey(statsGroup, statsCounter(statsEntries, 0))} {
+		if _, err := GetEntry(internal); err == nil {
+			t.Errorf("内部数据 %s 不应作为条目返回", internal)
+		}
+	}
+}
+
+func TestMemoryEvictionUpdatesStats(t *testing.T) {
+	// 统计计数器不占用条目容量，也不会被淘汰
+	initTestCache(t, config.Config{CacheBackend: BackendMemory, CacheMemoryMaxItems: 6})
+	for i := 0; i < 10; i++ {
+		key := ResultKey(ComputeHash(fmt.Sprint(i)), "glm-4.5v", "")
+		SetImageResult(key, Result{Description: "image"})
+		GetImageResult(key)
+	}
+
+	var listed []Entry
+	cursor := ""
+	for {
+		entries, next, err := ListEntries(cursor, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, entries...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	checkStats(t, 6)
+	if len(listed) != 6 {
+		t.Errorf("保留 %d 条识别结果，期望容量上限 6 条", len(listed))
+	}
+	for _, entry := range listed {
+		if entry.Hits != 1 {
+			t.Errorf("%s 的命中次数 = %d，期望 1", entry.Key, entry.Hits)
+		}
+	}
+
+	// 被淘汰的条目的命中次数计数器一并删除
+	hits, _ := getCache().Counters(hitsGroup(statsBucket(24*time.Hour, time.Now())))
+	if len(hits) != 6 {
+		t.Errorf("剩余 %d 个命中次数计数器，期望 6 个", len(hits))
+	}
+}
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/buntdb"
+)
+
+// BuntDBCache 基于本地 buntdb 文件的缓存，重启后仍可用
+type BuntDBCache struct {
+	db *buntdb.DB
+}
+
+// counterKey 计数器保存为不过期的键：counters:组|名称
+func counterKey(group, name string) string {
+	return counterKeyPrefix + group + "|" + name
+}
+
+// isCounterKey 是否为计数器的键，Scan 和 Range 跳过这些键
+func isCounterKey(key string) bool {
+	return strings.HasPrefix(key, counterKeyPrefix)
+}
+
+// NewBuntDB 打开（或创建）buntdb 缓存文件
+func NewBuntDB(path string) (*BuntDBCache, error) {
+	db, err := buntdb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开缓存文件 %s 失败: %w", path, err)
+	}
+	return &BuntDBCache{db: db}, nil
+}
+
+// Get 读取 key 的值
+func (b *BuntDBCache) Get(key string) (string, error) {
+	var value string
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		value, err = tx.Get(key)
+		return err
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// GetMulti 在同一个只读事务中批量读取
+func (b *BuntDBCache) GetMulti(keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		for _, key := range keys {
+			value, err := tx.Get(key)
+			if errors.Is(err, buntdb.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			values[key] = value
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Set 写入 key-value
+func (b *BuntDBCache) Set(key string, value string, ttl time.Duration) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(key, value, setOptions(ttl))
+		return err
+	})
+}
+
+// Delete 在同一个事务中删除 key
+func (b *BuntDBCache) Delete(keys ...string) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		for _, key := range keys {
+			if _, err := tx.Delete(key); err != nil && !errors.Is(err, buntdb.ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TTL 返回 key 的剩余有效期
+func (b *BuntDBCache) TTL(key string) (time.Duration, error) {
+	var ttl time.Duration
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		ttl, err = tx.TTL(key)
+		return err
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return 0, ErrNotFound
+	}
+	if ttl < 0 {
+		// buntdb 对不过期的键返回 -1
+		ttl = 0
+	}
+	return ttl, err
+}
+
+// Scan 按键的顺序分批返回以 prefix 开头的条目，游标为上一批的最后一个键
+func (b *BuntDBCache) Scan(prefix string, cursor string, count int) ([]Item, string, error) {
+	var items []Item
+	next := ""
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		var iterErr error
+		err := tx.AscendGreaterOrEqual("", max(prefix, cursor), func(key, value string) bool {
+			if key == cursor || isCounterKey(key) {
+				return true
+			}
+			if !strings.HasPrefix(key, prefix) {
+				return false
+			}
+			if count > 0 && len(items) == count {
+				next = items[count-1].Key
+				return false
+			}
+			ttl, err := tx.TTL(key)
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			items = append(items, Item{Key: key, Value: value, TTL: max(ttl, 0)})
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return iterErr
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+// Range 按键的顺序遍历所有条目
+func (b *BuntDBCache) Range(fn func(key, value string) bool) error {
+	return b.db.View(func(tx *buntdb.Tx) error {
+		return tx.Ascend("", func(key, value string) bool {
+			return isCounterKey(key) || fn(key, value)
+		})
+	})
+}
+
+// IncrCounters 在同一个事务中累加计数器
+func (b *BuntDBCache) IncrCounters(group string, deltas map[string]int64) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		for name, delta := range deltas {
+			key := counterKey(group, name)
+			var current int64
+			value, err := tx.Get(key)
+			switch {
+			case errors.Is(err, buntdb.ErrNotFound):
+			case err != nil:
+				return err
+			default:
+				if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+					return fmt.Errorf("计数器 %s 的值不是整数: %w", key, err)
+				}
+			}
+			if _, _, err := tx.Set(key, strconv.FormatInt(current+delta, 10), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Counters 在同一个只读事务中读取计数器
+func (b *BuntDBCache) Counters(group string, names ...string) (map[string]int64, error) {
+	values := make(map[string]int64)
+	add := func(name, value string) {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			values[name] = n
+		}
+	}
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		if len(names) == 0 {
+			prefix := counterKey(group, "")
+			return tx.AscendGreaterOrEqual("", prefix, func(key, value string) bool {
+				if !strings.HasPrefix(key, prefix) {
+					return false
+				}
+				add(strings.TrimPrefix(key, prefix), value)
+				return true
+			})
+		}
+		for _, name := range names {
+			value, err := tx.Get(counterKey(group, name))
+			if errors.Is(err, buntdb.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			add(name, value)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// DeleteCounters 在同一个事务中删除计数器
+func (b *BuntDBCache) DeleteCounters(group string, names ...string) error {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = counterKey(group, name)
+	}
+	return b.Delete(keys...)
+}
+
+// DeleteCounterGroup 删除计数器组的所有计数器
+func (b *BuntDBCache) DeleteCounterGroup(group string) error {
+	counters, err := b.Counters(group)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	return b.DeleteCounters(group, names...)
+}
+
+// Close 关闭缓存文件
+func (b *BuntDBCache) Close() error {
+	return b.db.Close()
+}
+
+// setOptions 有效期对应的写入选项，0 表示不过期
+func setOptions(ttl time.Duration) *buntdb.SetOptions {
+	if ttl <= 0 {
+		return nil
+	}
+	return &buntdb.SetOptions{Expires: true, TTL: ttl}
+}
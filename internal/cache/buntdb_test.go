@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuntDBCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	b, err := NewBuntDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Set("persistent", "1", 0)
+	b.Set("long", "2", time.Hour)
+	b.Set("short", "3", 20*time.Millisecond)
+
+	if ttl, err := b.TTL("persistent"); err != nil || ttl != 0 {
+		t.Errorf("不过期条目 TTL = %v, %v，期望 0", ttl, err)
+	}
+	if ttl, err := b.TTL("long"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL = %v, %v，期望约 1 小时", ttl, err)
+	}
+	if _, err := b.TTL("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不存在的键 err = %v，期望 ErrNotFound", err)
+	}
+	if _, err := b.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不存在的键 err = %v，期望 ErrNotFound", err)
+	}
+	if err := b.Delete("missing"); err != nil {
+		t.Errorf("删除不存在的键返回 %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := b.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("过期条目 err = %v，期望 ErrNotFound", err)
+	}
+
+	// 重新打开后条目和有效期仍然保留
+	b.Close()
+	if b, err = NewBuntDB(path); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if value, err := b.Get("long"); err != nil || value != "2" {
+		t.Errorf("重新打开后 Get = %q, %v", value, err)
+	}
+	if ttl, err := b.TTL("long"); err != nil || ttl <= 0 {
+		t.Errorf("重新打开后 TTL = %v, %v", ttl, err)
+	}
+}
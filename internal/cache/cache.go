@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 缓存后端类型
+const (
+	BackendMemory = "memory" // 进程内 LRU，重启后丢失
+	BackendBuntDB = "buntdb" // 本地 buntdb 文件
+	BackendRedis  = "redis"  // Redis 协议服务，多个副本共享识别结果
+)
+
+// counterKeyPrefix buntdb 和 Redis 中计数器的键前缀
+// 识别结果的键以十六进制哈希开头，不会与之冲突
+const counterKeyPrefix = "counters:"
+
+// ErrNotFound 键不存在或已过期
+var ErrNotFound = errors.New("缓存不存在")
+
+// ErrInvalidCursor Scan 的游标无效
+var ErrInvalidCursor = errors.New("无效的游标")
+
+// Cache 识别结果缓存的存储后端
+type Cache interface {
+	// Get 读取 key 的值，不存在或已过期时返回 ErrNotFound
+	Get(key string) (string, error)
+	// GetMulti 批量读取，返回存在的 key 及其值
+	GetMulti(keys []string) (map[string]string, error)
+	// Set 写入 key-value，ttl 为 0 表示不过期
+	Set(key string, value string, ttl time.Duration) error
+	// Delete 删除 key，不存在时不返回错误
+	Delete(keys ...string) error
+	// TTL 返回 key 的剩余有效期，不过期时返回 0，不存在时返回 ErrNotFound
+	TTL(key string) (time.Duration, error)
+	// Scan 从 cursor（第一次为空）开始返回一批以 prefix 开头的条目（包括剩余有效期）和下一批的游标，
+	// 游标为空表示遍历结束；count 为每批数量的提示，Redis 返回的数量可能略多
+	Scan(prefix string, cursor string, count int) ([]Item, string, error)
+	// Range 遍历所有未过期的条目（无固定顺序），fn 返回 false 时停止；fn 中不能读写缓存
+	Range(fn func(key, value string) bool) error
+
+	// 计数器与条目分开保存：不占用条目容量，不会被淘汰或过期，也不出现在 Scan 和 Range 中
+
+	// IncrCounters 将计数器组 group 中的各个计数器加上对应的增量，不存在的计数器从 0 开始
+	IncrCounters(group string, deltas map[string]int64) error
+	// Counters 读取计数器组中的计数器，names 为空时读取整个组；不存在的计数器不返回
+	Counters(group string, names ...string) (map[string]int64, error)
+	// DeleteCounters 删除计数器组中的计数器
+	DeleteCounters(group string, names ...string) error
+	// DeleteCounterGroup 删除整个计数器组
+	DeleteCounterGroup(group string) error
+
+	// Close 释放连接或文件
+	Close() error
+}
+
+// Item Scan 返回的条目
+type Item struct {
+	Key   string
+	Value string
+	TTL   time.Duration // 剩余有效期，0 表示不过期
+}
+
+// Options 缓存后端配置
+type Options struct {
+	Backend        string // BackendMemory、BackendBuntDB 或 BackendRedis
+	Path           string // buntdb 文件路径
+	MemoryMaxItems int    // 内存缓存最大条目数，0 表示不限制
+	MemoryMaxBytes int64  // 内存缓存最大字节数（键和值），0 表示不限制
+	RedisAddr      string // Redis 地址（host:port）
+	RedisPassword  string
+	RedisDB        int
+	RedisKeyPrefix string // 键前缀，多个服务共用同一个 Redis 时区分数据
+}
+
+// New 按配置创建缓存后端
+func New(options Options) (Cache, error) {
+	switch options.Backend {
+	case BackendMemory:
+		return NewMemory(options.MemoryMaxItems, options.MemoryMaxBytes), nil
+	case BackendBuntDB, "":
+		return NewBuntDB(options.Path)
+	case BackendRedis:
+		return NewRedis(options.RedisAddr, options.RedisPassword, options.RedisDB, options.RedisKeyPrefix)
+	}
+	return nil, fmt.Errorf("不支持的缓存后端: %s", options.Backend)
+}
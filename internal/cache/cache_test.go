@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testBackends 创建三种后端，测试结束时关闭
+func testBackends(t *testing.T) map[string]Cache {
+	t.Helper()
+	bunt, err := NewBuntDB(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	redis, err := NewRedis(miniredis.RunT(t).Addr(), "", 0, "glm:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]Cache{
+		BackendMemory: NewMemory(0, 0),
+		BackendBuntDB: bunt,
+		BackendRedis:  redis,
+	}
+	t.Cleanup(func() {
+		for _, c := range backends {
+			c.Close()
+		}
+	})
+	return backends
+}
+
+func TestBackendScan(t *testing.T) {
+	for name, c := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 7; i++ {
+				c.Set(fmt.Sprintf("a:%d", i), "v", time.Hour)
+			}
+			c.Set("b:0", "v", 0)
+
+			var keys []string
+			cursor, pages := "", 0
+			for {
+				items, next, err := c.Scan("a:", cursor, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, item := range items {
+					keys = append(keys, item.Key)
+					if item.TTL <= 59*time.Minute || item.TTL > time.Hour {
+						t.Errorf("%s 的剩余有效期 = %v", item.Key, item.TTL)
+					}
+				}
+				pages++
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if len(keys) != 7 || pages < 3 {
+				t.Errorf("分 %d 批遍历到 %v，期望分批返回 a: 开头的 7 个键", pages, keys)
+			}
+
+			items, next, err := c.Scan("b:", "", 10)
+			if err != nil || next != "" || len(items) != 1 || items[0].TTL != 0 {
+				t.Errorf("Scan(b:) = %+v, %q, %v，期望一个不过期的条目", items, next, err)
+			}
+		})
+	}
+}
+
+func TestBackendGetMultiAndDelete(t *testing.T) {
+	for name, c := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.Set("a", "1", 0)
+			c.Set("b", "2", 0)
+			c.Set("c", "3", 0)
+
+			values, err := c.GetMulti([]string{"a", "missing", "c"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]string{"a": "1", "c": "3"}; !reflect.DeepEqual(values, want) {
+				t.Errorf("GetMulti = %v，期望 %v", values, want)
+			}
+
+			if err := c.Delete("a", "b", "missing"); err != nil {
+				t.Fatal(err)
+			}
+			values, _ = c.GetMulti([]string{"a", "b", "c"})
+			if want := map[string]string{"c": "3"}; !reflect.DeepEqual(values, want) {
+				t.Errorf("删除后 GetMulti = %v，期望 %v", values, want)
+			}
+		})
+	}
+}
+
+func TestBackendCounters(t *testing.T) {
+	for name, c := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.Set("entry", "v", time.Hour)
+			for i := 0; i < 3; i++ {
+				if err := c.IncrCounters("stats", map[string]int64{"entries:1": 1, "bytes:1": 10}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.IncrCounters("stats", map[string]int64{"entries:1": -1})
+			c.IncrCounters("hits:1", map[string]int64{"entry": 1})
+
+			all, err := c.Counters("stats")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]int64{"entries:1": 2, "bytes:1": 30}; !reflect.DeepEqual(all, want) {
+				t.Errorf("Counters(stats) = %v，期望 %v", all, want)
+			}
+			named, _ := c.Counters("stats", "bytes:1", "missing")
+			if want := map[string]int64{"bytes:1": 30}; !reflect.DeepEqual(named, want) {
+				t.Errorf("Counters(stats, bytes:1, missing) = %v，期望 %v", named, want)
+			}
+
+			// 计数器不出现在条目中
+			items, _, err := c.Scan("", "", 100)
+			if err != nil || len(items) != 1 || items[0].Key != "entry" {
+				t.Errorf("Scan = %+v, %v，期望只有 entry", items, err)
+			}
+
+			c.DeleteCounters("stats", "entries:1")
+			if all, _ := c.Counters("stats"); !reflect.DeepEqual(all, map[string]int64{"bytes:1": 30}) {
+				t.Errorf("删除后 Counters(stats) = %v", all)
+			}
+			c.DeleteCounterGroup("hits:1")
+			if all, _ := c.Counters("hits:1"); len(all) != 0 {
+				t.Errorf("删除计数器组后仍有 %v", all)
+			}
+			if all, _ := c.Counters("stats"); len(all) != 1 {
+				t.Errorf("删除其他计数器组影响了 stats: %v", all)
+			}
+		})
+	}
+}
+
+func TestStatsBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	hour := now.Unix() / 3600
+	tests := []struct {
+		ttl         time.Duration
+		wantBucket  int64
+		wantExpired bool // 一小时后是否已过期
+	}{
+		{ttl: 0, wantBucket: 0},
+		{ttl: 10 * time.Minute, wantBucket: hour + 1, wantExpired: true},
+		{ttl: 30 * time.Minute, wantBucket: hour + 1, wantExpired: true},
+		{ttl: 24 * time.Hour, wantBucket: hour + 25},
+	}
+	for _, tt := range tests {
+		bucket := statsBucket(tt.ttl, now)
+		if bucket != tt.wantBucket {
+			t.Errorf("statsBucket(%v) = %d，期望 %d", tt.ttl, bucket, tt.wantBucket)
+		}
+		if bucketExpired(bucket, now) {
+			t.Errorf("分组 %d 在写入时已过期", bucket)
+		}
+		if got := bucketExpired(bucket, now.Add(time.Hour)); got != tt.wantExpired {
+			t.Errorf("分组 %d 一小时后是否过期 = %v，期望 %v", bucket, got, tt.wantExpired)
+		}
+	}
+}
+
+func TestPruneCounters(t *testing.T) {
+	c := NewMemory(0, 0)
+	now := time.Now()
+	live := statsBucket(time.Hour, now)
+	expired := statsBucket(time.Hour, now.Add(-3*time.Hour))
+	c.IncrCounters(statsGroup, map[string]int64{
+		statsCounter(statsEntries, 0):       1,
+		statsCounter(statsEntries, live):    2,
+		statsCounter(statsEntries, expired): 4,
+		statsCounter(statsHits, expired):    1,
+	})
+	c.IncrCounters(hitsGroup(expired), map[string]int64{"old": 1})
+	c.IncrCounters(hitsGroup(live), map[string]int64{"new": 1})
+
+	if entries, _, _ := readStats(c); entries != 3 {
+		t.Errorf("统计条目数 = %d，期望不计入过期分组的 3", entries)
+	}
+
+	prunedBucket.Store(0)
+	pruneCounters(c, now)
+	counters, _ := c.Counters(statsGroup)
+	if len(counters) != 2 {
+		t.Errorf("删除过期分组后剩余 %v", counters)
+	}
+	if old, _ := c.Counters(hitsGroup(expired)); len(old) != 0 {
+		t.Errorf("过期分组的命中次数计数器未删除: %v", old)
+	}
+	if current, _ := c.Counters(hitsGroup(live)); len(current) != 1 {
+		t.Errorf("未过期分组的命中次数计数器被删除: %v", current)
+	}
+}
+
+func TestScanRejectsInvalidRedisCursor(t *testing.T) {
+	c := testBackends(t)[BackendRedis]
+	if _, _, err := c.Scan("", "not-a-cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("err = %v，期望 ErrInvalidCursor", err)
+	}
+}
@@ -3,38 +3,115 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"glm-tool/config"
 
-	cacheconfig "github.com/gophertool/tool/db/cache/config"
-	_interface "github.com/gophertool/tool/db/cache/interface"
 	"github.com/gophertool/tool/log"
-
-	// 导入 buntdb 驱动以注册驱动
-	_ "github.com/gophertool/tool/db/cache/buntdb"
 )
 
 var (
-	imageCache _interface.Cache
-	once       sync.Once
+	imageCache Cache
+	backend    string // 实际使用的后端（回退后与配置不同）
+	mu         sync.RWMutex
+
+	// 进程启动以来的缓存命中和未命中次数
+	hits   atomic.Int64
+	misses atomic.Int64
 )
 
-// getCache 延迟初始化缓存
-func getCache() _interface.Cache {
-	once.Do(func() {
-		cfg := cacheconfig.Cache{
-			Driver: cacheconfig.CacheDriverBuntdb,
-			Path:   config.AppConfig.CachePath,
-		}
+// SchemaVersion 识别结果条目的格式版本，格式变化时递增，并在 migrate 中转换旧条目
+const SchemaVersion = 1
+
+// Usage 识别消耗的 token
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
 
-		var err error
-		imageCache, err = _interface.New(cfg)
-		if err != nil {
-			log.Errorf("初始化图片缓存失败: %v", err)
+// Result 缓存的识别结果及其来源
+type Result struct {
+	Version     int       `json:"v"`
+	Description string    `json:"description"`
+	Model       string    `json:"model"`                 // 视觉模型
+	Profile     string    `json:"profile,omitempty"`     // 实际使用的提示词模板
+	PromptHash  string    `json:"prompt_hash,omitempty"` // 提示词标识的哈希，默认提示词时为空
+	Usage       Usage     `json:"usage"`
+	Created     time.Time `json:"created"`
+}
+
+// Init 按配置创建识别结果缓存，服务启动时调用；未初始化时不使用缓存
+// 配置的后端不可用时回退到进程内 LRU 缓存，返回的错误说明回退原因
+func Init() error {
+	options := Options{
+		Backend:        config.AppConfig.CacheBackend,
+		Path:           config.AppConfig.CachePath,
+		MemoryMaxItems: config.AppConfig.CacheMemoryMaxItems,
+		MemoryMaxBytes: int64(config.AppConfig.CacheMemoryMaxMB) * 1024 * 1024,
+		RedisAddr:      config.AppConfig.CacheRedisAddr,
+		RedisPassword:  config.AppConfig.CacheRedisPassword,
+		RedisDB:        config.AppConfig.CacheRedisDB,
+		RedisKeyPrefix: config.AppConfig.CacheRedisKeyPrefix,
+	}
+	c, initErr := New(options)
+	if initErr != nil {
+		if options.Backend == BackendMemory {
+			return initErr
 		}
-	})
+		initErr = fmt.Errorf("缓存后端 %s 不可用，改用进程内缓存: %w", options.Backend, initErr)
+		options.Backend = BackendMemory
+		c = NewMemory(options.MemoryMaxItems, options.MemoryMaxBytes)
+	}
+	if m, ok := c.(*MemoryCache); ok {
+		// 超出容量被淘汰的识别结果不会再过期，需要从统计中扣除
+		m.onEvict = func(key, value string, ttl time.Duration) {
+			if isResultKey(key) {
+				countEntry(m, entrySize(key, value), ttl, -1)
+				m.DeleteCounters(hitsGroup(statsBucket(ttl, time.Now())), key)
+			}
+		}
+	}
+	if options.Backend == BackendBuntDB {
+		// 只有 buntdb 文件中可能存在旧版本写入的条目
+		upgrade(c, config.AppConfig.VisionModel)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if imageCache != nil {
+		imageCache.Close()
+	}
+	imageCache = c
+	backend = options.Backend
+	prunedBucket.Store(0)
+	log.Infof("图片识别缓存已初始化（后端: %s）", options.Backend)
+	return initErr
+}
+
+// Close 关闭识别结果缓存
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if imageCache == nil {
+		return
+	}
+	if err := imageCache.Close(); err != nil {
+		log.Warnf("关闭图片缓存失败: %v", err)
+	}
+	imageCache = nil
+}
+
+// getCache 返回当前的缓存后端，未初始化时返回 nil
+func getCache() Cache {
+	mu.RLock()
+	defer mu.RUnlock()
 	return imageCache
 }
 
@@ -44,28 +121,130 @@ func ComputeHash(imageData string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// GetImageResult 从缓存获取图片识别结果
-func GetImageResult(imageHash string) (string, bool) {
+// PromptHash 提示词标识的哈希，默认提示词时为空
+func PromptHash(prompt string) string {
+	if prompt == "" {
+		return ""
+	}
+	return ComputeHash(prompt)
+}
+
+// ResultKey 识别结果的缓存键：图片哈希:变体哈希，变体由视觉模型和提示词共同决定，
+// 更换模型或提示词后不会复用旧的识别结果
+func ResultKey(imageHash string, model string, prompt string) string {
+	return variantKey(imageHash, model, PromptHash(prompt))
+}
+
+// isResultKey 是否为识别结果的键（而不是计数器、格式版本等内部数据）
+func isResultKey(key string) bool {
+	return !strings.HasPrefix(key, counterKeyPrefix) && key != schemaVersionKey
+}
+
+// variantKey 根据图片哈希、视觉模型和提示词哈希生成缓存键
+func variantKey(imageHash string, model string, promptHash string) string {
+	return imageHash + ":" + ComputeHash(model+"\n"+promptHash)
+}
+
+// GetImageResult 从缓存获取图片识别结果，计入命中统计
+func GetImageResult(key string) (string, bool) {
 	cache := getCache()
-	if cache == nil {
+	result, found := readResult(cache, key)
+	if !found {
+		misses.Add(1)
 		return "", false
 	}
-	result, err := cache.Get(imageHash)
-	if err != nil {
+	hits.Add(1)
+	recordHit(cache, key)
+	return result.Description, true
+}
+
+// PeekImageResult 从缓存获取图片识别结果，不计入命中统计（用于同一张图片的重复检查）
+func PeekImageResult(key string) (string, bool) {
+	result, found := readResult(getCache(), key)
+	if !found {
 		return "", false
 	}
-	return result, true
+	return result.Description, true
 }
 
-// SetImageResult 保存图片识别结果到缓存
-func SetImageResult(imageHash string, result string) {
+// SetImageResult 保存图片识别结果到缓存，版本和创建时间未设置时自动填充
+func SetImageResult(key string, result Result) {
 	cache := getCache()
 	if cache == nil {
 		return
 	}
+	if result.Version == 0 {
+		result.Version = SchemaVersion
+	}
+	if result.Created.IsZero() {
+		result.Created = time.Now()
+	}
 	ttl := time.Duration(config.AppConfig.CacheTTLHours) * time.Hour
-	err := cache.Set(imageHash, result, ttl)
-	if err != nil {
+	if err := writeResult(cache, key, result, ttl); err != nil {
 		log.Warnf("保存图片缓存失败: %v", err)
 	}
 }
+
+// readResult 读取并解析识别结果，缓存未初始化、条目不存在或格式不是当前版本时返回 false
+func readResult(cache Cache, key string) (Result, bool) {
+	if cache == nil {
+		return Result{}, false
+	}
+	value, err := cache.Get(key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Warnf("读取图片缓存失败: %v", err)
+		}
+		return Result{}, false
+	}
+	result, ok := decodeResult(value)
+	if !ok {
+		log.Warnf("图片缓存条目格式无法识别，忽略: %s", key)
+	}
+	return result, ok
+}
+
+// recordHit 累加条目的命中次数，命中时只累加计数器，不改写条目
+// 计数器按条目的过期时间分组，分组过期后整组删除
+func recordHit(cache Cache, key string) {
+	ttl, err := cache.TTL(key)
+	if err != nil {
+		return
+	}
+	bucket := statsBucket(ttl, time.Now())
+	if err := cache.IncrCounters(hitsGroup(bucket), map[string]int64{key: 1}); err != nil {
+		log.Warnf("更新图片缓存命中次数失败: %v", err)
+		return
+	}
+	if err := cache.IncrCounters(statsGroup, map[string]int64{statsCounter(statsHits, bucket): 1}); err != nil {
+		log.Warnf("更新图片缓存统计失败: %v", err)
+	}
+}
+
+// decodeResult 解析当前版本的识别结果条目
+func decodeResult(value string) (Result, bool) {
+	var result Result
+	if err := json.Unmarshal([]byte(value), &result); err != nil || result.Version != SchemaVersion {
+		return Result{}, false
+	}
+	return result, true
+}
+
+// writeResult 序列化并保存识别结果条目，同时更新统计计数器（覆盖已有条目时先扣除旧条目）
+func writeResult(cache Cache, key string, result Result, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if old, err := cache.Get(key); err == nil {
+		if oldTTL, err := cache.TTL(key); err == nil {
+			countEntry(cache, entrySize(key, old), oldTTL, -1)
+		}
+	}
+	if err := cache.Set(key, string(data), ttl); err != nil {
+		return err
+	}
+	countEntry(cache, entrySize(key, string(data)), ttl, 1)
+	pruneCounters(cache, time.Now())
+	return nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"glm-tool/config"
+)
+
+// useConfig 在测试期间替换全局配置，结束时关闭缓存并恢复
+func useConfig(t *testing.T, cfg config.Config) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &cfg
+	t.Cleanup(func() {
+		Close()
+		config.AppConfig = previous
+	})
+}
+
+func TestInitFallsBackToMemory(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	useConfig(t, config.Config{CacheBackend: BackendRedis, CacheRedisAddr: addr, CacheMemoryMaxItems: 10})
+
+	if err := Init(); err == nil {
+		t.Error("期望返回回退原因")
+	}
+	c, ok := getCache().(*MemoryCache)
+	if !ok {
+		t.Fatalf("缓存后端 = %T，期望回退到 *MemoryCache", getCache())
+	}
+	if c.maxItems != 10 {
+		t.Errorf("回退的内存缓存条目上限 = %d，期望使用配置的 10", c.maxItems)
+	}
+
+	SetImageResult("key", Result{Description: "cat"})
+	if description, ok := GetImageResult("key"); !ok || description != "cat" {
+		t.Errorf("回退后读取 = %q, %v", description, ok)
+	}
+}
+
+func TestGetImageResultCountsHits(t *testing.T) {
+	initTestCache(t, config.Config{CacheBackend: BackendMemory})
+	c := getCache()
+	key := ResultKey(ComputeHash("image"), "glm-4.5v", "")
+	SetImageResult(key, Result{Description: "cat"})
+	stored, _ := c.Get(key)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := GetImageResult(key); !ok {
+			t.Fatal("未命中")
+		}
+	}
+	PeekImageResult(key)
+
+	// 命中只累加计数器，不改写条目
+	if value, _ := c.Get(key); value != stored {
+		t.Errorf("命中后条目被改写: %s", value)
+	}
+	entry, err := GetEntry(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Hits != 3 {
+		t.Errorf("命中次数 = %d，期望 3", entry.Hits)
+	}
+
+	// 删除条目后计数器一并删除，命中不会恢复条目
+	if _, err := DeleteEntries(key); err != nil {
+		t.Fatal(err)
+	}
+	hits, _ := c.Counters(hitsGroup(statsBucket(24*time.Hour, time.Now())), key)
+	if len(hits) != 0 {
+		t.Error("删除条目后计数器仍然存在")
+	}
+	if _, ok := GetImageResult(key); ok {
+		t.Error("删除后仍然命中")
+	}
+}
+
+func TestInitUpgradesBuntDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	b, err := NewBuntDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageHash := ComputeHash("image")
+	b.Set(imageHash, "a cat", 0)
+	b.Close()
+
+	initTestCache(t, config.Config{CacheBackend: BackendBuntDB, CachePath: path})
+	if description, ok := PeekImageResult(ResultKey(imageHash, "glm-4.5v", "")); !ok || description != "a cat" {
+		t.Errorf("旧条目转换后读取 = %q, %v", description, ok)
+	}
+	if _, err := getCache().Get(schemaVersionKey); err != nil {
+		t.Errorf("未记录格式版本: %v", err)
+	}
+}
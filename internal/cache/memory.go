@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryCache 进程内 LRU 缓存，按条目数和字节数淘汰最久未使用的条目
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxItems int
+	maxBytes int64
+	bytes    int64
+	order    *list.List // 最近使用的在前
+	items    map[string]*list.Element
+
+	// onEvict 条目因超出容量被淘汰后调用（不含过期和删除），调用时不持有锁
+	onEvict func(key, value string, ttl time.Duration)
+
+	// 计数器组 -> 计数器，与 LRU 条目分开保存，不参与容量计算和淘汰
+	countersMu sync.RWMutex
+	counters   map[string]map[string]*atomic.Int64
+}
+
+// memoryEntry LRU 链表中的条目
+type memoryEntry struct {
+	key     string
+	value   string
+	expires time.Time // 零值表示不过期
+}
+
+// NewMemory 创建内存缓存，maxItems / maxBytes 为 0 表示不限制
+func NewMemory(maxItems int, maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		counters: make(map[string]map[string]*atomic.Int64),
+	}
+}
+
+// Get 读取 key 的值并标记为最近使用
+func (m *MemoryCache) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.get(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return entry.value, nil
+}
+
+// GetMulti 批量读取并标记为最近使用
+func (m *MemoryCache) GetMulti(keys []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if entry, ok := m.get(key); ok {
+			values[key] = entry.value
+		}
+	}
+	return values, nil
+}
+
+// Set 写入 key-value，超出容量时淘汰最久未使用的条目
+func (m *MemoryCache) Set(key string, value string, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	m.mu.Lock()
+	evicted := m.set(key, value, expires)
+	m.mu.Unlock()
+
+	m.evicted(evicted)
+	return nil
+}
+
+// Delete 删除 key
+func (m *MemoryCache) Delete(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+// TTL 返回 key 的剩余有效期
+func (m *MemoryCache) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	entry := elem.Value.(*memoryEntry)
+	if entry.expires.IsZero() {
+		return 0, nil
+	}
+	ttl := time.Until(entry.expires)
+	if ttl <= 0 {
+		return 0, ErrNotFound
+	}
+	return ttl, nil
+}
+
+// Scan 按键的顺序分批返回以 prefix 开头的条目，游标为上一批的最后一个键
+func (m *MemoryCache) Scan(prefix string, cursor string, count int) ([]Item, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, elem := range m.items {
+		entry := elem.Value.(*memoryEntry)
+		if key > cursor && strings.HasPrefix(key, prefix) && (entry.expires.IsZero() || now.Before(entry.expires)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	next := ""
+	if count > 0 && len(keys) > count {
+		keys = keys[:count]
+		next = keys[count-1]
+	}
+	items := make([]Item, 0, len(keys))
+	for _, key := range keys {
+		entry := m.items[key].Value.(*memoryEntry)
+		item := Item{Key: key, Value: entry.value}
+		if !entry.expires.IsZero() {
+			item.TTL = entry.expires.Sub(now)
+		}
+		items = append(items, item)
+	}
+	return items, next, nil
+}
+
+// Range 遍历所有未过期的条目（遍历的是调用时的快照）
+func (m *MemoryCache) Range(fn func(key, value string) bool) error {
+	m.mu.Lock()
+	now := time.Now()
+	entries := make([]memoryEntry, 0, m.order.Len())
+	for elem := m.order.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*memoryEntry)
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			entries = append(entries, *entry)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, entry := range entries {
+		if !fn(entry.key, entry.value) {
+			break
+		}
+	}
+	return nil
+}
+
+// IncrCounters 原子累加计数器
+func (m *MemoryCache) IncrCounters(group string, deltas map[string]int64) error {
+	for name, delta := range deltas {
+		m.counter(group, name).Add(delta)
+	}
+	return nil
+}
+
+// Counters 读取计数器
+func (m *MemoryCache) Counters(group string, names ...string) (map[string]int64, error) {
+	m.countersMu.RLock()
+	defer m.countersMu.RUnlock()
+
+	counters := m.counters[group]
+	values := make(map[string]int64)
+	if len(names) == 0 {
+		for name, counter := range counters {
+			values[name] = counter.Load()
+		}
+		return values, nil
+	}
+	for _, name := range names {
+		if counter, ok := counters[name]; ok {
+			values[name] = counter.Load()
+		}
+	}
+	return values, nil
+}
+
+// DeleteCounters 删除计数器
+func (m *MemoryCache) DeleteCounters(group string, names ...string) error {
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+
+	for _, name := range names {
+		delete(m.counters[group], name)
+	}
+	if len(m.counters[group]) == 0 {
+		delete(m.counters, group)
+	}
+	return nil
+}
+
+// DeleteCounterGroup 删除整个计数器组
+func (m *MemoryCache) DeleteCounterGroup(group string) error {
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+
+	delete(m.counters, group)
+	return nil
+}
+
+// Close 清空缓存和计数器
+func (m *MemoryCache) Close() error {
+	m.mu.Lock()
+	m.order.Init()
+	m.items = make(map[string]*list.Element)
+	m.bytes = 0
+	m.mu.Unlock()
+
+	m.countersMu.Lock()
+	m.counters = make(map[string]map[string]*atomic.Int64)
+	m.countersMu.Unlock()
+	return nil
+}
+
+// counter 返回计数器，不存在时创建
+func (m *MemoryCache) counter(group, name string) *atomic.Int64 {
+	m.countersMu.RLock()
+	counter, ok := m.counters[group][name]
+	m.countersMu.RUnlock()
+	if ok {
+		return counter
+	}
+
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+	counters, ok := m.counters[group]
+	if !ok {
+		counters = make(map[string]*atomic.Int64)
+		m.counters[group] = counters
+	}
+	if counter, ok = counters[name]; !ok {
+		counter = new(atomic.Int64)
+		counters[name] = counter
+	}
+	return counter
+}
+
+// get 查找未过期的条目并标记为最近使用，调用方需持有锁
+func (m *MemoryCache) get(key string) (*memoryEntry, bool) {
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		m.remove(elem)
+		return nil, false
+	}
+	m.order.MoveToFront(elem)
+	return entry, true
+}
+
+// set 写入条目并淘汰超出容量的条目，返回被淘汰的条目，调用方需持有锁
+func (m *MemoryCache) set(key string, value string, expires time.Time) []memoryEntry {
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	size := entrySize(key, value)
+	if m.maxBytes > 0 && size > m.maxBytes {
+		// 单个条目超过容量上限，不缓存
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	m.bytes += size
+
+	var evicted []memoryEntry
+	for (m.maxItems > 0 && m.order.Len() > m.maxItems) || (m.maxBytes > 0 && m.bytes > m.maxBytes) {
+		evicted = append(evicted, *m.remove(m.order.Back()))
+	}
+	return evicted
+}
+
+// evicted 通知被淘汰的条目，调用方不能持有锁
+func (m *MemoryCache) evicted(entries []memoryEntry) {
+	if m.onEvict == nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		var ttl time.Duration
+		if !entry.expires.IsZero() {
+			if ttl = entry.expires.Sub(now); ttl <= 0 {
+				// 已过期的条目按过期处理
+				continue
+			}
+		}
+		m.onEvict(entry.key, entry.value, ttl)
+	}
+}
+
+// remove 删除链表条目，调用方需持有锁
+func (m *MemoryCache) remove(elem *list.Element) *memoryEntry {
+	entry := m.order.Remove(elem).(*memoryEntry)
+	delete(m.items, entry.key)
+	m.bytes -= entrySize(entry.key, entry.value)
+	return entry
+}
+
+// entrySize 条目占用的字节数（键和值），内存缓存的容量和缓存统计都按此计算
+func entrySize(key, value string) int64 {
+	return int64(len(key) + len(value))
+}
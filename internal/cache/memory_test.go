@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsByItems(t *testing.T) {
+	m := NewMemory(2, 0)
+	m.Set("a", "1", 0)
+	m.Set("b", "2", 0)
+	m.Get("a") // a 变为最近使用
+	m.Set("c", "3", 0)
+
+	if _, err := m.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("最久未使用的 b 未被淘汰: %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := m.Get(key); err != nil {
+			t.Errorf("%s 被淘汰: %v", key, err)
+		}
+	}
+}
+
+func TestMemoryCacheEvictsByBytes(t *testing.T) {
+	m := NewMemory(0, 10)
+	m.Set("a", "1234", 0) // 5 字节
+	m.Set("b", "1234", 0) // 10 字节
+	m.Set("c", "12", 0)   // 13 字节，淘汰 a
+
+	if _, err := m.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("超出容量后 a 未被淘汰: %v", err)
+	}
+	if m.bytes != 8 {
+		t.Errorf("占用 %d 字节，期望 8", m.bytes)
+	}
+
+	// 单个条目超过容量上限时不缓存，也不淘汰已有条目
+	m.Set("d", "12345678901", 0)
+	if _, err := m.Get("d"); !errors.Is(err, ErrNotFound) {
+		t.Error("超过容量上限的条目被缓存")
+	}
+	if _, err := m.Get("b"); err != nil {
+		t.Errorf("写入超大条目后 b 被淘汰: %v", err)
+	}
+
+	// 覆盖写入时按新值计算占用
+	m.Set("b", "1", 0)
+	if m.bytes != 5 {
+		t.Errorf("覆盖写入后占用 %d 字节，期望 5", m.bytes)
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	m := NewMemory(0, 0)
+	m.Set("persistent", "1", 0)
+	m.Set("short", "1", 20*time.Millisecond)
+	m.Set("long", "1", time.Hour)
+
+	if ttl, err := m.TTL("persistent"); err != nil || ttl != 0 {
+		t.Errorf("不过期条目 TTL = %v, %v，期望 0", ttl, err)
+	}
+	if ttl, err := m.TTL("long"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL = %v, %v，期望约 1 小时", ttl, err)
+	}
+	if _, err := m.TTL("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不存在的键 err = %v，期望 ErrNotFound", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := m.TTL("short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("过期条目 TTL err = %v，期望 ErrNotFound", err)
+	}
+	var keys []string
+	m.Range(func(key, value string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 2 {
+		t.Errorf("Range 返回 %v，期望不包含过期条目", keys)
+	}
+	if _, err := m.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("过期条目 Get err = %v，期望 ErrNotFound", err)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gophertool/tool/log"
+)
+
+// schemaVersionKey 记录缓存数据格式版本的键，版本不低于 SchemaVersion 时启动时不再遍历缓存
+const schemaVersionKey = "schema_version"
+
+// legacyEntry 待转换的旧格式条目
+type legacyEntry struct {
+	key   string
+	value string
+}
+
+// upgrade 将旧格式的条目转换为当前格式并记录格式版本，已转换过时直接返回
+// 转换失败只记录日志：未转换的条目读取时按无法识别处理，下次启动时重试
+func upgrade(cache Cache, defaultModel string) {
+	if value, err := cache.Get(schemaVersionKey); err == nil {
+		if version, _ := strconv.Atoi(value); version >= SchemaVersion {
+			return
+		}
+	}
+
+	migrated, err := migrate(cache, defaultModel)
+	if migrated > 0 {
+		log.Infof("已将 %d 条旧格式的图片缓存转换为当前格式（版本 %d）", migrated, SchemaVersion)
+	}
+	if err != nil {
+		log.Warnf("转换旧格式的图片缓存失败，下次启动时重试: %v", err)
+		return
+	}
+	if err := cache.Set(schemaVersionKey, strconv.Itoa(SchemaVersion), 0); err != nil {
+		log.Warnf("保存图片缓存格式版本失败: %v", err)
+	}
+}
+
+// migrate 将旧格式的识别结果转换为当前格式，返回转换的条目数
+// 旧格式的键为图片哈希，值为使用默认提示词识别的结果文本，没有记录模型，视为由 defaultModel 识别
+func migrate(cache Cache, defaultModel string) (int, error) {
+	var entries []legacyEntry
+	err := cache.Range(func(key, value string) bool {
+		if isLegacyKey(key) && !isVersioned(value) {
+			entries = append(entries, legacyEntry{key: key, value: value})
+		}
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("遍历缓存失败: %w", err)
+	}
+
+	migrated := 0
+	for _, entry := range entries {
+		ttl, err := cache.TTL(entry.key)
+		if err != nil {
+			// 遍历后已过期或被删除
+			continue
+		}
+		result := Result{
+			Version:     SchemaVersion,
+			Description: entry.value,
+			Model:       defaultModel,
+		}
+		if err := writeResult(cache, variantKey(entry.key, defaultModel, ""), result, ttl); err != nil {
+			return migrated, fmt.Errorf("写入缓存条目失败: %w", err)
+		}
+		if err := cache.Delete(entry.key); err != nil {
+			return migrated, fmt.Errorf("删除旧缓存条目失败: %w", err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// isLegacyKey 键是否为旧格式的图片哈希（64 位十六进制的 SHA-256）
+func isLegacyKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// isVersioned 值是否为带版本号的条目（包括更新版本写入、当前版本无法解析的条目），这类条目不做转换
+func isVersioned(value string) bool {
+	var probe struct {
+		Version int `json:"v"`
+	}
+	return json.Unmarshal([]byte(value), &probe) == nil && probe.Version > 0
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"glm-tool/config"
+)
+
+func TestMigrate(t *testing.T) {
+	imageHash := ComputeHash("image")
+	versioned := `{"v":1,"description":"current","model":"glm-4.6v"}`
+	future := `{"v":99,"description":"future"}`
+
+	c := NewMemory(0, 0)
+	c.Set(imageHash, "a cat", time.Hour)
+	c.Set(ComputeHash("persistent"), "a dog", 0)
+	c.Set("current", versioned, 0)
+	c.Set("future", future, 0)
+	c.Set("not-a-hash", "text", 0)
+
+	migrated, err := migrate(c, "glm-4.5v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 2 {
+		t.Errorf("转换了 %d 条，期望 2 条", migrated)
+	}
+
+	tests := []struct {
+		name            string
+		imageHash       string
+		wantDescription string
+		wantTTL         bool
+	}{
+		{name: "有效期", imageHash: imageHash, wantDescription: "a cat", wantTTL: true},
+		{name: "不过期", imageHash: ComputeHash("persistent"), wantDescription: "a dog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := ResultKey(tt.imageHash, "glm-4.5v", "")
+			result, ok := readResult(c, key)
+			if !ok {
+				t.Fatalf("转换后的条目 %s 不存在", key)
+			}
+			if result.Description != tt.wantDescription || result.Model != "glm-4.5v" || result.PromptHash != "" || !result.Created.IsZero() {
+				t.Errorf("转换结果 = %+v", result)
+			}
+			if ttl, _ := c.TTL(key); (ttl > 0) != tt.wantTTL {
+				t.Errorf("剩余有效期 = %v，期望保留原有效期", ttl)
+			}
+			if _, err := c.Get(tt.imageHash); !errors.Is(err, ErrNotFound) {
+				t.Errorf("旧的键 %s 未删除", tt.imageHash)
+			}
+		})
+	}
+
+	// 带版本号的条目和不是图片哈希的键保持不变
+	for key, want := range map[string]string{"current": versioned, "future": future, "not-a-hash": "text"} {
+		if value, _ := c.Get(key); value != want {
+			t.Errorf("%s = %q，期望保持不变", key, value)
+		}
+	}
+}
+
+// TestInitMigratesBaselineCache 旧版本写入的 buntdb 文件在启动时转换一次
+func TestInitMigratesBaselineCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	seed := func(key, value string) {
+		t.Helper()
+		db, err := NewBuntDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.Set(key, value, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed(ComputeHash("image"), "a cat")
+
+	cfg := config.Config{CacheBackend: BackendBuntDB, CachePath: path}
+	initTestCache(t, cfg)
+	if description, ok := GetImageResult(ResultKey(ComputeHash("image"), "glm-4.5v", "")); !ok || description != "a cat" {
+		t.Errorf("转换后读取 = %q, %v", description, ok)
+	}
+	if value, _ := getCache().Get(schemaVersionKey); value != strconv.Itoa(SchemaVersion) {
+		t.Errorf("格式版本 = %q，期望 %d", value, SchemaVersion)
+	}
+	checkStats(t, 1)
+	getCache().Close()
+
+	// 已记录格式版本，再次启动时不再遍历转换
+	seed(ComputeHash("other"), "a dog")
+	initTestCache(t, cfg)
+	if value, err := getCache().Get(ComputeHash("other")); err != nil || value != "a dog" {
+		t.Errorf("记录格式版本后仍然转换了旧条目: %q, %v", value, err)
+	}
+	if _, ok := GetImageResult(ResultKey(ComputeHash("image"), "glm-4.5v", "")); !ok {
+		t.Error("再次启动后已转换的条目丢失")
+	}
+}
+
+// failingRange 遍历失败的缓存
+type failingRange struct {
+	Cache
+}
+
+func (failingRange) Range(func(key, value string) bool) error {
+	return errors.New("遍历失败")
+}
+
+func TestUpgrade(t *testing.T) {
+	c := NewMemory(0, 0)
+	imageHash := ComputeHash("image")
+	c.Set(imageHash, "a cat", 0)
+
+	// 转换失败时不记录格式版本，下次启动时重试
+	upgrade(failingRange{c}, "glm-4.5v")
+	if _, err := c.Get(schemaVersionKey); !errors.Is(err, ErrNotFound) {
+		t.Error("转换失败后记录了格式版本")
+	}
+
+	upgrade(c, "glm-4.5v")
+	if value, _ := c.Get(schemaVersionKey); value != strconv.Itoa(SchemaVersion) {
+		t.Errorf("格式版本 = %q，期望 %d", value, SchemaVersion)
+	}
+	if _, ok := readResult(c, variantKey(imageHash, "glm-4.5v", "")); !ok {
+		t.Error("旧条目未转换")
+	}
+
+	// 已记录格式版本时不再遍历
+	c.Set(ComputeHash("other"), "a dog", 0)
+	upgrade(failingRange{c}, "glm-4.5v")
+	upgrade(c, "glm-4.5v")
+	if value, _ := c.Get(ComputeHash("other")); value != "a dog" {
+		t.Error("记录格式版本后仍然转换了旧条目")
+	}
+}
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout Redis 连接、读写以及单次调用（包括等待连接）的超时，缓存不可用时尽快回退到直接识别
+const redisTimeout = 3 * time.Second
+
+// redisScanCount 每次 SCAN 返回的键数量提示
+const redisScanCount = 200
+
+// RedisCache 基于 Redis 协议服务的缓存，多个副本可以共享识别结果
+// 计数器组保存为不过期的 hash（counters:组），不受条目的有效期影响
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis 连接 Redis 并检查连通性，prefix 附加在所有键之前
+func NewRedis(addr string, password string, db int, prefix string) (*RedisCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     password,
+		DB:           db,
+		DialTimeout:  redisTimeout,
+		ReadTimeout:  redisTimeout,
+		WriteTimeout: redisTimeout,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接 Redis %s 失败: %w", addr, err)
+	}
+	return &RedisCache{client: client, prefix: prefix}, nil
+}
+
+// Get 读取 key 的值
+func (r *RedisCache) Get(key string) (string, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	value, err := r.client.Get(ctx, r.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// GetMulti 用一次 MGET 批量读取
+func (r *RedisCache) GetMulti(keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	results, err := r.client.MGet(ctx, r.keys(keys)...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range results {
+		// 不存在的键返回 nil
+		if s, ok := value.(string); ok {
+			values[keys[i]] = s
+		}
+	}
+	return values, nil
+}
+
+// Set 写入 key-value
+func (r *RedisCache) Set(key string, value string, ttl time.Duration) error {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+// Delete 用一次 DEL 删除 key
+func (r *RedisCache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	return r.client.Del(ctx, r.keys(keys)...).Err()
+}
+
+// TTL 返回 key 的剩余有效期
+func (r *RedisCache) TTL(key string) (time.Duration, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	ttl, err := r.client.PTTL(ctx, r.prefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Redis 对不存在的键返回 -2，对不过期的键返回 -1
+	switch {
+	case ttl == -2:
+		return 0, ErrNotFound
+	case ttl < 0:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Scan 用 SCAN 取一批键，再用一次流水线读取值和剩余有效期；游标为 SCAN 的游标
+func (r *RedisCache) Scan(prefix string, cursor string, count int) ([]Item, string, error) {
+	var start uint64
+	if cursor != "" {
+		var err error
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+		}
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	keys, next, err := r.client.Scan(ctx, start, r.prefix+prefix+"*", int64(count)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	nextCursor := ""
+	if next != 0 {
+		nextCursor = strconv.FormatUint(next, 10)
+	}
+	if len(keys) == 0 {
+		return nil, nextCursor, nil
+	}
+
+	pipe := r.client.Pipeline()
+	values := pipe.MGet(ctx, keys...)
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, "", err
+	}
+
+	items := make([]Item, 0, len(keys))
+	for i, value := range values.Val() {
+		// 扫描期间过期或被删除的键，以及计数器组的 hash 返回 nil
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		items = append(items, Item{
+			Key:   strings.TrimPrefix(keys[i], r.prefix),
+			Value: s,
+			TTL:   max(ttls[i].Val(), 0), // 不过期的键返回 -1
+		})
+	}
+	return items, nextCursor, nil
+}
+
+// Range 用 SCAN 遍历前缀下的所有键，分批读取值
+func (r *RedisCache) Range(fn func(key, value string) bool) error {
+	var cursor uint64
+	for {
+		keys, values, next, err := r.scanValues(cursor)
+		if err != nil {
+			return err
+		}
+		for i, value := range values {
+			// 扫描期间过期或被删除的键，以及计数器组的 hash 返回 nil
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if !fn(strings.TrimPrefix(keys[i], r.prefix), s) {
+				return nil
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// scanValues 用 SCAN 取一批键并读取它们的值
+func (r *RedisCache) scanValues(cursor uint64) ([]string, []any, uint64, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	keys, next, err := r.client.Scan(ctx, cursor, r.prefix+"*", redisScanCount).Result()
+	if err != nil || len(keys) == 0 {
+		return nil, nil, next, err
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return keys, values, next, nil
+}
+
+// IncrCounters 在一个事务中用 HINCRBY 累加计数器
+func (r *RedisCache) IncrCounters(group string, deltas map[string]int64) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	key := r.counterKey(group)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for name, delta := range deltas {
+			pipe.HIncrBy(ctx, key, name, delta)
+		}
+		return nil
+	})
+	return err
+}
+
+// Counters 用 HGETALL（整个组）或 HMGET 读取计数器
+func (r *RedisCache) Counters(group string, names ...string) (map[string]int64, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	values := make(map[string]int64)
+	if len(names) == 0 {
+		all, err := r.client.HGetAll(ctx, r.counterKey(group)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range all {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				values[name] = n
+			}
+		}
+		return values, nil
+	}
+
+	results, err := r.client.HMGet(ctx, r.counterKey(group), names...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range results {
+		// 不存在的计数器返回 nil
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			values[names[i]] = n
+		}
+	}
+	return values, nil
+}
+
+// DeleteCounters 用 HDEL 删除计数器
+func (r *RedisCache) DeleteCounters(group string, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	return r.client.HDel(ctx, r.counterKey(group), names...).Err()
+}
+
+// DeleteCounterGroup 删除计数器组的 hash
+func (r *RedisCache) DeleteCounterGroup(group string) error {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	return r.client.Del(ctx, r.counterKey(group)).Err()
+}
+
+// context 单次调用的上下文，限制包括等待连接在内的总耗时
+func (r *RedisCache) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), redisTimeout)
+}
+
+// counterKey 计数器组的 hash 的键
+func (r *RedisCache) counterKey(group string) string {
+	return r.prefix + counterKeyPrefix + group
+}
+
+// keys 为 key 加上前缀
+func (r *RedisCache) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	return prefixed
+}
+
+// Close 关闭连接
+func (r *RedisCache) Close() error {
+	return r.client.Close()
+}
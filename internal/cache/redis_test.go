@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	r, err := NewRedis(server.Addr(), "", 0, "glm:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Set("persistent", "1", 0)
+	r.Set("long", "2", time.Hour)
+	r.Set("precise", "3", 1500*time.Millisecond)
+
+	if value, err := server.Get("glm:long"); err != nil || value != "2" {
+		t.Errorf("键前缀未生效: %q, %v", value, err)
+	}
+	if got := server.TTL("glm:precise"); got != 1500*time.Millisecond {
+		t.Errorf("非整秒有效期写入为 %v，期望 1.5s", got)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		want    time.Duration
+		wantErr error
+	}{
+		{name: "不过期", key: "persistent", want: 0},
+		{name: "有效期", key: "long", want: time.Hour},
+		{name: "不存在", key: "missing", wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, err := r.TTL(tt.key)
+			if !errors.Is(err, tt.wantErr) || ttl != tt.want {
+				t.Errorf("TTL(%s) = %v, %v，期望 %v, %v", tt.key, ttl, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := r.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不存在的键 err = %v，期望 ErrNotFound", err)
+	}
+	if err := r.Delete("long"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get("long"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后 err = %v，期望 ErrNotFound", err)
+	}
+}
+
+func TestRedisCacheRange(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.Set("other:key", "x") // 其他前缀的键不遍历
+	r, err := NewRedis(server.Addr(), "", 0, "glm:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// 超过一批 SCAN 的数量，需要按游标继续遍历
+	const total = redisScanCount + 50
+	for i := 0; i < total; i++ {
+		r.Set(fmt.Sprintf("key%03d", i), strconv.Itoa(i), 0)
+	}
+	// 计数器组的 hash 不遍历
+	r.IncrCounters("stats", map[string]int64{"entries:0": 1})
+
+	seen := make(map[string]string)
+	if err := r.Range(func(key, value string) bool {
+		seen[key] = value
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != total || seen["key007"] != "7" {
+		t.Errorf("遍历到 %d 个键，期望 %d 个", len(seen), total)
+	}
+
+	count := 0
+	r.Range(func(key, value string) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("fn 返回 false 后仍继续遍历（%d 次）", count)
+	}
+}
+
+func TestRedisCountersHaveNoTTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	r, err := NewRedis(server.Addr(), "", 0, "glm:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.IncrCounters("stats", map[string]int64{"entries:1": 2, "bytes:1": 100}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.HGet("glm:counters:stats", "bytes:1"); got != "100" {
+		t.Errorf("计数器保存为 %q，期望 hash glm:counters:stats 中的 100", got)
+	}
+	if ttl := server.TTL("glm:counters:stats"); ttl != 0 {
+		t.Errorf("计数器组的有效期 = %v，期望不过期", ttl)
+	}
+}
+
+func TestNewRedisUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	if _, err := NewRedis(addr, "", 0, ""); err == nil {
+		t.Error("连接失败时期望返回错误")
+	}
+}
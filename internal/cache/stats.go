@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gophertool/tool/log"
+)
+
+// statsGroup 统计计数器组，计数器与条目分开保存，不会被淘汰或过期
+// 计数器按条目的过期时间分组（向上取整到整点）：entries:分组、bytes:分组、hits:分组，
+// 分组过期后不再计入统计，由 pruneCounters 删除，条目过期时不需要逐个扣减；不过期的条目属于分组 0
+const statsGroup = "stats"
+
+// 统计计数器的类型
+const (
+	statsEntries = "entries" // 条目数
+	statsBytes   = "bytes"   // 条目占用的字节数（键和值）
+	statsHits    = "hits"    // 分组的命中次数，用于找到需要删除的命中次数计数器组
+)
+
+// statsBucketSeconds 统计分组的时间粒度（秒）
+const statsBucketSeconds = int64(time.Hour / time.Second)
+
+// prunedBucket 最近一次删除过期计数器时所在的分组
+var prunedBucket atomic.Int64
+
+// statsBucket 返回剩余有效期为 ttl 的条目所属的统计分组
+func statsBucket(ttl time.Duration, now time.Time) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	expires := now.Add(ttl).Unix()
+	return (expires + statsBucketSeconds - 1) / statsBucketSeconds
+}
+
+// bucketExpired 分组中的条目是否已全部过期
+func bucketExpired(bucket int64, now time.Time) bool {
+	return bucket != 0 && bucket*statsBucketSeconds <= now.Unix()
+}
+
+// statsCounter 统计计数器的名称
+func statsCounter(kind string, bucket int64) string {
+	return kind + ":" + strconv.FormatInt(bucket, 10)
+}
+
+// parseStatsCounter 解析统计计数器的名称
+func parseStatsCounter(name string) (string, int64, bool) {
+	kind, suffix, ok := strings.Cut(name, ":")
+	if !ok {
+		return "", 0, false
+	}
+	bucket, err := strconv.ParseInt(suffix, 10, 64)
+	return kind, bucket, err == nil
+}
+
+// hitsGroup 分组中各识别结果的命中次数计数器组，计数器名称为条目的键
+func hitsGroup(bucket int64) string {
+	return "hits:" + strconv.FormatInt(bucket, 10)
+}
+
+// countEntry 将占用 size 字节、剩余有效期为 ttl 的识别结果计入（sign 为 1）或移出（sign 为 -1）统计
+// 计数器更新失败只记录日志，不影响缓存读写
+func countEntry(cache Cache, size int64, ttl time.Duration, sign int64) {
+	bucket := statsBucket(ttl, time.Now())
+	err := cache.IncrCounters(statsGroup, map[string]int64{
+		statsCounter(statsEntries, bucket): sign,
+		statsCounter(statsBytes, bucket):   sign * size,
+	})
+	if err != nil {
+		log.Warnf("更新图片缓存统计失败: %v", err)
+	}
+}
+
+// readStats 汇总未过期分组的计数器，返回条目数和字节数
+func readStats(cache Cache) (int, int64, error) {
+	counters, err := cache.Counters(statsGroup)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	var entries, bytes int64
+	for name, n := range counters {
+		kind, bucket, ok := parseStatsCounter(name)
+		if !ok || bucketExpired(bucket, now) {
+			continue
+		}
+		switch kind {
+		case statsEntries:
+			entries += n
+		case statsBytes:
+			bytes += n
+		}
+	}
+	// 删除的条目与写入时分到不同分组（有效期恰好跨过整点）时计数可能短暂为负
+	return int(max(entries, 0)), max(bytes, 0), nil
+}
+
+// pruneCounters 每个整点最多一次，删除已过期分组的统计计数器和命中次数计数器组
+func pruneCounters(cache Cache, now time.Time) {
+	current := now.Unix() / statsBucketSeconds
+	last := prunedBucket.Load()
+	if last >= current || !prunedBucket.CompareAndSwap(last, current) {
+		return
+	}
+
+	counters, err := cache.Counters(statsGroup)
+	if err != nil {
+		log.Warnf("读取图片缓存统计失败: %v", err)
+		return
+	}
+	var expired []string
+	for name := range counters {
+		kind, bucket, ok := parseStatsCounter(name)
+		if !ok || !bucketExpired(bucket, now) {
+			continue
+		}
+		if kind == statsHits {
+			if err := cache.DeleteCounterGroup(hitsGroup(bucket)); err != nil {
+				log.Warnf("删除过期的命中次数计数器失败: %v", err)
+				continue
+			}
+		}
+		expired = append(expired, name)
+	}
+	if err := cache.DeleteCounters(statsGroup, expired...); err != nil {
+		log.Warnf("删除过期的图片缓存统计失败: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"glm-tool/config"
+	"glm-tool/internal/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gophertool/tool/log"
+)
+
+// adminListDefaultLimit / adminListMaxLimit 缓存条目列表的默认和最大分页大小
+const (
+	adminListDefaultLimit = 100
+	adminListMaxLimit     = 1000
+)
+
+// AdminAuth 校验管理接口的令牌（Authorization: Bearer <ADMIN_TOKEN> 或 X-Admin-Token）
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Admin-Token")
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		expected := config.AppConfig.AdminToken
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			log.Warnf("管理接口鉴权失败: %s %s", c.Request.Method, c.Request.URL.Path)
+			abortAdminError(c, http.StatusUnauthorized, "authentication_error", "无效的管理令牌")
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListCacheEntries 按游标分页列出识别结果缓存，cursor 为上一页返回的 next_cursor
+func (h *Handler) ListCacheEntries(c *gin.Context) {
+	limit, err := queryInt(c, "limit", adminListDefaultLimit)
+	if err != nil || limit <= 0 || limit > adminListMaxLimit {
+		abortAdminError(c, http.StatusBadRequest, "invalid_request_error", "limit 必须是 1 到 1000 之间的整数")
+		return
+	}
+
+	entries, next, err := cache.ListEntries(c.Query("cursor"), limit)
+	if err != nil {
+		abortCacheError(c, err)
+		return
+	}
+
+	data := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, cacheEntryJSON(entry))
+	}
+	var nextCursor any
+	if next != "" {
+		nextCursor = next
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":      "list",
+		"data":        data,
+		"limit":       limit,
+		"has_more":    next != "",
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetCacheEntry 读取单个识别结果
+func (h *Handler) GetCacheEntry(c *gin.Context) {
+	entry, err := cache.GetEntry(c.Param("key"))
+	if err != nil {
+		abortCacheError(c, err)
+		return
+	}
+	data := cacheEntryJSON(entry)
+	data["description"] = entry.Result.Description
+	c.JSON(http.StatusOK, data)
+}
+
+// DeleteCacheEntry 删除图片哈希（或完整的键）对应的所有识别结果
+func (h *Handler) DeleteCacheEntry(c *gin.Context) {
+	key := c.Param("key")
+	deleted, err := cache.DeleteEntries(key)
+	if err != nil {
+		abortCacheError(c, err)
+		return
+	}
+	if deleted == 0 {
+		abortCacheError(c, cache.ErrNotFound)
+		return
+	}
+	log.Infof("管理接口删除了 %d 条图片缓存: %s", deleted, key)
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// PurgeCache 清空识别结果缓存，older_than（如 24h）只删除早于该时长之前创建的条目
+func (h *Handler) PurgeCache(c *gin.Context) {
+	var olderThan time.Duration
+	if value := c.Query("older_than"); value != "" {
+		var err error
+		olderThan, err = time.ParseDuration(value)
+		if err != nil || olderThan <= 0 {
+			abortAdminError(c, http.StatusBadRequest, "invalid_request_error", "older_than 必须是正的时长，例如 24h")
+			return
+		}
+	}
+
+	deleted, err := cache.PurgeEntries(olderThan)
+	if err != nil {
+		abortCacheError(c, err)
+		return
+	}
+	log.Infof("管理接口清理了 %d 条图片缓存 (older_than: %s)", deleted, olderThan)
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// CacheStats 返回缓存条目数量和命中率
+func (h *Handler) CacheStats(c *gin.Context) {
+	stats, err := cache.GetStats()
+	if err != nil {
+		abortCacheError(c, err)
+		return
+	}
+	hitRatio := 0.0
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		hitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"backend":   stats.Backend,
+		"entries":   stats.Entries,
+		"bytes":     stats.Bytes,
+		"hits":      stats.Hits,
+		"misses":    stats.Misses,
+		"hit_ratio": hitRatio,
+	})
+}
+
+// cacheEntryJSON 缓存条目的 JSON 表示
+func cacheEntryJSON(entry cache.Entry) gin.H {
+	result := entry.Result
+	data := gin.H{
+		"key":         entry.Key,
+		"hash":        entry.Hash,
+		"version":     result.Version,
+		"size":        entry.Size,
+		"ttl_seconds": int64(entry.TTL.Seconds()),
+		"hits":        entry.Hits,
+		"model":       result.Model,
+		"profile":     result.Profile,
+		"prompt_hash": result.PromptHash,
+		"usage": gin.H{
+			"prompt_tokens":     result.Usage.PromptTokens,
+			"completion_tokens": result.Usage.CompletionTokens,
+			"total_tokens":      result.Usage.TotalTokens,
+		},
+		"created_at": nil,
+	}
+	if !result.Created.IsZero() {
+		data["created_at"] = result.Created.Unix()
+	}
+	return data
+}
+
+// queryInt 读取整数查询参数，未设置时返回默认值
+func queryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// abortCacheError 将缓存操作错误转换为响应
+func abortCacheError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		abortAdminError(c, http.StatusNotFound, "not_found_error", "缓存条目不存在")
+	case errors.Is(err, cache.ErrInvalidCursor):
+		abortAdminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+	case errors.Is(err, cache.ErrNotInitialized):
+		abortAdminError(c, http.StatusServiceUnavailable, "server_error", err.Error())
+	default:
+		log.Warnf("缓存管理操作失败: %v", err)
+		abortAdminError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+}
+
+// abortAdminError 返回管理接口的错误响应
+func abortAdminError(c *gin.Context, status int, errType string, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
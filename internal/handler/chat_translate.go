@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+
+	"glm-tool/config"
+	"glm-tool/internal/debuglog"
+	"glm-tool/internal/translate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gophertool/tool/log"
+)
+
+// chatCompletionsViaAnthropic 将 OpenAI 请求转换为 Anthropic 格式，转发到 Anthropic 上游后再转换回 OpenAI 格式
+func (h *Handler) chatCompletionsViaAnthropic(c *gin.Context, requestData map[string]any, authHeader string) {
+	anthropicReq, err := translate.OpenAIToAnthropicRequest(requestData, config.AppConfig.AnthropicDefaultMaxTokens)
+	if err != nil {
+		log.Warnf("转换 OpenAI 请求失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	log.Infof("OpenAI 请求通过 Anthropic 上游处理 (model: %v)", requestData["model"])
+
+	anthropicResp, err := h.proxy.ForwardAnthropicRequest(c.Request.Context(), anthropicReq, authHeader)
+
+	var respData map[string]any
+	if err == nil {
+		model, _ := requestData["model"].(string)
+		respData = translate.AnthropicToOpenAIResponse(anthropicResp, model)
+	}
+
+	// 记录 debug 日志
+	debuglog.LogRequest(requestData, respData, err)
+
+	if err != nil {
+		log.Warnf("转发请求失败: %v", err)
+		writeOpenAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, respData)
+}
+
+// messagesViaOpenAI 将 Anthropic 请求转换为 OpenAI 格式，转发到 OpenAI 上游后再转换回 Anthropic 格式
+func (h *Handler) messagesViaOpenAI(c *gin.Context, requestData map[string]any, authHeader string) {
+	openaiReq, err := translate.AnthropicToOpenAIRequest(requestData)
+	if err != nil {
+		log.Warnf("转换 Anthropic 请求失败: %v", err)
+		abortAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	log.Infof("Anthropic 请求通过 OpenAI 上游处理 (model: %v)", requestData["model"])
+
+	openaiResp, err := h.proxy.ForwardRequest(c.Request.Context(), openaiReq, bearerAuth(authHeader))
+
+	var respData map[string]any
+	if err == nil {
+		model, _ := requestData["model"].(string)
+		respData = translate.OpenAIToAnthropicResponse(openaiResp, model)
+	}
+
+	// 记录 debug 日志
+	debuglog.LogRequest(requestData, respData, err)
+
+	if err != nil {
+		log.Warnf("转发 Anthropic 请求失败: %v", err)
+		writeAnthropicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, respData)
+}
+
+// chatCompletionsStreamViaAnthropic 将 OpenAI 流式请求转发到 Anthropic 上游，并把事件流转换为 OpenAI chunk
+func (h *Handler) chatCompletionsStreamViaAnthropic(c *gin.Context, requestData map[string]any, authHeader string) {
+	anthropicReq, err := translate.OpenAIToAnthropicRequest(requestData, config.AppConfig.AnthropicDefaultMaxTokens)
+	if err != nil {
+		log.Warnf("转换 OpenAI 请求失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	log.Infof("OpenAI 流式请求通过 Anthropic 上游处理 (model: %v)", requestData["model"])
+
+	includeUsage := false
+	if options, ok := requestData["stream_options"].(map[string]any); ok {
+		includeUsage, _ = options["include_usage"].(bool)
+	}
+	model, _ := requestData["model"].(string)
+	converter := translate.NewAnthropicToOpenAIStream(model, includeUsage)
+
+	if err := h.proxy.ForwardAnthropicStreamRequestWithConverter(c, anthropicReq, authHeader, converter); err != nil {
+		log.Warnf("转发流式请求失败: %v", err)
+		writeOpenAIError(c, err)
+	}
+}
+
+// messagesStreamViaOpenAI 将 Anthropic 流式请求转发到 OpenAI 上游，并把 chunk 流转换为 Anthropic 事件
+func (h *Handler) messagesStreamViaOpenAI(c *gin.Context, requestData map[string]any, authHeader string) {
+	openaiReq, err := translate.AnthropicToOpenAIRequest(requestData)
+	if err != nil {
+		log.Warnf("转换 Anthropic 请求失败: %v", err)
+		abortAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	log.Infof("Anthropic 流式请求通过 OpenAI 上游处理 (model: %v)", requestData["model"])
+
+	model, _ := requestData["model"].(string)
+	converter := translate.NewOpenAIToAnthropicStream(model)
+
+	if err := h.proxy.ForwardStreamRequestWithConverter(c, openaiReq, bearerAuth(authHeader), converter); err != nil {
+		log.Warnf("转发 Anthropic 流式请求失败: %v", err)
+		writeAnthropicError(c, err)
+	}
+}
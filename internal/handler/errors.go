@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"glm-tool/internal/proxy"
+	"glm-tool/internal/translate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forwardedErrorHeaders 需要透传给客户端的上游错误响应头
+var forwardedErrorHeaders = []string{"Retry-After", "X-Request-Id", "Request-Id"}
+
+// forwardedErrorHeaderPrefixes 需要透传的限流相关响应头前缀
+var forwardedErrorHeaderPrefixes = []string{"X-Ratelimit-", "Anthropic-Ratelimit-"}
+
+// upstreamFailure 将转发错误归一化为状态码、错误信息和上游错误码
+// 上游错误保留原状态码；等待上游超时返回 504，本地处理失败返回 500，其他（连接失败等）返回 502
+func upstreamFailure(err error) (status int, message string, code any, upstreamErr *proxy.UpstreamError) {
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode, upstreamErr.Message(), upstreamErr.Code(), upstreamErr
+	}
+	var localErr *proxy.LocalError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &localErr):
+		return http.StatusInternalServerError, err.Error(), nil, nil
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout, err.Error(), nil, nil
+	}
+	return http.StatusBadGateway, err.Error(), nil, nil
+}
+
+// copyUpstreamErrorHeaders 透传 Retry-After 和限流相关的响应头
+func copyUpstreamErrorHeaders(c *gin.Context, upstreamErr *proxy.UpstreamError) {
+	if upstreamErr == nil {
+		return
+	}
+	for name, values := range upstreamErr.Header {
+		canonical := http.CanonicalHeaderKey(name)
+		forward := false
+		for _, h := range forwardedErrorHeaders {
+			if canonical == h {
+				forward = true
+			}
+		}
+		for _, prefix := range forwardedErrorHeaderPrefixes {
+			if strings.HasPrefix(canonical, prefix) {
+				forward = true
+			}
+		}
+		if forward && len(values) > 0 {
+			c.Header(canonical, values[0])
+		}
+	}
+}
+
+// clientGone 客户端已断开（或服务正在关闭）时无需再返回错误
+func clientGone(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.Canceled)
+}
+
+// isContextLengthError 判断是否为上下文超长错误
+func isContextLengthError(message string) bool {
+	lower := strings.ToLower(message)
+	return strings.Contains(lower, "context length") ||
+		strings.Contains(lower, "context_length") ||
+		strings.Contains(lower, "maximum context") ||
+		strings.Contains(lower, "prompt is too long") ||
+		strings.Contains(lower, "too many tokens")
+}
+
+// openAIErrorType 按状态码映射 OpenAI 错误类型
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "server_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+// anthropicErrorType 按状态码映射 Anthropic 错误类型
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	}
+	if status >= 500 {
+		return "api_error"
+	}
+	return "invalid_request_error"
+}
+
+// writeOpenAIError 以 OpenAI 错误格式返回转发错误，保留上游状态码
+// 流式响应已经开始时，以 SSE data 事件的形式发送错误
+func writeOpenAIError(c *gin.Context, err error) {
+	if clientGone(c) {
+		return
+	}
+	status, message, code, upstreamErr := upstreamFailure(err)
+
+	errObj := gin.H{
+		"message": message,
+		"type":    openAIErrorType(status),
+		"param":   nil,
+		"code":    code,
+	}
+	if status == http.StatusBadRequest && isContextLengthError(message) {
+		errObj["code"] = "context_length_exceeded"
+	}
+
+	if c.Writer.Written() {
+		payload, _ := json.Marshal(gin.H{"error": errObj})
+		fmt.Fprintf(c.Writer, "data: %s\n\n", payload)
+		c.Writer.Flush()
+		return
+	}
+
+	copyUpstreamErrorHeaders(c, upstreamErr)
+	c.JSON(status, gin.H{"error": errObj})
+}
+
+// writeResponsesError 以 OpenAI 错误格式返回 Responses 请求的转发错误，保留上游状态码
+// 流式响应已经开始时，以 response.failed 事件结束流（Responses 客户端不识别 chat 格式的错误事件）
+func writeResponsesError(c *gin.Context, err error, stream *translate.ChatToResponsesStream) {
+	if clientGone(c) {
+		return
+	}
+	if !c.Writer.Written() {
+		writeOpenAIError(c, err)
+		return
+	}
+	status, message, _, _ := upstreamFailure(err)
+	c.Writer.Write(stream.Fail(openAIErrorType(status), message))
+	c.Writer.Flush()
+}
+
+// writeAnthropicError 以 Anthropic 错误格式返回转发错误，保留上游状态码
+// 流式响应已经开始时，以 SSE error 事件的形式发送错误
+func writeAnthropicError(c *gin.Context, err error) {
+	if clientGone(c) {
+		return
+	}
+	status, message, _, upstreamErr := upstreamFailure(err)
+
+	body := gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    anthropicErrorType(status),
+			"message": message,
+		},
+	}
+
+	if c.Writer.Written() {
+		payload, _ := json.Marshal(body)
+		fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", payload)
+		c.Writer.Flush()
+		return
+	}
+
+	copyUpstreamErrorHeaders(c, upstreamErr)
+	c.JSON(status, body)
+}
+
+// abortAnthropicError 以 Anthropic 错误格式返回本地产生的错误（请求格式、鉴权等）
+func abortAnthropicError(c *gin.Context, status int, errorType, message string) {
+	c.JSON(status, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
+
+// abortImageRecognitionError 以 OpenAI 错误格式返回图片识别失败（失败策略为 fail）
+func abortImageRecognitionError(c *gin.Context, err *ImageRecognitionError) {
+	c.JSON(http.StatusBadGateway, gin.H{
+		"error": gin.H{
+			"message": err.Error(),
+			"type":    "server_error",
+			"param":   nil,
+			"code":    "image_recognition_failed",
+		},
+	})
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+
+	"glm-tool/internal/proxy"
+	"glm-tool/internal/translate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutError 模拟 http.Client 超时返回的 net.Error
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "Client.Timeout exceeded while awaiting headers" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestUpstreamFailure(t *testing.T) {
+	upstream := &proxy.UpstreamError{
+		StatusCode: http.StatusTooManyRequests,
+		Body:       []byte(`{"error":{"message":"rate limited","code":"1302"}}`),
+	}
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+		wantCode    any
+	}{
+		{name: "上游错误", err: fmt.Errorf("转发失败: %w", upstream), wantStatus: http.StatusTooManyRequests, wantMessage: "rate limited", wantCode: "1302"},
+		{name: "连接失败", err: fmt.Errorf("发送请求失败: %w", syscall.ECONNREFUSED), wantStatus: http.StatusBadGateway},
+		{name: "等待上游超时", err: fmt.Errorf("发送请求失败: %w", context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout},
+		{name: "客户端超时", err: fmt.Errorf("发送请求失败: %w", &url.Error{Op: "Post", URL: "http://upstream", Err: timeoutError{}}), wantStatus: http.StatusGatewayTimeout},
+		{name: "本地错误", err: fmt.Errorf("发送请求失败: %w", &proxy.LocalError{Err: errors.New("序列化请求失败")}), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message, code, _ := upstreamFailure(tt.err)
+			if status != tt.wantStatus {
+				t.Errorf("状态码 = %d，期望 %d", status, tt.wantStatus)
+			}
+			if tt.wantMessage != "" && message != tt.wantMessage {
+				t.Errorf("错误信息 = %q，期望 %q", message, tt.wantMessage)
+			}
+			if code != tt.wantCode {
+				t.Errorf("错误码 = %v，期望 %v", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	tests := []struct {
+		status        int
+		wantOpenAI    string
+		wantAnthropic string
+	}{
+		{http.StatusBadRequest, "invalid_request_error", "invalid_request_error"},
+		{http.StatusUnauthorized, "authentication_error", "authentication_error"},
+		{http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error"},
+		{http.StatusInternalServerError, "server_error", "api_error"},
+		{http.StatusServiceUnavailable, "server_error", "overloaded_error"},
+		{http.StatusGatewayTimeout, "server_error", "api_error"},
+	}
+	for _, tt := range tests {
+		if got := openAIErrorType(tt.status); got != tt.wantOpenAI {
+			t.Errorf("openAIErrorType(%d) = %s，期望 %s", tt.status, got, tt.wantOpenAI)
+		}
+		if got := anthropicErrorType(tt.status); got != tt.wantAnthropic {
+			t.Errorf("anthropicErrorType(%d) = %s，期望 %s", tt.status, got, tt.wantAnthropic)
+		}
+	}
+}
+
+func TestWriteResponsesError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstreamErr := fmt.Errorf("读取流式响应失败: %w", &proxy.UpstreamError{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       []byte(`{"error":{"message":"overloaded"}}`),
+	})
+
+	tests := []struct {
+		name       string
+		started    bool // 流式响应是否已经开始
+		wantStatus int
+		want       []string
+		notWant    string
+	}{
+		{name: "响应尚未开始", wantStatus: http.StatusServiceUnavailable, want: []string{`"error"`, "overloaded"}, notWant: "event:"},
+		{name: "流式响应已开始", started: true, wantStatus: http.StatusOK, want: []string{"event: response.failed", `"code":"server_error"`, "overloaded"}, notWant: `data: {"error"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+
+			stream := translate.NewChatToResponsesStream("resp_test", map[string]any{"model": "gpt-test"}, nil)
+			if tt.started {
+				c.Writer.Write(stream.ConvertLine([]byte(`data: {"choices":[{"index":0,"delta":{"content":"你好"}}]}`)))
+			}
+			writeResponsesError(c, upstreamErr, stream)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("状态码 = %d，期望 %d", recorder.Code, tt.wantStatus)
+			}
+			body := recorder.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("响应中缺少 %q: %s", want, body)
+				}
+			}
+			if strings.Contains(body, tt.notWant) {
+				t.Errorf("响应中不应包含 %q: %s", tt.notWant, body)
+			}
+		})
+	}
+}
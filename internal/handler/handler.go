@@ -1,22 +1,31 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"glm-tool/config"
 	"glm-tool/internal/debuglog"
 	"glm-tool/internal/proxy"
+	"glm-tool/internal/responsestore"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gophertool/tool/log"
 )
 
 type Handler struct {
-	proxy *proxy.Proxy
+	proxy     *proxy.Proxy
+	responses *responsestore.Store
 }
 
 func NewHandler() *Handler {
 	return &Handler{
 		proxy: proxy.NewProxy(),
+		responses: responsestore.New(
+			config.AppConfig.ResponsesStoreMaxEntries,
+			time.Duration(config.AppConfig.ResponsesStoreTTLHours)*time.Hour,
+		),
 	}
 }
 
@@ -49,8 +58,35 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 
 	// log.Printf("收到请求: %v", requestData)
 
+	imageOptions, err := imageProcessOptions(c, requestData)
+	if err != nil {
+		log.Warnf("图片识别配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
 	// [调试中间件] 识别图片并转换为文本（仅在 DEBUG 模式下生效）
-	if err := ProcessImageToText(requestData, authHeader); err != nil {
+	// 目标模型支持图片输入时图片原样转发（PDF 文档仍然展开），只为纯文本模型识别图片
+	model, _ := requestData["model"].(string)
+	imageOptions.SkipImages = supportsImageInput(model)
+	imageStats, err := ProcessImageToText(c.Request.Context(), requestData, authHeader, imageOptions)
+	setImageStatsHeader(c, imageStats)
+	if err != nil {
+		if clientGone(c) {
+			log.Infof("客户端已断开，取消请求")
+			return
+		}
+		var recErr *ImageRecognitionError
+		if errors.As(err, &recErr) {
+			log.Warnf("图片识别失败，按策略返回错误: %v", err)
+			abortImageRecognitionError(c, recErr)
+			return
+		}
 		log.Warnf("图片处理失败: %v", err)
 	}
 
@@ -60,36 +96,34 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 		isStream = true
 	}
 
-	if isStream {
+	viaAnthropic := useAnthropicForChat(model)
+
+	if isStream && viaAnthropic {
+		// 流式响应：转换为 Anthropic 格式处理
+		h.chatCompletionsStreamViaAnthropic(c, requestData, authHeader)
+	} else if isStream {
 		// 流式响应：直接透传
 		log.Infof("处理流式请求")
 		err := h.proxy.ForwardStreamRequest(c, requestData, authHeader)
 		if err != nil {
 			log.Warnf("转发流式请求失败: %v", err)
-			// 流式响应错误时，尝试发送错误消息
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": gin.H{
-					"message": err.Error(),
-					"type":    "proxy_error",
-				},
-			})
+			// 流式响应尚未开始时返回错误响应，已开始时以 SSE 事件发送错误
+			writeOpenAIError(c, err)
 		}
 		// 流式请求不记录 debug 日志（内容太大）
+	} else if viaAnthropic {
+		// 非流式响应：转换为 Anthropic 格式处理
+		h.chatCompletionsViaAnthropic(c, requestData, authHeader)
 	} else {
 		// 非流式响应：正常处理
-		respData, err := h.proxy.ForwardRequest(requestData, authHeader)
+		respData, err := h.proxy.ForwardRequest(c.Request.Context(), requestData, authHeader)
 
 		// 记录 debug 日志
 		debuglog.LogRequest(requestData, respData, err)
 
 		if err != nil {
 			log.Warnf("转发请求失败: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": gin.H{
-					"message": err.Error(),
-					"type":    "proxy_error",
-				},
-			})
+			writeOpenAIError(c, err)
 			return
 		}
 
@@ -120,19 +154,14 @@ func (h *Handler) ListModels(c *gin.Context) {
 
 	log.Infof("收到 models 列表请求")
 
-	respData, err := h.proxy.ForwardGetRequest("models", authHeader)
+	respData, err := h.proxy.ForwardGetRequest(c.Request.Context(), "models", authHeader)
 
 	// 记录 debug 日志
 	debuglog.LogRequest(nil, respData, err)
 
 	if err != nil {
 		log.Warnf("转发请求失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "proxy_error",
-			},
-		})
+		writeOpenAIError(c, err)
 		return
 	}
 
@@ -144,12 +173,7 @@ func (h *Handler) AnthropicMessages(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&requestData); err != nil {
 		log.Warnf("解析 Anthropic 请求失败: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "无效的请求格式",
-				"type":    "invalid_request_error",
-			},
-		})
+		abortAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "无效的请求格式")
 		return
 	}
 
@@ -165,17 +189,34 @@ func (h *Handler) AnthropicMessages(c *gin.Context) {
 
 	if authHeader == "" {
 		log.Warnf("缺少 Authorization 或 x-api-key header")
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": "缺少 API Key",
-				"type":    "authentication_error",
-			},
-		})
+		abortAnthropicError(c, http.StatusUnauthorized, "authentication_error", "缺少 API Key")
+		return
+	}
+
+	imageOptions, err := imageProcessOptions(c, requestData)
+	if err != nil {
+		log.Warnf("图片识别配置无效: %v", err)
+		abortAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
 
 	// [调试中间件] 识别图片并转换为文本（仅在 DEBUG 模式下生效）
-	if err := ProcessImageToTextForAnthropic(requestData, authHeader); err != nil {
+	// 目标模型支持图片输入时图片原样转发（PDF 文档仍然展开），只为纯文本模型识别图片
+	model, _ := requestData["model"].(string)
+	imageOptions.SkipImages = supportsImageInput(model)
+	imageStats, err := ProcessImageToTextForAnthropic(c.Request.Context(), requestData, authHeader, imageOptions)
+	setImageStatsHeader(c, imageStats)
+	if err != nil {
+		if clientGone(c) {
+			log.Infof("客户端已断开，取消请求")
+			return
+		}
+		var recErr *ImageRecognitionError
+		if errors.As(err, &recErr) {
+			log.Warnf("图片识别失败，按策略返回错误: %v", err)
+			abortAnthropicError(c, http.StatusBadGateway, "api_error", recErr.Error())
+			return
+		}
 		log.Warnf("Anthropic 图片处理失败: %v", err)
 	}
 
@@ -185,35 +226,33 @@ func (h *Handler) AnthropicMessages(c *gin.Context) {
 		isStream = true
 	}
 
-	if isStream {
+	viaOpenAI := useOpenAIForMessages(model)
+
+	if isStream && viaOpenAI {
+		// 流式响应：转换为 OpenAI 格式处理
+		h.messagesStreamViaOpenAI(c, requestData, authHeader)
+	} else if isStream {
 		// 流式响应：直接透传
 		log.Infof("处理 Anthropic 流式请求")
 		err := h.proxy.ForwardAnthropicStreamRequest(c, requestData, authHeader)
 		if err != nil {
 			log.Warnf("转发 Anthropic 流式请求失败: %v", err)
-			// 流式响应错误时，尝试发送错误消息
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": gin.H{
-					"message": err.Error(),
-					"type":    "proxy_error",
-				},
-			})
+			// 流式响应尚未开始时返回错误响应，已开始时以 SSE 事件发送错误
+			writeAnthropicError(c, err)
 		}
+	} else if viaOpenAI {
+		// 非流式响应：转换为 OpenAI 格式处理
+		h.messagesViaOpenAI(c, requestData, authHeader)
 	} else {
 		// 非流式响应：正常处理
-		respData, err := h.proxy.ForwardAnthropicRequest(requestData, authHeader)
+		respData, err := h.proxy.ForwardAnthropicRequest(c.Request.Context(), requestData, authHeader)
 
 		// 记录 debug 日志
 		debuglog.LogRequest(requestData, respData, err)
 
 		if err != nil {
 			log.Warnf("转发 Anthropic 请求失败: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": gin.H{
-					"message": err.Error(),
-					"type":    "proxy_error",
-				},
-			})
+			writeAnthropicError(c, err)
 			return
 		}
 
@@ -227,12 +266,7 @@ func (h *Handler) AnthropicCountTokens(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&requestData); err != nil {
 		log.Warnf("解析 Anthropic Count Tokens 请求失败: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "无效的请求格式",
-				"type":    "invalid_request_error",
-			},
-		})
+		abortAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "无效的请求格式")
 		return
 	}
 
@@ -248,29 +282,19 @@ func (h *Handler) AnthropicCountTokens(c *gin.Context) {
 
 	if authHeader == "" {
 		log.Warnf("缺少 Authorization 或 x-api-key header")
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": "缺少 API Key",
-				"type":    "authentication_error",
-			},
-		})
+		abortAnthropicError(c, http.StatusUnauthorized, "authentication_error", "缺少 API Key")
 		return
 	}
 
 	// 转发请求
-	respData, err := h.proxy.ForwardAnthropicCountTokensRequest(requestData, authHeader)
+	respData, err := h.proxy.ForwardAnthropicCountTokensRequest(c.Request.Context(), requestData, authHeader)
 
 	// 记录 debug 日志
 	debuglog.LogRequest(requestData, respData, err)
 
 	if err != nil {
 		log.Warnf("转发 Anthropic Count Tokens 请求失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "proxy_error",
-			},
-		})
+		writeAnthropicError(c, err)
 		return
 	}
 
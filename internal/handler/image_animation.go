@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"glm-tool/config"
+	"glm-tool/internal/cache"
+	"glm-tool/internal/imageutil"
+	"glm-tool/internal/vision"
+
+	"github.com/gophertool/tool/log"
+)
+
+// extractAnimation 按配置从动画图片（GIF/APNG/WebP）中抽帧，静态图片或未开启抽帧时返回 nil
+func extractAnimation(t ImageTask) *imageutil.Animation {
+	if config.AppConfig.ImageAnimationMaxFrames <= 1 {
+		return nil
+	}
+	if t.MediaType != imageutil.MediaTypeGIF && t.MediaType != imageutil.MediaTypePNG && t.MediaType != imageutil.MediaTypeWebP {
+		return nil
+	}
+
+	data, err := imageutil.DecodeBase64(t.Base64Data)
+	if err != nil {
+		return nil
+	}
+	animation, err := imageutil.ExtractFrames(data, t.MediaType, imageutil.FrameOptions{
+		Sampling:  config.AppConfig.ImageAnimationSampling,
+		Interval:  config.AppConfig.ImageAnimationFrameInterval,
+		MaxFrames: config.AppConfig.ImageAnimationMaxFrames,
+	})
+	if err != nil {
+		// 无法逐帧解析时按静态图片（第一帧）识别
+		log.Warnf("动画抽帧失败，按静态图片识别（哈希: %s, ID: %s）: %v", t.ImageHash[:16], t.ImageID, err)
+		return nil
+	}
+	return animation
+}
+
+// recognizeAnimation 逐帧识别抽取的帧（每帧单独缓存），按时间顺序合并为一段描述
+func recognizeAnimation(ctx context.Context, t ImageTask, animation *imageutil.Animation, apiKey string, visionConfig vision.VisionConfig) (string, error) {
+	log.Infof("动画图片共 %d 帧，抽取 %d 帧识别（哈希: %s, ID: %s）", animation.TotalFrames, len(animation.Frames), t.ImageHash[:16], t.ImageID)
+
+	texts := make([]string, len(animation.Frames))
+	errs := make([]error, len(animation.Frames))
+	var wg sync.WaitGroup
+	for i, frame := range animation.Frames {
+		data, err := imageutil.EncodeFrame(frame)
+		if err != nil {
+			return "", fmt.Errorf("第 %d 帧%w", frame.Index+1, err)
+		}
+
+		frameTask := t
+		frameTask.Base64Data = base64.StdEncoding.EncodeToString(data)
+		frameTask.ImageHash = cache.ComputeHash(frameTask.Base64Data)
+		frameTask.MediaType = imageutil.MediaTypePNG
+		frameTask.ImageID = fmt.Sprintf("%s/%d", t.ImageID, frame.Index+1)
+
+		wg.Add(1)
+		go func(i int, frameTask ImageTask) {
+			defer wg.Done()
+			// 每一帧与普通图片一样按哈希和提示词缓存，相同的帧只识别一次
+			key := frameTask.Prompt.resultKey(frameTask.ImageHash)
+			texts[i], _, errs[i] = recognitionFlights.do(ctx, key, func(ctx context.Context) (string, error) {
+				return recognizeImage(ctx, frameTask, key, apiKey, visionConfig)
+			})
+		}(i, frameTask)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return "", fmt.Errorf("第 %d 帧识别失败: %w", animation.Frames[i].Index+1, err)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "这是一段动画（共 %d 帧，时长 %.1f 秒），以下按时间顺序给出其中 %d 帧的内容：",
+		animation.TotalFrames, animation.Duration.Seconds(), len(animation.Frames))
+	for i, frame := range animation.Frames {
+		fmt.Fprintf(&b, "\n\n【第 %d 帧，%.1f 秒】\n%s", frame.Index+1, frame.Timestamp.Seconds(), texts[i])
+	}
+	return b.String(), nil
+}
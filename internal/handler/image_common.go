@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 
+	"glm-tool/config"
 	"glm-tool/internal/cache"
+	"glm-tool/internal/imagefetch"
+	"glm-tool/internal/imageutil"
 	"glm-tool/internal/vision"
 
 	"github.com/gophertool/tool/log"
@@ -15,17 +21,25 @@ import (
 
 // ImageTask 通用图片识别任务
 type ImageTask struct {
-	ContentIndex int    // content 数组的索引
-	Base64Data   string // 图片 base64 数据
-	ImageHash    string // 图片哈希值
-	ImageID      string // 图片 ID（自动生成）
+	SlotIndex    int         // 所在 content 数组的序号（消息或嵌套的 tool_result 等内容）
+	ContentIndex int         // content 数组的索引
+	Base64Data   string      // 图片 base64 数据
+	ImageHash    string      // 图片哈希值
+	ImageID      string      // 图片 ID（自动生成）
+	URL          string      // 远程图片地址（http/https），识别前下载并填充 Base64Data 和 ImageHash
+	MediaType    string      // 按文件头识别的图片类型（为空表示未知）
+	Prompt       imagePrompt // 识别提示词模板和上下文，参与缓存键计算
+	Priority     int         // 调度优先级（最新一条用户消息中的图片优先）
 }
 
 // ImageResult 通用图片识别结果
 type ImageResult struct {
+	SlotIndex    int    // 所在 content 数组的序号
 	ContentIndex int    // content 数组的索引
 	Text         string // 识别结果文本
 	Success      bool   // 是否成功
+	Cached       bool   // 是否使用了缓存结果
+	Error        string // 失败原因
 }
 
 // ImageReference 图片引用信息
@@ -34,6 +48,18 @@ type ImageReference struct {
 	Number  int    // 编号（从文本中提取）
 }
 
+// latestUserMessageIndex 返回最后一条用户消息的索引，没有时返回 -1
+func latestUserMessageIndex(messages []interface{}) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if message, ok := messages[i].(map[string]interface{}); ok {
+			if role, _ := message["role"].(string); role == "user" {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // extractImageReferences 从 content 中提取所有图片引用（按出现顺序）
 // 返回：图片位置 -> 引用信息的映射
 // msgIdx: 消息索引，用于生成 ID
@@ -292,80 +318,194 @@ func processImageWithCache(imageHash string, ref ImageReference, prefix string)
 	return false, ""
 }
 
-// recognizeImagesConcurrently 并发识别多张图片
-func recognizeImagesConcurrently(tasks []ImageTask, apiKey string, apiType string) []ImageResult {
+// recognizeImagesConcurrently 并发识别多张图片，结果按任务顺序返回；ctx 取消时所有识别请求随之中止
+func recognizeImagesConcurrently(ctx context.Context, tasks []ImageTask, apiKey string, apiType string, visionConfig vision.VisionConfig) []ImageResult {
 	if len(tasks) == 0 {
 		return nil
 	}
 
-	log.Infof("%s检测到 %d 张图片需要识别，开始并发处理...", apiType, len(tasks))
+	log.Infof("%s检测到 %d 张图片需要识别（模型: %s），开始并发处理...", apiType, len(tasks), visionConfig.Model)
 
 	var wg sync.WaitGroup
-	resultChan := make(chan ImageResult, len(tasks))
+	results := make([]ImageResult, len(tasks))
 
-	for _, task := range tasks {
+	for i, task := range tasks {
 		wg.Add(1)
-		go func(t ImageTask) {
+		go func(i int, t ImageTask) {
 			defer wg.Done()
 
-			log.Infof("开始识别图片（哈希: %s, ID: %s）...", t.ImageHash[:16], t.ImageID)
-
-			// 构建识别请求
-			visionReq := vision.ImageAnalysisRequest{
-				ImageBase64: t.Base64Data,
-				Prompt:      "", // 使用默认 prompt
-				APIKey:      apiKey,
+			// 远程图片先下载，再按内容哈希检查缓存
+			if t.URL != "" {
+				cached, hit, err := resolveRemoteImage(ctx, &t)
+				if err != nil {
+					log.Warnf("下载图片失败（ID: %s, URL: %s）: %v", t.ImageID, t.URL, err)
+					results[i] = ImageResult{
+						ContentIndex: t.ContentIndex,
+						SlotIndex:    t.SlotIndex,
+						Success:      false,
+						Error:        fmt.Sprintf("下载图片失败: %v", err),
+					}
+					return
+				}
+				if hit {
+					log.Infof("使用缓存的图片识别结果（哈希: %s, ID: %s, URL: %s）", t.ImageHash[:16], t.ImageID, t.URL)
+					results[i] = ImageResult{
+						ContentIndex: t.ContentIndex,
+						SlotIndex:    t.SlotIndex,
+						Text:         cached,
+						Success:      true,
+						Cached:       true,
+					}
+					return
+				}
 			}
 
-			// 调用识别
-			result, err := vision.AnalyzeImage(visionReq)
+			// 相同图片和提示词的识别（包括其他请求中的）只调用一次视觉模型
+			key := t.Prompt.resultKey(t.ImageHash)
+			text, shared, err := recognitionFlights.do(ctx, key, func(ctx context.Context) (string, error) {
+				return recognizeImage(ctx, t, key, apiKey, visionConfig)
+			})
 			if err != nil {
 				log.Warnf("图片识别失败（哈希: %s, ID: %s）: %v", t.ImageHash[:16], t.ImageID, err)
-				resultChan <- ImageResult{
+				results[i] = ImageResult{
 					ContentIndex: t.ContentIndex,
+					SlotIndex:    t.SlotIndex,
 					Success:      false,
+					Error:        err.Error(),
 				}
 				return
 			}
-
-			if !result.Success {
-				log.Warnf("图片识别失败（哈希: %s, ID: %s）: %s", t.ImageHash[:16], t.ImageID, result.Error)
-				resultChan <- ImageResult{
-					ContentIndex: t.ContentIndex,
-					Success:      false,
-				}
-				return
+			if shared {
+				log.Infof("复用进行中的相同图片识别结果（哈希: %s, ID: %s）", t.ImageHash[:16], t.ImageID)
 			}
 
-			// 识别成功
-			log.Infof("图片识别成功（哈希: %s, ID: %s），转换为文本", t.ImageHash[:16], t.ImageID)
-
-			// 保存到缓存
-			cache.SetImageResult(t.ImageHash, result.Data)
-			log.Infof("图片识别结果已缓存（哈希: %s, ID: %s）", t.ImageHash[:16], t.ImageID)
-
 			// 构建带 ID 的识别结果（前缀在外部构建）
-			resultChan <- ImageResult{
+			results[i] = ImageResult{
 				ContentIndex: t.ContentIndex,
-				Text:         result.Data,
+				SlotIndex:    t.SlotIndex,
+				Text:         text,
 				Success:      true,
 			}
-		}(task)
+		}(i, task)
 	}
 
 	// 等待所有识别完成
 	wg.Wait()
-	close(resultChan)
 
 	log.Infof("%s所有图片识别完成", apiType)
+	return results
+}
 
-	// 收集结果
-	var results []ImageResult
-	for result := range resultChan {
-		results = append(results, result)
+// recognizeImage 调用视觉模型识别一张图片并缓存结果，key 为结果缓存键
+func recognizeImage(ctx context.Context, t ImageTask, key string, apiKey string, visionConfig vision.VisionConfig) (string, error) {
+	// 等待期间其他请求可能已完成识别（收集任务时已计入未命中，这里不重复统计）
+	if cached, found := cache.PeekImageResult(key); found {
+		log.Infof("使用缓存的图片识别结果（哈希: %s, ID: %s）", t.ImageHash[:16], t.ImageID)
+		return cached, nil
 	}
 
-	return results
+	// 动画图片抽帧后逐帧识别，合并为一段按时间顺序的描述
+	if animation := extractAnimation(t); animation != nil {
+		text, err := recognizeAnimation(ctx, t, animation, apiKey, visionConfig)
+		if err != nil {
+			return "", err
+		}
+		cache.SetImageResult(key, cache.Result{
+			Description: text,
+			Model:       visionConfig.Model,
+			Profile:     t.Prompt.Profile,
+			PromptHash:  cache.PromptHash(t.Prompt.cacheKey()),
+		})
+		log.Infof("动画图片识别结果已缓存（哈希: %s, ID: %s）", t.ImageHash[:16], t.ImageID)
+		return text, nil
+	}
+
+	// 全局并发限制：排队等待识别名额
+	release, err := getRecognitionScheduler().acquire(ctx, apiKey, t.Priority)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	// 取消的同时获得了名额：不再调用视觉模型
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	log.Infof("开始识别图片（哈希: %s, ID: %s, 类型: %s）...", t.ImageHash[:16], t.ImageID, t.MediaType)
+
+	// 转换视觉模型不支持的格式（GIF/WebP/BMP），并按配置缩放和重新编码
+	// 缓存键仍使用原始图片的哈希，不受预处理配置影响
+	imageData, err := imageutil.PrepareForVision(t.Base64Data, t.MediaType, visionImageOptions())
+	if err != nil {
+		return "", fmt.Errorf("图片格式处理失败: %w", err)
+	}
+
+	// 自动选择提示词模板：先用一次低成本调用分类
+	profile := t.Prompt.Profile
+	if profile == vision.ProfileAuto {
+		profile, err = vision.ClassifyImage(ctx, imageData, apiKey, visionConfig)
+		if err != nil {
+			log.Warnf("图片分类失败，使用默认提示词（哈希: %s, ID: %s）: %v", t.ImageHash[:16], t.ImageID, err)
+		} else {
+			log.Infof("图片分类结果（哈希: %s, ID: %s）: %s", t.ImageHash[:16], t.ImageID, profile)
+		}
+	}
+
+	// 构建识别请求
+	prompt := t.Prompt.render(profile)
+	visionReq := vision.ImageAnalysisRequest{
+		ImageBase64: imageData,
+		Prompt:      prompt, // 为空时使用默认 prompt
+		APIKey:      apiKey,
+	}
+
+	// 调用识别
+	result, err := vision.AnalyzeImageWithConfig(ctx, visionReq, visionConfig)
+	if err != nil {
+		return "", err
+	}
+	if !result.Success {
+		return "", errors.New(result.Error)
+	}
+
+	// 识别成功
+	log.Infof("图片识别成功（哈希: %s, ID: %s），转换为文本", t.ImageHash[:16], t.ImageID)
+
+	// 保存到缓存
+	cache.SetImageResult(key, cache.Result{
+		Description: result.Data,
+		Model:       visionConfig.Model,
+		Profile:     profile,
+		PromptHash:  cache.PromptHash(t.Prompt.cacheKey()),
+		Usage: cache.Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		},
+	})
+	log.Infof("图片识别结果已缓存（哈希: %s, ID: %s）", t.ImageHash[:16], t.ImageID)
+
+	return result.Data, nil
+}
+
+// resolveRemoteImage 下载远程图片，填充任务的图片数据和哈希（与内联 base64 图片使用相同的缓存键）
+// 返回：缓存中的识别结果、是否命中缓存
+func resolveRemoteImage(ctx context.Context, t *ImageTask) (string, bool, error) {
+	image, err := imagefetch.Fetch(ctx, t.URL)
+	if err != nil {
+		return "", false, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(image.Data)
+	t.ImageHash = cache.ComputeHash(encoded)
+	t.Base64Data = encoded
+	t.MediaType = imageutil.DetectMediaType(image.Data)
+	log.Infof("已下载远程图片（ID: %s, 类型: %s, 大小: %d 字节）", t.ImageID, image.MediaType, len(image.Data))
+
+	if result, found := cache.GetImageResult(t.Prompt.resultKey(t.ImageHash)); found {
+		return result, true, nil
+	}
+	return "", false, nil
 }
 
 // applyRecognitionResults 将识别结果应用到 content
@@ -463,6 +603,25 @@ func extractBase64FromURL(url string) string {
 	return url
 }
 
+// visionImageOptions 发送给视觉模型前的图片预处理配置
+func visionImageOptions() imageutil.Options {
+	return imageutil.Options{
+		MaxDimension:  config.AppConfig.ImageMaxDimension,
+		MaxPixels:     config.AppConfig.ImageMaxPixels,
+		Format:        config.AppConfig.ImageOutputFormat,
+		JPEGQuality:   config.AppConfig.ImageJPEGQuality,
+		StripMetadata: config.AppConfig.ImageStripMetadata,
+	}
+}
+
+// detectMediaType 按文件头识别 base64 图片的类型，无法识别时使用请求中声明的类型
+func detectMediaType(base64Data string, declared string) string {
+	if mediaType := imageutil.DetectBase64MediaType(base64Data); mediaType != "" {
+		return mediaType
+	}
+	return declared
+}
+
 // extractBase64FromData 从 data URI 或直接的 base64 字符串中提取 base64 数据（别名）
 func extractBase64FromData(data string) string {
 	return extractBase64FromURL(data)
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+
+	"glm-tool/internal/cache"
+	"glm-tool/internal/imagefetch"
+
+	"github.com/gophertool/tool/log"
+)
+
+// contentSlot 一个可能包含图片的 content 数组：消息本身，或嵌套在其中的 tool_result、document 等内容
+type contentSlot struct {
+	msgIdx         int                    // 所在消息的索引，用于生成图片 ID
+	system         bool                   // 是否属于 system 块（不属于任何消息，图片 ID 按消息 #0 编号）
+	container      map[string]interface{} // content 数组所在的对象，删除图片时写回
+	key            string                 // content 数组在 container 中的字段名
+	content        []interface{}
+	references     map[int]ImageReference
+	activeImageIDs []string // 当前 content 对应的活跃图片 ID 列表（按顺序）
+}
+
+// collectTasksFunc 从一个 content 数组中收集图片任务，返回任务、无法识别的图片（记为失败）和命中缓存的图片数量
+type collectTasksFunc func(content []interface{}, references map[int]ImageReference, prompts promptBuilder) ([]ImageTask, []ImageResult, int)
+
+// collectContentSlots 递归收集 container[key] 及其中嵌套的 content 数组（按出现顺序）
+// 嵌套内容包括 tool_result.content 以及 document 的 source.content；PDF 文档先展开为逐页内容
+func collectContentSlots(slots []contentSlot, container map[string]interface{}, key string, msgIdx int) []contentSlot {
+	content, ok := container[key].([]interface{})
+	if !ok {
+		return slots
+	}
+	if expanded, changed := expandDocuments(content); changed {
+		content = expanded
+		container[key] = content
+	}
+	slots = append(slots, contentSlot{msgIdx: msgIdx, container: container, key: key, content: content})
+
+	for _, item := range content {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// tool_result 等块的 content 数组
+		if _, ok := block["content"].([]interface{}); ok {
+			slots = collectContentSlots(slots, block, "content", msgIdx)
+		}
+		// document 块：source.type 为 content 时 source.content 为内容数组
+		if source, ok := block["source"].(map[string]interface{}); ok {
+			if _, ok := source["content"].([]interface{}); ok {
+				slots = collectContentSlots(slots, source, "content", msgIdx)
+			}
+		}
+	}
+	return slots
+}
+
+// priority 返回 content 中图片的调度优先级：只有最新一条用户消息中的图片优先
+func (slot contentSlot) priority(latestUserIdx int) int {
+	if !slot.system && slot.msgIdx == latestUserIdx {
+		return priorityLatest
+	}
+	return priorityHistory
+}
+
+// logContentItems 打印 content 结构（用于调试）
+func logContentItems(content []interface{}) {
+	for i, item := range content {
+		contentItem, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		contentType, _ := contentItem["type"].(string)
+		switch contentType {
+		case "text":
+			if text, ok := contentItem["text"].(string); ok {
+				log.Infof("  Content[%d]: type=text, text=%s", i, truncateString(text, 100))
+			}
+		case "image_url":
+			// 提取并打印图片哈希值
+			if imageURL, ok := contentItem["image_url"].(map[string]interface{}); ok {
+				if url, ok := imageURL["url"].(string); ok {
+					if imagefetch.IsRemoteURL(url) {
+						log.Infof("  Content[%d]: type=image_url, url=%s", i, truncateString(url, 100))
+						continue
+					}
+					imageHash := cache.ComputeHash(extractBase64FromURL(url))
+					log.Infof("  Content[%d]: type=image_url, hash=%s", i, imageHash[:16])
+				}
+			}
+		case "image":
+			if source, ok := contentItem["source"].(map[string]interface{}); ok {
+				if url, ok := source["url"].(string); ok {
+					log.Infof("  Content[%d]: type=image, url=%s", i, truncateString(url, 100))
+				} else if data, ok := source["data"].(string); ok {
+					imageHash := cache.ComputeHash(extractBase64FromData(data))
+					log.Infof("  Content[%d]: type=image, hash=%s", i, imageHash[:16])
+				}
+			}
+		default:
+			if contentType != "" {
+				log.Infof("  Content[%d]: type=%s", i, contentType)
+			}
+		}
+	}
+}
+
+// processContentSlots 识别所有 content 数组中的图片并替换为文本
+// apiType 为日志前缀；latestUserIdx 为最新一条用户消息的索引，其中的图片优先调度
+func processContentSlots(ctx context.Context, slots []contentSlot, apiKey string, apiType string, options ImageProcessOptions, prompts promptBuilder, latestUserIdx int, collect collectTasksFunc) (ImageStats, error) {
+	var stats ImageStats
+	if options.SkipImages {
+		// 收集 content 时已展开 PDF 文档
+		log.Infof("%s目标模型支持图片输入，跳过图片识别", apiType)
+		return stats, nil
+	}
+
+	// 全局图片计数器，确保整个请求中的图片 ID 唯一
+	globalImageCounter := 1
+
+	// 当前活跃的图片 ID 列表（遇到新图片时更新）
+	currentActiveImageIDs := make([]string, 0)
+
+	// 第一遍：提取所有图片引用，确定每个 content 对应的图片 ID 列表
+	for slotIdx := range slots {
+		slot := &slots[slotIdx]
+		log.Infof("消息 #%d 的 %s 包含 %d 个 content 项", slot.msgIdx, slot.key, len(slot.content))
+		logContentItems(slot.content)
+
+		// 提取图片引用
+		references := extractImageReferences(slot.content, slot.msgIdx, globalImageCounter)
+		if len(references) > 0 {
+			log.Infof("%s提取到 %d 个图片引用", apiType, len(references))
+			// 清空当前活跃列表，重新填充（新的图片组）
+			currentActiveImageIDs = make([]string, 0)
+			for idx, ref := range references {
+				log.Infof("  图片[%d] -> ID: %s, 编号: #%d", idx, ref.ImageID, ref.Number)
+				currentActiveImageIDs = append(currentActiveImageIDs, ref.ImageID)
+			}
+			// 更新全局计数器
+			globalImageCounter += len(references)
+		}
+
+		// 复制当前活跃列表
+		slot.references = references
+		slot.activeImageIDs = make([]string, len(currentActiveImageIDs))
+		copy(slot.activeImageIDs, currentActiveImageIDs)
+	}
+
+	// 第二遍：在所有文本中填充 ID（使用每个 content 对应的活跃图片列表）
+	for _, slot := range slots {
+		if len(slot.activeImageIDs) == 0 {
+			continue
+		}
+		// 填充文本中的图片 ID（按编号匹配）
+		fillImageIDsInTextByNumber(slot.content, slot.activeImageIDs)
+		log.Debugf("消息 #%d 填充 ID 完成 (活跃图片: %v)", slot.msgIdx, slot.activeImageIDs)
+	}
+
+	// 第三遍：收集所有 content 中的图片任务，一次性并发识别
+	// 无法识别的图片（未启用远程下载、来源无效等）直接记为失败，与识别失败一样按失败策略处理
+	var tasks []ImageTask
+	var skipped []ImageResult
+	for slotIdx, slot := range slots {
+		if len(slot.references) == 0 {
+			continue
+		}
+
+		slotTasks, slotSkipped, cached := collect(slot.content, slot.references, prompts)
+		stats.Cached += cached
+		for i := range slotTasks {
+			slotTasks[i].SlotIndex = slotIdx
+			slotTasks[i].Priority = slot.priority(latestUserIdx)
+		}
+		for i := range slotSkipped {
+			slotSkipped[i].SlotIndex = slotIdx
+		}
+		tasks = append(tasks, slotTasks...)
+		skipped = append(skipped, slotSkipped...)
+	}
+
+	// 并发识别图片
+	results := recognizeImagesConcurrently(ctx, tasks, apiKey, apiType, options.Vision)
+	results = append(results, skipped...)
+
+	stats.add(results)
+
+	// 客户端已断开，不再修改请求
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+
+	// 失败策略为 fail 时整个请求返回错误
+	if err := recognitionError(results, options.FailurePolicy); err != nil {
+		return stats, err
+	}
+
+	// 第四遍：将识别结果应用回各个 content
+	resultsBySlot := make(map[int][]ImageResult)
+	for _, result := range results {
+		resultsBySlot[result.SlotIndex] = append(resultsBySlot[result.SlotIndex], result)
+	}
+	for slotIdx, slot := range slots {
+		slotResults, ok := resultsBySlot[slotIdx]
+		if !ok {
+			continue
+		}
+		applyRecognitionResults(slot.content, slotResults, slot.references)
+		if stats.Failed > 0 {
+			slot.container[slot.key] = applyFailurePolicy(slot.content, slotResults, slot.references, options.FailurePolicy)
+		}
+		log.Infof("消息 #%d 的 %s 图片识别完成", slot.msgIdx, slot.key)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestCollectAnthropicSlots system 块、tool_result 和 document 中嵌套的 content 按出现顺序收集，
+// 只有最新一条用户消息（包括其中嵌套的内容）中的图片优先调度
+func TestCollectAnthropicSlots(t *testing.T) {
+	disableImageFetch(t)
+
+	body := `{
+		"system": [
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+			{"type": "text", "text": "系统提示"}
+		],
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "t1", "content": [
+					{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "BBBB"}}
+				]}
+			]},
+			{"role": "assistant", "content": "好的"},
+			{"role": "user", "content": [
+				{"type": "document", "source": {"type": "content", "content": [
+					{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "CCCC"}}
+				]}},
+				{"type": "tool_result", "tool_use_id": "t2", "content": [
+					{"type": "text", "text": "结果"},
+					{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "DDDD"}}
+				]}
+			]}
+		]
+	}`
+	var requestData map[string]any
+	if err := json.Unmarshal([]byte(body), &requestData); err != nil {
+		t.Fatal(err)
+	}
+	messages := requestData["messages"].([]interface{})
+	slots := collectAnthropicSlots(requestData, messages)
+
+	want := []struct {
+		msgIdx   int
+		system   bool
+		items    int
+		priority int
+	}{
+		{msgIdx: 0, system: true, items: 2, priority: priorityHistory}, // system 块
+		{msgIdx: 0, items: 1, priority: priorityHistory},               // 消息 #0
+		{msgIdx: 0, items: 1, priority: priorityHistory},               // 消息 #0 的 tool_result
+		{msgIdx: 2, items: 2, priority: priorityLatest},                // 消息 #2
+		{msgIdx: 2, items: 1, priority: priorityLatest},                // 消息 #2 的 document
+		{msgIdx: 2, items: 2, priority: priorityLatest},                // 消息 #2 的 tool_result
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("收集到 %d 个 content 数组，期望 %d 个", len(slots), len(want))
+	}
+	latest := latestUserMessageIndex(messages)
+	for i, w := range want {
+		slot := slots[i]
+		if slot.msgIdx != w.msgIdx || slot.system != w.system || len(slot.content) != w.items {
+			t.Errorf("slots[%d] = 消息 #%d（system: %v，%d 项），期望消息 #%d（system: %v，%d 项）",
+				i, slot.msgIdx, slot.system, len(slot.content), w.msgIdx, w.system, w.items)
+		}
+		if got := slot.priority(latest); got != w.priority {
+			t.Errorf("slots[%d] 的优先级 = %d，期望 %d", i, got, w.priority)
+		}
+	}
+
+	// 只有 system 块和第一条用户消息时，system 中的图片仍按历史图片调度
+	first := messages[:1]
+	for _, slot := range collectAnthropicSlots(requestData, first) {
+		if want := !slot.system; (slot.priority(latestUserMessageIndex(first)) == priorityLatest) != want {
+			t.Errorf("消息 #%d（system: %v）的优先级错误", slot.msgIdx, slot.system)
+		}
+	}
+}
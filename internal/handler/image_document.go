@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"glm-tool/config"
+	"glm-tool/internal/pdfdoc"
+
+	"github.com/gophertool/tool/log"
+)
+
+// expandDocuments 将 content 中的 PDF 文档（Anthropic document 块、OpenAI file 块）展开为逐页的文本块
+// 没有文字层的页面展开为页面中的图片，交给后续的图片识别流程处理；返回值表示 content 是否被修改
+func expandDocuments(content []interface{}) ([]interface{}, bool) {
+	if !config.AppConfig.PDFEnabled {
+		return content, false
+	}
+
+	var expanded []interface{}
+	changed := false
+	for i, item := range content {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			if changed {
+				expanded = append(expanded, item)
+			}
+			continue
+		}
+		data, name, anthropic, ok := pdfDocumentData(block)
+		if !ok {
+			if changed {
+				expanded = append(expanded, item)
+			}
+			continue
+		}
+		if !changed {
+			expanded = append(make([]interface{}, 0, len(content)), content[:i]...)
+			changed = true
+		}
+		expanded = append(expanded, documentBlocks(data, name, anthropic)...)
+	}
+	if !changed {
+		return content, false
+	}
+	return expanded, true
+}
+
+// pdfDocumentData 从 document / file 块中取出 PDF 数据（base64）和文件名
+// anthropic 表示块来自 Anthropic 格式，页面图片按相同格式生成
+func pdfDocumentData(block map[string]interface{}) (data string, name string, anthropic bool, ok bool) {
+	switch block["type"] {
+	case "document":
+		// {"type": "document", "source": {"type": "base64", "media_type": "application/pdf", "data": "..."}, "title": "..."}
+		source, _ := block["source"].(map[string]interface{})
+		if source == nil || source["type"] != "base64" || source["media_type"] != pdfdoc.MediaType {
+			return "", "", false, false
+		}
+		data, _ = source["data"].(string)
+		name, _ = block["title"].(string)
+		return data, name, true, data != ""
+	case "file":
+		// {"type": "file", "file": {"filename": "...", "file_data": "data:application/pdf;base64,..."}}
+		file, _ := block["file"].(map[string]interface{})
+		if file == nil {
+			return "", "", false, false
+		}
+		fileData, _ := file["file_data"].(string)
+		name, _ = file["filename"].(string)
+		if !strings.HasPrefix(fileData, "data:"+pdfdoc.MediaType) {
+			return "", "", false, false
+		}
+		return extractBase64FromURL(fileData), name, false, true
+	}
+	return "", "", false, false
+}
+
+// documentBlocks 解析 PDF 并生成替换用的 content 块
+func documentBlocks(data string, name string, anthropic bool) []interface{} {
+	if name == "" {
+		name = "未命名"
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		log.Warnf("PDF 文档 %s 解码失败: %v", name, err)
+		return []interface{}{textBlock(fmt.Sprintf("[PDF 文档: %s，解析失败: 无效的 base64 数据]", name))}
+	}
+	doc, err := pdfdoc.Parse(raw, pdfdoc.Options{
+		MaxPages:         config.AppConfig.PDFMaxPages,
+		MinTextChars:     config.AppConfig.PDFMinTextChars,
+		MaxImagesPerPage: config.AppConfig.PDFMaxImagesPerPage,
+	})
+	if err != nil {
+		log.Warnf("PDF 文档 %s 解析失败: %v", name, err)
+		return []interface{}{textBlock(fmt.Sprintf("[PDF 文档: %s，%v]", name, err))}
+	}
+
+	header := fmt.Sprintf("[PDF 文档: %s，共 %d 页]", name, doc.NumPages)
+	if len(doc.Pages) < doc.NumPages {
+		header = fmt.Sprintf("[PDF 文档: %s，共 %d 页，仅包含前 %d 页]", name, doc.NumPages, len(doc.Pages))
+	}
+
+	// 连续的文字页合并到同一个文本块，扫描页的图片单独成块
+	blocks := make([]interface{}, 0, len(doc.Pages)+1)
+	var text strings.Builder
+	text.WriteString(header)
+	flush := func() {
+		if text.Len() > 0 {
+			blocks = append(blocks, textBlock(text.String()))
+			text.Reset()
+		}
+	}
+
+	imageCount := 0
+	for _, page := range doc.Pages {
+		switch {
+		case len(page.Images) > 0:
+			text.WriteString(fmt.Sprintf("\n\n--- 第 %d 页（扫描页，共 %d 张图片）---", page.Number, len(page.Images)))
+			if page.Text != "" {
+				text.WriteString("\n" + page.Text)
+			}
+			flush()
+			for _, img := range page.Images {
+				blocks = append(blocks, pageImageBlock(img, anthropic))
+			}
+			imageCount += len(page.Images)
+		case page.Text != "":
+			text.WriteString(fmt.Sprintf("\n\n--- 第 %d 页 ---\n%s", page.Number, page.Text))
+		default:
+			text.WriteString(fmt.Sprintf("\n\n--- 第 %d 页（无可提取的内容）---", page.Number))
+		}
+	}
+	flush()
+
+	log.Infof("PDF 文档 %s 展开为 %d 页，其中 %d 张页面图片待识别", name, len(doc.Pages), imageCount)
+	return blocks
+}
+
+// textBlock 生成文本 content 块
+func textBlock(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "text",
+		"text": text,
+	}
+}
+
+// pageImageBlock 按请求格式生成页面图片 content 块
+func pageImageBlock(img pdfdoc.Image, anthropic bool) map[string]interface{} {
+	data := base64.StdEncoding.EncodeToString(img.Data)
+	if anthropic {
+		return map[string]interface{}{
+			"type": "image",
+			"source": map[string]interface{}{
+				"type":       "base64",
+				"media_type": img.MediaType,
+				"data":       data,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"type": "image_url",
+		"image_url": map[string]interface{}{
+			"url": fmt.Sprintf("data:%s;base64,%s", img.MediaType, data),
+		},
+	}
+}
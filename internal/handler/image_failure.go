@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gophertool/tool/log"
+)
+
+// 图片识别失败时的处理策略
+const (
+	FailurePolicyKeep        = "keep"        // 保留原图片（上游模型需要支持图片）
+	FailurePolicyPlaceholder = "placeholder" // 替换为说明失败原因的文本
+	FailurePolicyDrop        = "drop"        // 删除图片
+	FailurePolicyFail        = "fail"        // 整个请求返回错误
+)
+
+// imageStatsHeader 报告图片识别统计的响应头
+const imageStatsHeader = "X-Image-Recognition"
+
+// failureReasonMaxChars 占位文本中失败原因的最大字符数
+const failureReasonMaxChars = 200
+
+// isFailurePolicy 是否为支持的失败处理策略
+func isFailurePolicy(policy string) bool {
+	switch policy {
+	case FailurePolicyKeep, FailurePolicyPlaceholder, FailurePolicyDrop, FailurePolicyFail:
+		return true
+	}
+	return false
+}
+
+// ImageStats 单个请求的图片识别统计
+type ImageStats struct {
+	Recognized int // 调用视觉模型识别成功
+	Cached     int // 使用缓存结果
+	Failed     int // 识别失败
+}
+
+// add 累加识别结果
+func (s *ImageStats) add(results []ImageResult) {
+	for _, result := range results {
+		switch {
+		case !result.Success:
+			s.Failed++
+		case result.Cached:
+			s.Cached++
+		default:
+			s.Recognized++
+		}
+	}
+}
+
+// String 响应头格式：recognized=3, cached=1, failed=0
+func (s ImageStats) String() string {
+	return fmt.Sprintf("recognized=%d, cached=%d, failed=%d", s.Recognized, s.Cached, s.Failed)
+}
+
+// setImageStatsHeader 请求中有图片时在响应头中报告识别统计
+func setImageStatsHeader(c *gin.Context, stats ImageStats) {
+	if stats.Recognized+stats.Cached+stats.Failed == 0 {
+		return
+	}
+	c.Header(imageStatsHeader, stats.String())
+}
+
+// ImageRecognitionError 失败策略为 fail 时有图片识别失败
+type ImageRecognitionError struct {
+	Failed int    // 失败的图片数量
+	Reason string // 第一张失败图片的原因
+}
+
+func (e *ImageRecognitionError) Error() string {
+	return fmt.Sprintf("%d 张图片识别失败: %s", e.Failed, e.Reason)
+}
+
+// skippedImage 无法识别的图片（未启用远程下载、来源无效等）记为识别失败
+func skippedImage(contentIndex int, ref ImageReference, reason string) ImageResult {
+	log.Warnf("无法识别 Content[%d] 的图片（ID: %s）: %s", contentIndex, ref.ImageID, reason)
+	return ImageResult{ContentIndex: contentIndex, Success: false, Error: reason}
+}
+
+// recognitionError 按失败策略检查识别结果，策略为 fail 且有失败时返回错误
+func recognitionError(results []ImageResult, policy string) error {
+	if policy != FailurePolicyFail {
+		return nil
+	}
+	var recErr *ImageRecognitionError
+	for _, result := range results {
+		if result.Success {
+			continue
+		}
+		if recErr == nil {
+			recErr = &ImageRecognitionError{Reason: result.Error}
+		}
+		recErr.Failed++
+	}
+	if recErr == nil {
+		return nil
+	}
+	return recErr
+}
+
+// failurePlaceholder 识别失败图片的占位文本
+func failurePlaceholder(imageID string, reason string) string {
+	return fmt.Sprintf("[Image %s: recognition failed: %s]", imageID, truncateRunes(reason, failureReasonMaxChars))
+}
+
+// applyFailurePolicy 按失败策略处理识别失败的图片，返回处理后的 content
+// drop 会删除 content 项，调用方需要将返回值写回消息
+func applyFailurePolicy(content []interface{}, results []ImageResult, references map[int]ImageReference, policy string) []interface{} {
+	if policy != FailurePolicyPlaceholder && policy != FailurePolicyDrop {
+		return content
+	}
+
+	failed := make(map[int]bool)
+	for _, result := range results {
+		if result.Success {
+			continue
+		}
+		ref := references[result.ContentIndex]
+		failed[result.ContentIndex] = true
+		content[result.ContentIndex] = map[string]interface{}{
+			"type": "text",
+			"text": failurePlaceholder(ref.ImageID, result.Error),
+		}
+		log.Infof("将识别失败的 Content[%d] 替换为占位文本 (ID: %s, 策略: %s)", result.ContentIndex, ref.ImageID, policy)
+	}
+	if policy != FailurePolicyDrop || len(failed) == 0 {
+		return content
+	}
+
+	kept := make([]interface{}, 0, len(content)-len(failed))
+	for i, item := range content {
+		if !failed[i] {
+			kept = append(kept, item)
+		}
+	}
+	// 消息只剩识别失败的图片时保留占位文本，避免出现空消息
+	if len(kept) == 0 {
+		return content
+	}
+	return kept
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"glm-tool/config"
+)
+
+// failureContent 两张图片和一段文字：第一张识别成功，第二张识别失败
+func failureContent() ([]interface{}, []ImageResult, map[int]ImageReference) {
+	content := []interface{}{
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,AAAA"}},
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,BBBB"}},
+		map[string]interface{}{"type": "text", "text": "看图"},
+	}
+	results := []ImageResult{
+		{ContentIndex: 0, Text: "一只猫", Success: true},
+		{ContentIndex: 1, Success: false, Error: "timeout"},
+	}
+	references := map[int]ImageReference{0: {ImageID: "#0_1"}, 1: {ImageID: "#0_2"}}
+	return content, results, references
+}
+
+// contentTypes 返回 content 各项的类型，文本项返回文本内容
+func contentTypes(content []interface{}) []string {
+	var types []string
+	for _, item := range content {
+		block, _ := item.(map[string]interface{})
+		if block["type"] == "text" {
+			types = append(types, block["text"].(string))
+			continue
+		}
+		types = append(types, block["type"].(string))
+	}
+	return types
+}
+
+func TestApplyFailurePolicy(t *testing.T) {
+	placeholder := failurePlaceholder("#0_2", "timeout")
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: FailurePolicyKeep, want: []string{"image_url", "image_url", "看图"}},
+		{policy: FailurePolicyFail, want: []string{"image_url", "image_url", "看图"}},
+		{policy: FailurePolicyPlaceholder, want: []string{"image_url", placeholder, "看图"}},
+		{policy: FailurePolicyDrop, want: []string{"image_url", "看图"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			content, results, references := failureContent()
+			got := contentTypes(applyFailurePolicy(content, results, references, tt.policy))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("content = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+
+	// 只剩识别失败的图片时保留占位文本
+	content := []interface{}{map[string]interface{}{"type": "image_url"}}
+	results := []ImageResult{{ContentIndex: 0, Error: "timeout"}}
+	got := contentTypes(applyFailurePolicy(content, results, map[int]ImageReference{0: {ImageID: "#0_1"}}, FailurePolicyDrop))
+	if want := []string{failurePlaceholder("#0_1", "timeout")}; !slices.Equal(got, want) {
+		t.Errorf("content = %q，期望 %q", got, want)
+	}
+}
+
+func TestRecognitionError(t *testing.T) {
+	_, failed, _ := failureContent()
+	succeeded := []ImageResult{{Success: true}}
+	tests := []struct {
+		name       string
+		results    []ImageResult
+		policy     string
+		wantFailed int
+	}{
+		{name: "fail 有失败", results: failed, policy: FailurePolicyFail, wantFailed: 1},
+		{name: "fail 全部成功", results: succeeded, policy: FailurePolicyFail},
+		{name: "keep", results: failed, policy: FailurePolicyKeep},
+		{name: "placeholder", results: failed, policy: FailurePolicyPlaceholder},
+		{name: "drop", results: failed, policy: FailurePolicyDrop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := recognitionError(tt.results, tt.policy)
+			var recErr *ImageRecognitionError
+			if tt.wantFailed == 0 {
+				if err != nil {
+					t.Errorf("期望没有错误，实际 %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &recErr) || recErr.Failed != tt.wantFailed || recErr.Reason != "timeout" {
+				t.Errorf("错误 = %v，期望 %d 张图片失败", err, tt.wantFailed)
+			}
+		})
+	}
+}
+
+// disableImageFetch 使用未启用远程图片下载的配置
+func disableImageFetch(t *testing.T) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{}
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+// TestSkippedImagesFollowPolicy 无法识别的图片（未启用远程下载、来源无效）按失败策略处理并计入统计
+func TestSkippedImagesFollowPolicy(t *testing.T) {
+	disableImageFetch(t)
+
+	requests := []struct {
+		name      string
+		body      string
+		imageType string
+		reasons   []string // 两张图片的失败原因
+		process   func(context.Context, map[string]any, string, ImageProcessOptions) (ImageStats, error)
+	}{
+		{
+			name: "OpenAI",
+			body: `{"messages":[{"role":"user","content":[
+				{"type":"image_url","image_url":{"url":"https://example.com/a.png"}},
+				{"type":"image_url","image_url":{}},
+				{"type":"text","text":"看图"}]}]}`,
+			imageType: "image_url",
+			reasons:   []string{"未启用远程图片下载", "缺少图片地址"},
+			process:   ProcessImageToText,
+		},
+		{
+			name: "Anthropic",
+			body: `{"messages":[{"role":"user","content":[
+				{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}},
+				{"type":"image","source":{"type":"base64","media_type":"text/plain","data":"AAAA"}},
+				{"type":"text","text":"看图"}]}]}`,
+			imageType: "image",
+			reasons:   []string{"未启用远程图片下载", "不支持的图片来源"},
+			process:   ProcessImageToTextForAnthropic,
+		},
+	}
+
+	for _, req := range requests {
+		tests := []struct {
+			policy  string
+			wantErr bool
+			want    []string // 处理后的 content（文本项为文本内容）
+		}{
+			{policy: FailurePolicyKeep, want: []string{req.imageType, req.imageType, "看图"}},
+			{policy: FailurePolicyPlaceholder, want: []string{failurePlaceholder("#0_1", req.reasons[0]), failurePlaceholder("#0_2", req.reasons[1]), "看图"}},
+			{policy: FailurePolicyDrop, want: []string{"看图"}},
+			{policy: FailurePolicyFail, wantErr: true},
+		}
+		for _, tt := range tests {
+			t.Run(req.name+"/"+tt.policy, func(t *testing.T) {
+				var body map[string]any
+				if err := json.Unmarshal([]byte(req.body), &body); err != nil {
+					t.Fatal(err)
+				}
+				stats, err := req.process(context.Background(), body, "Bearer test", ImageProcessOptions{FailurePolicy: tt.policy})
+				if stats.Failed != 2 {
+					t.Errorf("失败数 = %d，期望 2", stats.Failed)
+				}
+				var recErr *ImageRecognitionError
+				if errors.As(err, &recErr) != tt.wantErr {
+					t.Fatalf("错误 = %v，期望返回识别错误: %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				content := body["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+				if got := contentTypes(content); !slices.Equal(got, tt.want) {
+					t.Errorf("content = %q，期望 %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
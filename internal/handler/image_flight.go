@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// recognitionFlights 全局进行中的图片识别，相同缓存键（图片哈希 + 提示词）的识别只调用一次视觉模型
+var recognitionFlights = newFlightGroup()
+
+// flightCall 一次进行中的识别
+type flightCall struct {
+	done    chan struct{}      // 识别完成时关闭
+	result  string             // 识别结果
+	err     error              // 识别错误
+	waiters int                // 正在等待结果的调用方数量
+	cancel  context.CancelFunc // 所有调用方都离开时取消识别
+}
+
+// flightGroup 按键合并并发的相同识别
+// 识别使用独立的 context 运行：只要还有调用方在等待就继续，全部取消后才中止
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// newFlightGroup 创建 flightGroup
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// do 执行 key 对应的识别；已有相同的识别在进行时等待并共享其结果
+// 返回：识别结果、是否复用了其他调用方发起的识别、错误
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (string, error)) (string, bool, error) {
+	g.mu.Lock()
+	call, shared := g.calls[key]
+	if shared {
+		call.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = call
+		go g.run(callCtx, key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, shared, call.err
+	case <-ctx.Done():
+		g.leave(key, call)
+		return "", shared, ctx.Err()
+	}
+}
+
+// run 执行识别并唤醒所有等待的调用方
+func (g *flightGroup) run(ctx context.Context, key string, call *flightCall, fn func(ctx context.Context) (string, error)) {
+	call.result, call.err = fn(ctx)
+
+	g.mu.Lock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	close(call.done)
+	call.cancel()
+}
+
+// leave 调用方放弃等待；最后一个调用方离开时取消识别，之后的请求会重新发起识别
+func (g *flightGroup) leave(key string, call *flightCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	call.cancel()
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupSharesCall(t *testing.T) {
+	g := newFlightGroup()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "cat", nil
+	}
+
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, shared, err := g.do(context.Background(), "key", fn)
+			if err != nil || result != "cat" {
+				t.Errorf("do = %q, %v", result, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	waitFor(t, func() bool { return waiters(g, "key") == 5 })
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || sharedCount.Load() != 4 {
+		t.Errorf("识别调用 %d 次，复用 %d 次，期望 1 次和 4 次", calls.Load(), sharedCount.Load())
+	}
+	if len(g.calls) != 0 {
+		t.Error("识别完成后仍保留进行中的记录")
+	}
+}
+
+func TestFlightGroupCancellation(t *testing.T) {
+	g := newFlightGroup()
+	started := make(chan context.Context, 2)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		started <- ctx
+		select {
+		case <-release:
+			return "cat", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	// 发起识别的调用方取消后，只要还有其他调用方等待，识别就继续
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(firstCtx, "key", fn)
+		firstErr <- err
+	}()
+	callCtx := <-started
+	secondResult := make(chan string, 1)
+	go func() {
+		result, _, _ := g.do(context.Background(), "key", fn)
+		secondResult <- result
+	}()
+	waitFor(t, func() bool { return waiters(g, "key") == 2 })
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("取消的调用方 err = %v，期望 context.Canceled", err)
+	}
+	if callCtx.Err() != nil {
+		t.Fatal("仍有调用方等待时识别被取消")
+	}
+	close(release)
+	if result := <-secondResult; result != "cat" {
+		t.Errorf("剩余调用方得到 %q，期望共享识别结果", result)
+	}
+
+	// 所有调用方都取消后中止识别，之后的请求重新发起识别
+	ctx, cancel := context.WithCancel(context.Background())
+	go g.do(ctx, "other", func(ctx context.Context) (string, error) {
+		started <- ctx
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	callCtx = <-started
+	cancel()
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("所有调用方取消后识别没有中止")
+	}
+	result, shared, err := g.do(context.Background(), "other", func(ctx context.Context) (string, error) {
+		return "dog", nil
+	})
+	if err != nil || shared || result != "dog" {
+		t.Errorf("重新识别 = %q, %v, %v，期望发起新的识别", result, shared, err)
+	}
+}
+
+// waiters 返回 key 对应识别的等待方数量
+func waiters(g *flightGroup, key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call.waiters
+	}
+	return 0
+}
+
+// waitFor 等待条件成立，超时时测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,16 +1,22 @@
 package handler
 
 import (
+	"context"
 	"strings"
 
 	"glm-tool/internal/cache"
+	"glm-tool/internal/imagefetch"
+	"glm-tool/internal/imageutil"
 
 	"github.com/gophertool/tool/log"
 )
 
 // collectOpenAIImageTasks 从 OpenAI 格式的 content 中收集图片任务
-func collectOpenAIImageTasks(content []interface{}, references map[int]ImageReference) []ImageTask {
+// 返回：待识别的任务、无法识别的图片（记为失败）、命中缓存的图片数量
+func collectOpenAIImageTasks(content []interface{}, references map[int]ImageReference, prompts promptBuilder) ([]ImageTask, []ImageResult, int) {
 	var tasks []ImageTask
+	var failed []ImageResult
+	cached := 0
 
 	for i, item := range content {
 		contentItem, ok := item.(map[string]interface{})
@@ -19,178 +25,112 @@ func collectOpenAIImageTasks(content []interface{}, references map[int]ImageRefe
 		}
 
 		// 检查 type 是否为 image_url
-		if contentType, ok := contentItem["type"].(string); ok && contentType == "image_url" {
-			// 获取 image_url 对象
-			if imageURL, ok := contentItem["image_url"].(map[string]interface{}); ok {
-				// 提取图片 URL
-				if url, ok := imageURL["url"].(string); ok {
-					// 提取 base64 数据
-					base64Data := extractBase64FromURL(url)
-					imageHash := cache.ComputeHash(base64Data)
-
-					// 获取这张图片的引用信息
-					ref, hasRef := references[i]
-					if !hasRef {
-						continue // 没有引用信息，跳过
-					}
-
-					// 构建前缀
-					prefix := buildImagePrefix(ref)
-
-					// 检查缓存
-					if hit, text := processImageWithCache(imageHash, ref, prefix); hit {
-						content[i] = map[string]interface{}{
-							"type": "text",
-							"text": text,
-						}
-						continue
-					}
-
-					// 添加到待处理任务列表
-					tasks = append(tasks, ImageTask{
-						ContentIndex: i,
-						Base64Data:   base64Data,
-						ImageHash:    imageHash,
-						ImageID:      ref.ImageID,
-					})
-				}
+		if contentType, _ := contentItem["type"].(string); contentType != "image_url" {
+			continue
+		}
+
+		// 获取这张图片的引用信息
+		ref, hasRef := references[i]
+		if !hasRef {
+			failed = append(failed, skippedImage(i, ref, "缺少图片引用信息"))
+			continue
+		}
+
+		// 提取图片 URL
+		imageURL, _ := contentItem["image_url"].(map[string]interface{})
+		url, _ := imageURL["url"].(string)
+		if url == "" {
+			failed = append(failed, skippedImage(i, ref, "缺少图片地址"))
+			continue
+		}
+
+		// 远程图片：识别时再下载
+		if imagefetch.IsRemoteURL(url) {
+			if !imagefetch.Enabled() {
+				failed = append(failed, skippedImage(i, ref, "未启用远程图片下载"))
+				continue
 			}
+			tasks = append(tasks, ImageTask{
+				ContentIndex: i,
+				ImageID:      ref.ImageID,
+				URL:          url,
+				Prompt:       prompts.build(content, i),
+			})
+			continue
 		}
+
+		// 提取 base64 数据
+		base64Data := extractBase64FromURL(url)
+		imageHash := cache.ComputeHash(base64Data)
+
+		// 构建前缀
+		prefix := buildImagePrefix(ref)
+
+		// 识别提示词（缓存键包含提示词哈希）
+		prompt := prompts.build(content, i)
+
+		// 检查缓存
+		if hit, text := processImageWithCache(prompt.resultKey(imageHash), ref, prefix); hit {
+			content[i] = map[string]interface{}{
+				"type": "text",
+				"text": text,
+			}
+			cached++
+			continue
+		}
+
+		// 添加到待处理任务列表
+		tasks = append(tasks, ImageTask{
+			ContentIndex: i,
+			Base64Data:   base64Data,
+			ImageHash:    imageHash,
+			ImageID:      ref.ImageID,
+			Prompt:       prompt,
+			MediaType:    imageutil.DetectDataMediaType(url),
+		})
 	}
 
-	return tasks
+	return tasks, failed, cached
 }
 
 // ProcessImageToText 图片处理中间件：并发识别图片并转换为文本（OpenAI 格式）
-func ProcessImageToText(requestData map[string]any, authHeader string) error {
+// 包括所有角色（system、user、assistant、tool）消息中的图片，以及嵌套 content 中的图片
+func ProcessImageToText(ctx context.Context, requestData map[string]any, authHeader string, options ImageProcessOptions) (ImageStats, error) {
 	// 提取 API Key
 	apiKey := strings.TrimPrefix(authHeader, "Bearer ")
 
 	// 获取 messages 字段
 	messages, ok := requestData["messages"].([]interface{})
 	if !ok {
-		return nil
+		return ImageStats{}, nil
 	}
 
 	log.Infof("OpenAI API 开始处理请求，共 %d 条消息", len(messages))
 
-	// 全局图片计数器，确保整个请求中的图片 ID 唯一
-	globalImageCounter := 1
-
-	// 存储所有消息的 content 和 references
-	type messageData struct {
-		content        []interface{}
-		references     map[int]ImageReference
-		activeImageIDs []string // 当前消息对应的活跃图片 ID 列表（按顺序）
-	}
-	allMessages := make([]messageData, 0)
-
-	// 当前活跃的图片 ID 列表（遇到新图片时更新）
-	currentActiveImageIDs := make([]string, 0)
-
-	// 第一遍：提取所有图片引用，确定每个消息对应的图片 ID 列表
+	// 收集所有消息及其嵌套的 content 数组
+	var slots []contentSlot
 	for msgIdx, msg := range messages {
 		message, ok := msg.(map[string]interface{})
 		if !ok {
-			// 复制当前活跃列表
-			activeIDs := make([]string, len(currentActiveImageIDs))
-			copy(activeIDs, currentActiveImageIDs)
-			allMessages = append(allMessages, messageData{activeImageIDs: activeIDs})
 			continue
 		}
-
 		// 打印消息角色
 		if role, ok := message["role"].(string); ok {
 			log.Infof("处理消息 #%d (role: %s)", msgIdx, role)
 		}
-
-		// 获取 content 字段
-		content, ok := message["content"].([]interface{})
-		if !ok {
-			activeIDs := make([]string, len(currentActiveImageIDs))
-			copy(activeIDs, currentActiveImageIDs)
-			allMessages = append(allMessages, messageData{activeImageIDs: activeIDs})
-			continue
-		}
-
-		log.Infof("消息 #%d 包含 %d 个 content 项", msgIdx, len(content))
-
-		// 打印 content 结构（用于调试）
-		for i, item := range content {
-			if contentItem, ok := item.(map[string]interface{}); ok {
-				if contentType, ok := contentItem["type"].(string); ok {
-					if contentType == "text" {
-						if text, ok := contentItem["text"].(string); ok {
-							log.Infof("  Content[%d]: type=text, text=%s", i, truncateString(text, 100))
-						}
-					} else if contentType == "image_url" {
-						// 提取并打印图片哈希值
-						if imageURL, ok := contentItem["image_url"].(map[string]interface{}); ok {
-							if url, ok := imageURL["url"].(string); ok {
-								base64Data := extractBase64FromURL(url)
-								imageHash := cache.ComputeHash(base64Data)
-								log.Infof("  Content[%d]: type=image_url, hash=%s", i, imageHash[:16])
-							}
-						}
-					}
-				}
-			}
-		}
-
-		// 提取图片引用
-		references := extractImageReferences(content, msgIdx, globalImageCounter)
-		if len(references) > 0 {
-			log.Infof("OpenAI API 提取到 %d 个图片引用", len(references))
-			// 清空当前活跃列表，重新填充（新的图片组）
-			currentActiveImageIDs = make([]string, 0)
-			for idx, ref := range references {
-				log.Infof("  图片[%d] -> ID: %s, 编号: #%d", idx, ref.ImageID, ref.Number)
-				currentActiveImageIDs = append(currentActiveImageIDs, ref.ImageID)
-			}
-			// 更新全局计数器
-			globalImageCounter += len(references)
-		}
-
-		// 复制当前活跃列表
-		activeIDs := make([]string, len(currentActiveImageIDs))
-		copy(activeIDs, currentActiveImageIDs)
-		allMessages = append(allMessages, messageData{
-			content:        content,
-			references:     references,
-			activeImageIDs: activeIDs,
-		})
-	}
-
-	// 第二遍：在所有消息的文本中填充 ID（使用每个消息对应的活跃图片列表）
-	for msgIdx, msgData := range allMessages {
-		if msgData.content == nil || len(msgData.activeImageIDs) == 0 {
-			continue
-		}
-		// 填充文本中的图片 ID（按编号匹配）
-		fillImageIDsInTextByNumber(msgData.content, msgData.activeImageIDs)
-		log.Debugf("消息 #%d 填充 ID 完成 (活跃图片: %v)", msgIdx, msgData.activeImageIDs)
+		slots = collectContentSlots(slots, message, "content", msgIdx)
 	}
 
-	// 第三遍：处理图片识别
-	for msgIdx, msgData := range allMessages {
-		if msgData.content == nil || len(msgData.references) == 0 {
-			continue
-		}
-
-		// 收集图片任务
-		tasks := collectOpenAIImageTasks(msgData.content, msgData.references)
-
-		// 并发识别图片
-		results := recognizeImagesConcurrently(tasks, apiKey, "")
-
-		// 应用识别结果
-		applyRecognitionResults(msgData.content, results, msgData.references)
+	// 识别提示词（启用上下文提示词时结合图片周围的文字）
+	prompts := newPromptBuilder(options, openAISystemText(messages))
 
-		log.Infof("消息 #%d 图片识别完成", msgIdx)
+	stats, err := processContentSlots(ctx, slots, apiKey, "", options, prompts, latestUserMessageIndex(messages), collectOpenAIImageTasks)
+	if err != nil {
+		return stats, err
 	}
 
-	log.Infof("OpenAI API 请求处理完成")
-	return nil
+	log.Infof("OpenAI API 请求处理完成（%s）", stats)
+	return stats, nil
 }
 
 // truncateString 截断字符串用于日志显示
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+
+	"glm-tool/internal/cache"
+	"glm-tool/internal/vision"
+)
+
+// imageRefPattern 文本中的图片引用标记，如 [Image #1] 或 [Image #0_1]
+var imageRefPattern = regexp.MustCompile(`\[Image\s*#[\d_]+\]`)
+
+// imagePrompt 单张图片的识别提示词：视觉模型、提示词模板和上下文
+type imagePrompt struct {
+	Model         string // 视觉模型，与提示词一起决定缓存键
+	Profile       string // 提示词模板，vision.ProfileAuto 表示识别前先分类
+	UserContext   string // 用户随图片发送的文字
+	SystemContext string // 系统提示词
+}
+
+// render 使用指定模板生成提示词，默认模板且没有上下文时返回空（使用默认提示词）
+func (p imagePrompt) render(profile string) string {
+	if (profile == "" || profile == vision.ProfileGeneral) && p.UserContext == "" && p.SystemContext == "" {
+		return ""
+	}
+	return vision.BuildPrompt(profile, p.UserContext, p.SystemContext)
+}
+
+// cacheKey 参与缓存键计算的提示词标识
+// 自动分类时识别前无法确定最终提示词，使用模板名和上下文代替
+func (p imagePrompt) cacheKey() string {
+	if p.Profile == vision.ProfileAuto {
+		return p.Profile + "\n" + p.UserContext + "\n" + p.SystemContext
+	}
+	return p.render(p.Profile)
+}
+
+// resultKey 图片使用该提示词识别的结果缓存键
+func (p imagePrompt) resultKey(imageHash string) string {
+	return cache.ResultKey(imageHash, p.Model, p.cacheKey())
+}
+
+// promptBuilder 根据提示词模板和图片周围的文字构建识别提示词
+type promptBuilder struct {
+	model    string // 视觉模型
+	profile  string // 提示词模板
+	enabled  bool   // 是否启用上下文提示词
+	system   string // 系统提示词（未启用时为空）
+	maxChars int    // 上下文的最大字符数
+}
+
+// newPromptBuilder 创建提示词构建器；system 为请求中的系统提示词文本
+func newPromptBuilder(options ImageProcessOptions, system string) promptBuilder {
+	builder := promptBuilder{model: options.Vision.Model, profile: options.Profile, enabled: options.ContextPrompt, maxChars: options.ContextMaxChars}
+	if options.ContextPrompt && options.ContextIncludeSystem {
+		builder.system = truncateRunes(system, options.ContextMaxChars)
+	}
+	return builder
+}
+
+// build 返回第 imageIndex 个 content 项（图片）的识别提示词
+// 上下文范围与引用替换一致：图片之后、下一张图片之前的文本
+func (b promptBuilder) build(content []interface{}, imageIndex int) imagePrompt {
+	prompt := imagePrompt{Model: b.model, Profile: b.profile}
+	if !b.enabled {
+		return prompt
+	}
+
+	var parts []string
+	for i := imageIndex + 1; i < len(content); i++ {
+		item, ok := content[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		contentType, _ := item["type"].(string)
+		if contentType == "image" || contentType == "image_url" {
+			break
+		}
+		if contentType == "text" {
+			if text, ok := item["text"].(string); ok {
+				text = strings.TrimSpace(imageRefPattern.ReplaceAllString(text, ""))
+				if text != "" {
+					parts = append(parts, text)
+				}
+			}
+		}
+	}
+
+	prompt.UserContext = truncateRunes(strings.Join(parts, "\n"), b.maxChars)
+	prompt.SystemContext = b.system
+	return prompt
+}
+
+// openAISystemText 提取 OpenAI 请求中 system / developer 消息的文本
+func openAISystemText(messages []interface{}) string {
+	var parts []string
+	for _, msg := range messages {
+		message, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if role, _ := message["role"].(string); role == "system" || role == "developer" {
+			if text := plainText(message["content"]); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// plainText 提取字符串或 content 数组中的文本
+func plainText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if block, ok := item.(map[string]interface{}); ok {
+				if text, ok := block["text"].(string); ok && text != "" {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// truncateRunes 按字符数截断文本，maxChars <= 0 时不截断
+func truncateRunes(s string, maxChars int) string {
+	s = strings.TrimSpace(s)
+	if maxChars <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	return string(runes[:maxChars]) + "..."
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"testing"
+
+	"glm-tool/internal/cache"
+	"glm-tool/internal/vision"
+)
+
+// TestImagePromptCacheKey 提示词或模板不同时缓存键不同，生成相同提示词时共用缓存键
+func TestImagePromptCacheKey(t *testing.T) {
+	imageHash := cache.ComputeHash("image")
+	base := imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileGeneral}
+
+	tests := []struct {
+		name   string
+		a, b   imagePrompt
+		shared bool
+	}{
+		{name: "相同提示词", a: base, b: base, shared: true},
+		{name: "未指定模板等同默认模板", a: base, b: imagePrompt{Model: "glm-4.5v"}, shared: true},
+		{name: "相同上下文", a: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileOCR, UserContext: "提取发票号"}, b: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileOCR, UserContext: "提取发票号"}, shared: true},
+		{name: "不同模板", a: base, b: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileOCR}},
+		{name: "不同用户上下文", a: imagePrompt{Model: "glm-4.5v", UserContext: "这是什么"}, b: imagePrompt{Model: "glm-4.5v", UserContext: "数一数有几个"}},
+		{name: "有无上下文", a: base, b: imagePrompt{Model: "glm-4.5v", UserContext: "这是什么"}},
+		{name: "不同系统提示词", a: imagePrompt{Model: "glm-4.5v", SystemContext: "你是医生"}, b: imagePrompt{Model: "glm-4.5v", SystemContext: "你是律师"}},
+		{name: "自动分类与默认模板", a: base, b: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileAuto}},
+		{name: "自动分类的不同上下文", a: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileAuto, UserContext: "a"}, b: imagePrompt{Model: "glm-4.5v", Profile: vision.ProfileAuto, UserContext: "b"}},
+		{name: "不同视觉模型", a: base, b: imagePrompt{Model: "glm-4.6v", Profile: vision.ProfileGeneral}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared := tt.a.resultKey(imageHash) == tt.b.resultKey(imageHash)
+			if shared != tt.shared {
+				t.Errorf("是否共用缓存键 = %v，期望 %v（%q / %q）", shared, tt.shared, tt.a.cacheKey(), tt.b.cacheKey())
+			}
+		})
+	}
+
+	// 默认提示词与旧版本的缓存键一致
+	if key := base.cacheKey(); key != "" {
+		t.Errorf("默认提示词的标识 = %q，期望为空", key)
+	}
+}
+
+// TestPromptBuilderCacheKey 按图片周围的文字构建提示词：文字相同的图片共用缓存键
+func TestPromptBuilderCacheKey(t *testing.T) {
+	content := []interface{}{
+		map[string]interface{}{"type": "image_url"},
+		map[string]interface{}{"type": "text", "text": "[Image #1] 这是什么"},
+		map[string]interface{}{"type": "image_url"},
+		map[string]interface{}{"type": "text", "text": "[Image #2] 这是什么"},
+		map[string]interface{}{"type": "image_url"},
+		map[string]interface{}{"type": "text", "text": "数一数有几个"},
+	}
+	options := ImageProcessOptions{Profile: vision.ProfileGeneral, ContextPrompt: true, ContextMaxChars: 100}
+	options.Vision.Model = "glm-4.5v"
+	builder := newPromptBuilder(options, "")
+
+	first, second, third := builder.build(content, 0), builder.build(content, 2), builder.build(content, 4)
+	if first.cacheKey() != second.cacheKey() {
+		t.Errorf("上下文相同的图片缓存键不同: %q / %q", first.cacheKey(), second.cacheKey())
+	}
+	if first.cacheKey() == third.cacheKey() {
+		t.Error("上下文不同的图片共用了缓存键")
+	}
+
+	// 未启用上下文提示词时所有图片共用默认提示词
+	options.ContextPrompt = false
+	builder = newPromptBuilder(options, "")
+	if key := builder.build(content, 4).cacheKey(); key != "" {
+		t.Errorf("未启用上下文提示词时标识 = %q，期望为空", key)
+	}
+}
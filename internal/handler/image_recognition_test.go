@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"glm-tool/config"
+	"glm-tool/internal/cache"
+	"glm-tool/internal/vision"
+)
+
+// useScheduler 使用指定并发上限的识别调度器
+func useScheduler(t *testing.T, limit int) {
+	t.Helper()
+	useRouteConfig(t, config.Config{})
+	schedulerOnce = sync.Once{}
+	schedulerOnce.Do(func() { defaultScheduler = newRecognitionScheduler(limit, time.Minute) })
+	t.Cleanup(func() {
+		schedulerOnce = sync.Once{}
+		defaultScheduler = nil
+	})
+}
+
+// testImage 生成第 n 张 1x1 的 PNG 图片（base64），不同 n 的图片内容不同
+func testImage(t *testing.T, n int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: uint8(n), G: uint8(n >> 8), B: 0x5a, A: 0xff})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// imageTasks 为 n 张不同的图片生成识别任务
+func imageTasks(t *testing.T, seed int, n int) []ImageTask {
+	t.Helper()
+	tasks := make([]ImageTask, n)
+	for i := range tasks {
+		data := testImage(t, seed+i)
+		tasks[i] = ImageTask{
+			SlotIndex:    i % 2,
+			ContentIndex: i,
+			Base64Data:   data,
+			ImageHash:    cache.ComputeHash(data),
+			ImageID:      fmt.Sprintf("#0_%d", i+1),
+			MediaType:    "image/png",
+		}
+	}
+	return tasks
+}
+
+// TestRecognizeImagesCancel 识别过程中取消请求后，排队中的图片不再调用视觉模型
+func TestRecognizeImagesCancel(t *testing.T) {
+	useScheduler(t, 1)
+
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		// 一直等到客户端取消
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tasks := imageTasks(t, 100, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan []ImageResult)
+	go func() {
+		done <- recognizeImagesConcurrently(ctx, tasks, "test", "", vision.VisionConfig{BaseURL: server.URL, Timeout: time.Minute})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待视觉模型调用超时")
+	}
+	// 其余图片都在排队等待名额时取消
+	waitFor(t, func() bool {
+		defaultScheduler.mu.Lock()
+		defer defaultScheduler.mu.Unlock()
+		return len(defaultScheduler.queue) == len(tasks)-1
+	})
+	cancel()
+
+	var results []ImageResult
+	select {
+	case results = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("取消后识别未结束")
+	}
+	if len(results) != len(tasks) {
+		t.Fatalf("返回 %d 个结果，期望 %d 个", len(results), len(tasks))
+	}
+	for _, result := range results {
+		if result.Success {
+			t.Errorf("图片 %d 在取消后仍识别成功", result.ContentIndex)
+		}
+	}
+	// 识别在独立的 context 中运行，等待它们全部退出
+	waitFor(t, func() bool {
+		defaultScheduler.mu.Lock()
+		defer defaultScheduler.mu.Unlock()
+		return defaultScheduler.running == 0 && len(defaultScheduler.queue) == 0
+	})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("视觉模型被调用 %d 次，期望取消后不再调用", n)
+	}
+}
+
+// TestRecognizeImagesOrder 识别完成的顺序与任务顺序不同时，结果仍按任务顺序返回
+func TestRecognizeImagesOrder(t *testing.T) {
+	const n = 5
+	useScheduler(t, n)
+
+	tasks := imageTasks(t, 200, n)
+	index := make(map[string]int)
+	for i, task := range tasks {
+		index["data:image/png;base64,"+task.Base64Data] = i
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content []struct {
+					ImageURL struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		i, ok := index[body.Messages[0].Content[0].ImageURL.URL]
+		if !ok {
+			http.Error(w, "unknown image", http.StatusBadRequest)
+			return
+		}
+		// 越靠前的图片越晚完成
+		time.Sleep(time.Duration(n-i) * 20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": fmt.Sprintf("图片 %d", i)}}},
+		})
+	}))
+	defer server.Close()
+
+	results := recognizeImagesConcurrently(context.Background(), tasks, "test", "", vision.VisionConfig{BaseURL: server.URL, Timeout: time.Minute})
+	if len(results) != n {
+		t.Fatalf("返回 %d 个结果，期望 %d 个", len(results), n)
+	}
+	for i, result := range results {
+		want := ImageResult{SlotIndex: tasks[i].SlotIndex, ContentIndex: tasks[i].ContentIndex, Text: fmt.Sprintf("图片 %d", i), Success: true}
+		if result != want {
+			t.Errorf("results[%d] = %+v，期望 %+v", i, result, want)
+		}
+	}
+}
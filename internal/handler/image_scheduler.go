@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"glm-tool/config"
+
+	"github.com/gophertool/tool/log"
+)
+
+// 识别任务的调度优先级
+const (
+	priorityHistory = 0 // 历史消息中的图片
+	priorityLatest  = 1 // 最新一条用户消息中的图片，优先识别
+)
+
+// errRecognitionQueueTimeout 等待识别名额超时
+var errRecognitionQueueTimeout = errors.New("等待图片识别队列超时")
+
+var (
+	schedulerOnce    sync.Once
+	defaultScheduler *recognitionScheduler
+)
+
+// getRecognitionScheduler 按配置延迟创建全局识别调度器
+func getRecognitionScheduler() *recognitionScheduler {
+	schedulerOnce.Do(func() {
+		defaultScheduler = newRecognitionScheduler(
+			config.AppConfig.VisionMaxConcurrency,
+			time.Duration(config.AppConfig.VisionQueueTimeoutSeconds)*time.Second,
+		)
+		log.Infof("图片识别并发上限: %d, 排队超时: %ds", config.AppConfig.VisionMaxConcurrency, config.AppConfig.VisionQueueTimeoutSeconds)
+	})
+	return defaultScheduler
+}
+
+// schedulerWaiter 排队等待识别名额的任务
+type schedulerWaiter struct {
+	key      string        // API Key，用于公平调度
+	priority int           // 调度优先级
+	seq      uint64        // 排队顺序
+	ready    chan struct{} // 获得名额时关闭
+	granted  bool          // 是否已获得名额
+}
+
+// recognitionScheduler 全局图片识别调度器：限制同时进行的视觉模型调用数量
+// 名额空出时按以下顺序选择排队的任务：优先级高的优先；同优先级时进行中任务较少、最久未获得名额的 API Key 优先；再按排队顺序
+type recognitionScheduler struct {
+	mu           sync.Mutex
+	limit        int               // 并发上限，0 表示不限制
+	queueTimeout time.Duration     // 排队超时，0 表示不限制
+	running      int               // 进行中的任务数
+	active       map[string]int    // 各 API Key 进行中的任务数
+	lastGrant    map[string]uint64 // 各 API Key 最近一次获得名额的序号（仅在有任务排队时记录）
+	grants       uint64
+	queue        []*schedulerWaiter
+	seq          uint64
+}
+
+// newRecognitionScheduler 创建识别调度器
+func newRecognitionScheduler(limit int, queueTimeout time.Duration) *recognitionScheduler {
+	return &recognitionScheduler{
+		limit:        limit,
+		queueTimeout: queueTimeout,
+		active:       make(map[string]int),
+		lastGrant:    make(map[string]uint64),
+	}
+}
+
+// acquire 获取一个识别名额，返回释放函数；ctx 取消或排队超时时返回错误
+func (s *recognitionScheduler) acquire(ctx context.Context, key string, priority int) (func(), error) {
+	if s.limit <= 0 {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	if s.running < s.limit && len(s.queue) == 0 {
+		s.grant(key)
+		s.mu.Unlock()
+		return s.releaseFunc(key), nil
+	}
+	s.seq++
+	w := &schedulerWaiter{key: key, priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.queue = append(s.queue, w)
+	log.Debugf("图片识别名额已满，排队等待（进行中: %d, 排队: %d）", s.running, len(s.queue))
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return s.releaseFunc(key), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = errRecognitionQueueTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.granted {
+		// 取消的同时已获得名额，交给调用方使用并释放
+		return s.releaseFunc(key), nil
+	}
+	for i, queued := range s.queue {
+		if queued == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	return nil, err
+}
+
+// grant 占用一个名额（需持有锁）
+func (s *recognitionScheduler) grant(key string) {
+	s.running++
+	s.active[key]++
+	s.grants++
+	if len(s.queue) > 0 {
+		s.lastGrant[key] = s.grants
+	}
+}
+
+// releaseFunc 返回只生效一次的释放函数
+func (s *recognitionScheduler) releaseFunc(key string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running--
+			if s.active[key]--; s.active[key] <= 0 {
+				delete(s.active, key)
+			}
+			s.dispatch()
+		})
+	}
+}
+
+// dispatch 将空出的名额分配给排队的任务（需持有锁）
+func (s *recognitionScheduler) dispatch() {
+	for s.running < s.limit && len(s.queue) > 0 {
+		best := 0
+		for i, w := range s.queue[1:] {
+			if s.before(w, s.queue[best]) {
+				best = i + 1
+			}
+		}
+		w := s.queue[best]
+		s.queue = append(s.queue[:best], s.queue[best+1:]...)
+		s.grant(w.key)
+		w.granted = true
+		close(w.ready)
+	}
+	if len(s.queue) == 0 {
+		clear(s.lastGrant)
+	}
+}
+
+// before 排队任务 a 是否应先于 b 获得名额
+func (s *recognitionScheduler) before(a, b *schedulerWaiter) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if s.active[a.key] != s.active[b.key] {
+		return s.active[a.key] < s.active[b.key]
+	}
+	if s.lastGrant[a.key] != s.lastGrant[b.key] {
+		return s.lastGrant[a.key] < s.lastGrant[b.key]
+	}
+	return a.seq < b.seq
+}
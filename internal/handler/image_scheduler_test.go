@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// queuedTask 排队的识别任务
+type queuedTask struct {
+	name     string
+	key      string
+	priority int
+}
+
+// grantedTask 获得名额的排队任务
+type grantedTask struct {
+	name    string
+	release func()
+}
+
+// enqueue 发起一个排队任务，等到它进入队列后返回，保证排队顺序确定
+func enqueue(t *testing.T, s *recognitionScheduler, name string, key string, priority int, granted chan<- grantedTask) {
+	t.Helper()
+	s.mu.Lock()
+	queued := len(s.queue)
+	s.mu.Unlock()
+	go func() {
+		release, err := s.acquire(context.Background(), key, priority)
+		if err != nil {
+			t.Errorf("%s 获取名额失败: %v", name, err)
+			return
+		}
+		granted <- grantedTask{name: name, release: release}
+	}()
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queue) == queued+1
+	})
+}
+
+// grantOrder 释放 holder 后逐个释放获得名额的任务，返回获得名额的顺序
+func grantOrder(t *testing.T, holder func(), granted <-chan grantedTask, n int) []string {
+	t.Helper()
+	holder()
+	var order []string
+	for i := 0; i < n; i++ {
+		select {
+		case task := <-granted:
+			order = append(order, task.name)
+			task.release()
+		case <-time.After(2 * time.Second):
+			t.Fatalf("等待名额超时，已获得名额: %v", order)
+		}
+	}
+	return order
+}
+
+func TestRecognitionSchedulerOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []queuedTask
+		want  []string
+	}{
+		{
+			name: "各 API Key 轮流获得名额",
+			tasks: []queuedTask{
+				{"a1", "key-a", priorityHistory},
+				{"a2", "key-a", priorityHistory},
+				{"a3", "key-a", priorityHistory},
+				{"b1", "key-b", priorityHistory},
+				{"b2", "key-b", priorityHistory},
+			},
+			want: []string{"a1", "b1", "a2", "b2", "a3"},
+		},
+		{
+			name: "最新消息中的图片优先",
+			tasks: []queuedTask{
+				{"history1", "key-a", priorityHistory},
+				{"history2", "key-b", priorityHistory},
+				{"latest", "key-a", priorityLatest},
+			},
+			want: []string{"latest", "history2", "history1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRecognitionScheduler(1, 0)
+			holder, err := s.acquire(context.Background(), "holder", priorityHistory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			granted := make(chan grantedTask, len(tt.tasks))
+			for _, task := range tt.tasks {
+				enqueue(t, s, task.name, task.key, task.priority, granted)
+			}
+			if got := grantOrder(t, holder, granted, len(tt.tasks)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("获得名额的顺序 = %v，期望 %v", got, tt.want)
+			}
+			if s.running != 0 || len(s.active) != 0 || len(s.lastGrant) != 0 {
+				t.Errorf("全部释放后 running=%d active=%v lastGrant=%v", s.running, s.active, s.lastGrant)
+			}
+		})
+	}
+}
+
+func TestRecognitionSchedulerPrefersIdleKey(t *testing.T) {
+	// key-a 已有任务在进行时，名额优先给没有进行中任务的 key-b
+	s := newRecognitionScheduler(2, 0)
+	running, _ := s.acquire(context.Background(), "key-a", priorityHistory)
+	defer running()
+	holder, _ := s.acquire(context.Background(), "holder", priorityHistory)
+
+	granted := make(chan grantedTask, 2)
+	enqueue(t, s, "a", "key-a", priorityHistory, granted)
+	enqueue(t, s, "b", "key-b", priorityHistory, granted)
+	if got := grantOrder(t, holder, granted, 2); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("获得名额的顺序 = %v，期望 [b a]", got)
+	}
+}
+
+func TestRecognitionSchedulerCancel(t *testing.T) {
+	s := newRecognitionScheduler(1, 20*time.Millisecond)
+	holder, _ := s.acquire(context.Background(), "key", priorityHistory)
+
+	if _, err := s.acquire(context.Background(), "key", priorityHistory); !errors.Is(err, errRecognitionQueueTimeout) {
+		t.Errorf("排队超时 err = %v，期望 errRecognitionQueueTimeout", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.acquire(ctx, "key", priorityHistory); !errors.Is(err, context.Canceled) {
+		t.Errorf("取消 err = %v，期望 context.Canceled", err)
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("放弃排队的任务仍在队列中: %d", len(s.queue))
+	}
+
+	// 释放函数重复调用只生效一次
+	holder()
+	holder()
+	if s.running != 0 {
+		t.Errorf("running = %d，期望 0", s.running)
+	}
+	release, err := s.acquire(context.Background(), "key", priorityHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestRecognitionSchedulerUnlimited(t *testing.T) {
+	s := newRecognitionScheduler(0, 0)
+	for i := 0; i < 10; i++ {
+		if _, err := s.acquire(context.Background(), "key", priorityHistory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.queue) != 0 {
+		t.Error("不限制并发时不应排队")
+	}
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"glm-tool/internal/cache"
+	"glm-tool/internal/debuglog"
+	"glm-tool/internal/translate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gophertool/tool/log"
+)
+
+// Responses 处理 OpenAI Responses API 请求：转换为 chat/completions 后转发，再转换回 Responses 格式
+func (h *Handler) Responses(c *gin.Context) {
+	var requestData map[string]any
+
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		log.Warnf("解析 Responses 请求失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "无效的请求格式",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		log.Warnf("缺少 Authorization header")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"message": "缺少 API Key",
+				"type":    "authentication_error",
+			},
+		})
+		return
+	}
+	owner := responseOwner(authHeader)
+
+	// 读取 previous_response_id 对应的历史对话
+	var history []any
+	if previousID, ok := requestData["previous_response_id"].(string); ok && previousID != "" {
+		entry, found := h.responses.Get(previousID, owner)
+		if !found {
+			log.Warnf("未找到 previous_response_id: %s", previousID)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": fmt.Sprintf("Previous response with id '%s' not found.", previousID),
+					"type":    "invalid_request_error",
+					"param":   "previous_response_id",
+				},
+			})
+			return
+		}
+		history = entry.Messages
+	}
+
+	imageOptions, err := imageProcessOptions(c, requestData)
+	if err != nil {
+		log.Warnf("图片识别配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	chatReq, conversation, err := translate.ResponsesToChatRequest(requestData, history)
+	if err != nil {
+		log.Warnf("转换 Responses 请求失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	// [调试中间件] 识别图片并转换为文本
+	// 目标模型支持图片输入时图片原样转发（PDF 文档仍然展开），只为纯文本模型识别图片
+	model, _ := chatReq["model"].(string)
+	imageOptions.SkipImages = supportsImageInput(model)
+	imageStats, err := ProcessImageToText(c.Request.Context(), chatReq, authHeader, imageOptions)
+	setImageStatsHeader(c, imageStats)
+	if err != nil {
+		if clientGone(c) {
+			log.Infof("客户端已断开，取消请求")
+			return
+		}
+		var recErr *ImageRecognitionError
+		if errors.As(err, &recErr) {
+			log.Warnf("图片识别失败，按策略返回错误: %v", err)
+			abortImageRecognitionError(c, recErr)
+			return
+		}
+		log.Warnf("图片处理失败: %v", err)
+	}
+
+	// store 默认为 true
+	store := true
+	if v, ok := requestData["store"].(bool); ok {
+		store = v
+	}
+	responseID := translate.NewResponseID()
+	save := func(resp map[string]any, assistantMessage map[string]any) {
+		if !store {
+			return
+		}
+		messages := append(conversation, assistantMessage)
+		h.responses.Save(responseID, owner, resp, messages)
+	}
+
+	if stream, ok := requestData["stream"].(bool); ok && stream {
+		log.Infof("处理 Responses 流式请求")
+		converter := translate.NewChatToResponsesStream(responseID, requestData, save)
+		if err := h.proxy.ForwardStreamRequestWithConverter(c, chatReq, authHeader, converter); err != nil {
+			log.Warnf("转发 Responses 流式请求失败: %v", err)
+			writeResponsesError(c, err, converter)
+		}
+		return
+	}
+
+	chatResp, err := h.proxy.ForwardRequest(c.Request.Context(), chatReq, authHeader)
+
+	var respData map[string]any
+	if err == nil {
+		var assistantMessage map[string]any
+		respData, assistantMessage = translate.ChatToResponsesResponse(chatResp, responseID, requestData)
+		save(respData, assistantMessage)
+	}
+
+	// 记录 debug 日志
+	debuglog.LogRequest(requestData, respData, err)
+
+	if err != nil {
+		log.Warnf("转发 Responses 请求失败: %v", err)
+		writeOpenAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, respData)
+}
+
+// GetResponse 读取已保存的 response 对象
+func (h *Handler) GetResponse(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"message": "缺少 API Key",
+				"type":    "authentication_error",
+			},
+		})
+		return
+	}
+
+	responseID := c.Param("id")
+	entry, found := h.responses.Get(responseID, responseOwner(authHeader))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Response with id '%s' not found.", responseID),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry.Response)
+}
+
+// DeleteResponse 删除已保存的 response 对象
+func (h *Handler) DeleteResponse(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"message": "缺少 API Key",
+				"type":    "authentication_error",
+			},
+		})
+		return
+	}
+
+	responseID := c.Param("id")
+	if !h.responses.Delete(responseID, responseOwner(authHeader)) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Response with id '%s' not found.", responseID),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseID,
+		"object":  "response.deleted",
+		"deleted": true,
+	})
+}
+
+// responseOwner 根据 API Key 计算响应记录的归属
+func responseOwner(authHeader string) string {
+	return cache.ComputeHash(strings.TrimPrefix(authHeader, "Bearer "))
+}
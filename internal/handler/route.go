@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"strings"
+
+	"glm-tool/config"
+)
+
+// useAnthropicForChat 判断 OpenAI 格式的请求是否应通过 Anthropic 上游处理
+func useAnthropicForChat(model string) bool {
+	if config.AppConfig.ChatCompletionsUpstream == "anthropic" {
+		return true
+	}
+	return config.MatchModel(config.AppConfig.ChatAnthropicModels, model)
+}
+
+// useOpenAIForMessages 判断 Anthropic 格式的请求是否应通过 OpenAI 上游处理
+func useOpenAIForMessages(model string) bool {
+	if config.AppConfig.MessagesUpstream == "openai" {
+		return true
+	}
+	return config.MatchModel(config.AppConfig.MessagesOpenAIModels, model)
+}
+
+// supportsImageInput 判断目标模型是否能直接处理图片（无需先转换为文本）
+func supportsImageInput(model string) bool {
+	return config.MatchModel(config.AppConfig.VisionNativeModels, model)
+}
+
+// bearerAuth 确保 Authorization 带有 Bearer 前缀（x-api-key 传入的是裸 key）
+func bearerAuth(authHeader string) string {
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return authHeader
+	}
+	return "Bearer " + authHeader
+}
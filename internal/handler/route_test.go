@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"glm-tool/config"
+)
+
+// useRouteConfig 使用测试的模型路由配置
+func useRouteConfig(t *testing.T, cfg config.Config) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func TestModelRouting(t *testing.T) {
+	useRouteConfig(t, config.Config{
+		VisionNativeModels:   []string{"glm-4.6v*", "GLM-4.5V", "qwen-vl-?"},
+		ChatAnthropicModels:  []string{"claude-*"},
+		MessagesOpenAIModels: []string{"gpt-4o*", "glm-4.?"},
+	})
+
+	tests := []struct {
+		model         string
+		wantNative    bool
+		wantAnthropic bool
+		wantOpenAI    bool
+	}{
+		{model: "glm-4.6v", wantNative: true},
+		{model: "glm-4.6v-flash", wantNative: true},
+		{model: "glm-4.5v", wantNative: true},
+		{model: "GLM-4.5v", wantNative: true},
+		{model: "glm-4.5v-x"},
+		{model: "qwen-vl-2", wantNative: true},
+		{model: "qwen-vl-10"},
+		{model: "glm-4.6", wantOpenAI: true},
+		{model: "claude-sonnet-4", wantAnthropic: true},
+		{model: "claude"},
+		{model: "gpt-4o-mini", wantOpenAI: true},
+		{model: "openai/gpt-4o"}, // * 不匹配 /
+		{model: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := supportsImageInput(tt.model); got != tt.wantNative {
+				t.Errorf("supportsImageInput = %v，期望 %v", got, tt.wantNative)
+			}
+			if got := useAnthropicForChat(tt.model); got != tt.wantAnthropic {
+				t.Errorf("useAnthropicForChat = %v，期望 %v", got, tt.wantAnthropic)
+			}
+			if got := useOpenAIForMessages(tt.model); got != tt.wantOpenAI {
+				t.Errorf("useOpenAIForMessages = %v，期望 %v", got, tt.wantOpenAI)
+			}
+		})
+	}
+
+	// 全局上游配置优先于模型名模式
+	useRouteConfig(t, config.Config{ChatCompletionsUpstream: "anthropic", MessagesUpstream: "openai"})
+	if !useAnthropicForChat("glm-4.6") || !useOpenAIForMessages("claude-sonnet-4") {
+		t.Error("全局上游配置未生效")
+	}
+}
+
+// TestSkipImagesExpandsDocuments 目标模型支持图片输入时图片原样转发，PDF 文档仍然展开
+func TestSkipImagesExpandsDocuments(t *testing.T) {
+	useRouteConfig(t, config.Config{PDFEnabled: true})
+
+	tests := []struct {
+		name    string
+		body    string
+		process func(context.Context, map[string]any, string, ImageProcessOptions) (ImageStats, error)
+		want    []string
+	}{
+		{
+			name: "OpenAI",
+			body: `{"messages":[{"role":"user","content":[
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"file","file":{"filename":"a.pdf","file_data":"data:application/pdf;base64,!!!"}}]}]}`,
+			process: ProcessImageToText,
+			want:    []string{"image_url", "[PDF 文档: a.pdf，解析失败: 无效的 base64 数据]"},
+		},
+		{
+			name: "Anthropic",
+			body: `{"messages":[{"role":"user","content":[
+				{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}},
+				{"type":"document","title":"b.pdf","source":{"type":"base64","media_type":"application/pdf","data":"!!!"}}]}]}`,
+			process: ProcessImageToTextForAnthropic,
+			want:    []string{"image", "[PDF 文档: b.pdf，解析失败: 无效的 base64 数据]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			stats, err := tt.process(context.Background(), body, "Bearer test", ImageProcessOptions{SkipImages: true})
+			if err != nil || stats != (ImageStats{}) {
+				t.Errorf("stats = %+v, err = %v，期望不识别图片", stats, err)
+			}
+			content := body["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+			if got := contentTypes(content); !slices.Equal(got, tt.want) {
+				t.Errorf("content = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"glm-tool/config"
+	"glm-tool/internal/vision"
+
+	"github.com/gin-gonic/gin"
+)
+
+// visionOptionsField 请求体中覆盖视觉模型配置的扩展字段，转发前会被移除
+const visionOptionsField = "vision_options"
+
+// ImageProcessOptions 图片识别的请求级配置
+type ImageProcessOptions struct {
+	Vision  vision.VisionConfig // 视觉模型配置
+	Profile string              // 识别提示词模板
+
+	ContextPrompt        bool // 是否根据图片周围的文字构建识别提示词
+	ContextIncludeSystem bool // 上下文提示词是否包含系统提示词
+	ContextMaxChars      int  // 上下文的最大字符数
+
+	FailurePolicy string // 图片识别失败时的处理策略
+
+	SkipImages bool // 目标模型支持图片输入：只展开 PDF 文档，图片原样转发
+}
+
+// defaultVisionConfig 由环境变量生成的视觉模型配置
+func defaultVisionConfig() vision.VisionConfig {
+	return vision.VisionConfig{
+		BaseURL:       config.AppConfig.VisionBaseURL,
+		Model:         config.AppConfig.VisionModel,
+		Temperature:   config.AppConfig.VisionTemperature,
+		TopP:          config.AppConfig.VisionTopP,
+		MaxTokens:     config.AppConfig.VisionMaxTokens,
+		Thinking:      config.AppConfig.VisionThinking,
+		Timeout:       time.Duration(config.AppConfig.VisionTimeoutSeconds) * time.Second,
+		APIKey:        config.AppConfig.VisionAPIKey,
+		ClassifyModel: config.AppConfig.VisionClassifyModel,
+	}
+}
+
+// imageProcessOptions 读取请求级的图片识别配置
+// 优先级：请求体 vision_options 字段 > 请求头 > 环境变量
+// 出于安全考虑，接口地址和 API Key 只能通过环境变量配置
+func imageProcessOptions(c *gin.Context, requestData map[string]any) (ImageProcessOptions, error) {
+	options := ImageProcessOptions{
+		Vision:               defaultVisionConfig(),
+		Profile:              config.AppConfig.VisionProfile,
+		ContextPrompt:        config.AppConfig.ImageContextPrompt,
+		ContextIncludeSystem: config.AppConfig.ImageContextIncludeSystem,
+		ContextMaxChars:      config.AppConfig.ImageContextMaxChars,
+		FailurePolicy:        config.AppConfig.ImageFailurePolicy,
+	}
+
+	// 请求头
+	if model := strings.TrimSpace(c.GetHeader("X-Vision-Model")); model != "" {
+		options.Vision.Model = model
+	}
+	if thinking := c.GetHeader("X-Vision-Thinking"); thinking != "" {
+		enabled, err := strconv.ParseBool(thinking)
+		if err != nil {
+			return options, fmt.Errorf("X-Vision-Thinking 格式无效: %s", thinking)
+		}
+		options.Vision.Thinking = enabled
+	}
+	if profile := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Vision-Profile"))); profile != "" {
+		if !vision.IsProfile(profile) {
+			return options, fmt.Errorf("X-Vision-Profile 无效: %s（支持: %s）", profile, strings.Join(vision.ProfileNames(), ", "))
+		}
+		options.Profile = profile
+	}
+
+	// 请求体扩展字段
+	raw, exists := requestData[visionOptionsField]
+	if !exists {
+		return options, nil
+	}
+	delete(requestData, visionOptionsField)
+
+	overrides, ok := raw.(map[string]any)
+	if !ok {
+		return options, fmt.Errorf("%s 必须是对象", visionOptionsField)
+	}
+	for key, value := range overrides {
+		switch key {
+		case "model":
+			model, ok := value.(string)
+			if !ok || strings.TrimSpace(model) == "" {
+				return options, fmt.Errorf("%s.model 必须是非空字符串", visionOptionsField)
+			}
+			options.Vision.Model = strings.TrimSpace(model)
+		case "temperature":
+			v, ok := value.(float64)
+			if !ok || v < 0 || v > 2 {
+				return options, fmt.Errorf("%s.temperature 必须是 0-2 之间的数字", visionOptionsField)
+			}
+			options.Vision.Temperature = v
+		case "top_p":
+			v, ok := value.(float64)
+			if !ok || v <= 0 || v > 1 {
+				return options, fmt.Errorf("%s.top_p 必须是 (0, 1] 之间的数字", visionOptionsField)
+			}
+			options.Vision.TopP = v
+		case "max_tokens":
+			v, ok := value.(float64)
+			if !ok || v < 1 || v != float64(int(v)) {
+				return options, fmt.Errorf("%s.max_tokens 必须是正整数", visionOptionsField)
+			}
+			options.Vision.MaxTokens = int(v)
+		case "thinking":
+			v, ok := value.(bool)
+			if !ok {
+				return options, fmt.Errorf("%s.thinking 必须是布尔值", visionOptionsField)
+			}
+			options.Vision.Thinking = v
+		case "profile":
+			v, ok := value.(string)
+			if !ok || !vision.IsProfile(strings.ToLower(v)) {
+				return options, fmt.Errorf("%s.profile 必须是以下之一: %s", visionOptionsField, strings.Join(vision.ProfileNames(), ", "))
+			}
+			options.Profile = strings.ToLower(v)
+		case "context_prompt":
+			v, ok := value.(bool)
+			if !ok {
+				return options, fmt.Errorf("%s.context_prompt 必须是布尔值", visionOptionsField)
+			}
+			options.ContextPrompt = v
+		case "failure_policy":
+			v, ok := value.(string)
+			if !ok || !isFailurePolicy(strings.ToLower(v)) {
+				return options, fmt.Errorf("%s.failure_policy 必须是以下之一: keep, placeholder, drop, fail", visionOptionsField)
+			}
+			options.FailurePolicy = strings.ToLower(v)
+		default:
+			return options, fmt.Errorf("%s 不支持的字段: %s", visionOptionsField, key)
+		}
+	}
+	return options, nil
+}
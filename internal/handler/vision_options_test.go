@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"glm-tool/config"
+	"glm-tool/internal/vision"
+
+	"github.com/gin-gonic/gin"
+)
+
+// visionOptionsContext 带有指定请求头的请求上下文
+func visionOptionsContext(headers map[string]string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func TestImageProcessOptions(t *testing.T) {
+	useRouteConfig(t, config.Config{
+		VisionModel:        "glm-4.5v",
+		VisionProfile:      vision.ProfileGeneral,
+		VisionTemperature:  0.8,
+		VisionMaxTokens:    1024,
+		ImageFailurePolicy: FailurePolicyKeep,
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		wantErr string // 错误信息中应包含的内容，为空表示期望成功
+		check   func(t *testing.T, options ImageProcessOptions)
+	}{
+		{
+			name: "默认使用环境变量",
+			body: `{}`,
+			check: func(t *testing.T, options ImageProcessOptions) {
+				if options.Vision.Model != "glm-4.5v" || options.Profile != vision.ProfileGeneral || options.FailurePolicy != FailurePolicyKeep {
+					t.Errorf("options = %+v", options)
+				}
+			},
+		},
+		{
+			name:    "请求头",
+			headers: map[string]string{"X-Vision-Model": " glm-4.6v ", "X-Vision-Thinking": "true", "X-Vision-Profile": "OCR"},
+			body:    `{}`,
+			check: func(t *testing.T, options ImageProcessOptions) {
+				if options.Vision.Model != "glm-4.6v" || !options.Vision.Thinking || options.Profile != vision.ProfileOCR {
+					t.Errorf("options = %+v", options)
+				}
+			},
+		},
+		{
+			name:    "请求体优先于请求头",
+			headers: map[string]string{"X-Vision-Model": "glm-4.6v", "X-Vision-Profile": "ocr"},
+			body:    `{"vision_options": {"model": "glm-4.1v", "profile": "Chart", "temperature": 0, "top_p": 1, "max_tokens": 256, "thinking": true, "context_prompt": true, "failure_policy": "FAIL"}}`,
+			check: func(t *testing.T, options ImageProcessOptions) {
+				v := options.Vision
+				if v.Model != "glm-4.1v" || v.Temperature != 0 || v.TopP != 1 || v.MaxTokens != 256 || !v.Thinking {
+					t.Errorf("视觉模型配置 = %+v", v)
+				}
+				if options.Profile != vision.ProfileChart || !options.ContextPrompt || options.FailurePolicy != FailurePolicyFail {
+					t.Errorf("options = %+v", options)
+				}
+			},
+		},
+		{name: "X-Vision-Thinking 格式无效", headers: map[string]string{"X-Vision-Thinking": "maybe"}, body: `{}`, wantErr: "X-Vision-Thinking"},
+		{name: "X-Vision-Profile 未知", headers: map[string]string{"X-Vision-Profile": "photo"}, body: `{}`, wantErr: "X-Vision-Profile"},
+		{name: "vision_options 不是对象", body: `{"vision_options": "ocr"}`, wantErr: "必须是对象"},
+		{name: "vision_options 为数组", body: `{"vision_options": [{"profile": "ocr"}]}`, wantErr: "必须是对象"},
+		{name: "vision_options 为 null", body: `{"vision_options": null}`, wantErr: "必须是对象"},
+		{name: "未知字段", body: `{"vision_options": {"api_key": "sk-x"}}`, wantErr: "不支持的字段: api_key"},
+		{name: "model 为空", body: `{"vision_options": {"model": " "}}`, wantErr: "model"},
+		{name: "temperature 超出范围", body: `{"vision_options": {"temperature": 2.5}}`, wantErr: "temperature"},
+		{name: "temperature 为负数", body: `{"vision_options": {"temperature": -0.1}}`, wantErr: "temperature"},
+		{name: "temperature 类型错误", body: `{"vision_options": {"temperature": "0.5"}}`, wantErr: "temperature"},
+		{name: "top_p 为 0", body: `{"vision_options": {"top_p": 0}}`, wantErr: "top_p"},
+		{name: "top_p 超出范围", body: `{"vision_options": {"top_p": 1.1}}`, wantErr: "top_p"},
+		{name: "max_tokens 为 0", body: `{"vision_options": {"max_tokens": 0}}`, wantErr: "max_tokens"},
+		{name: "max_tokens 不是整数", body: `{"vision_options": {"max_tokens": 1.5}}`, wantErr: "max_tokens"},
+		{name: "thinking 类型错误", body: `{"vision_options": {"thinking": "yes"}}`, wantErr: "thinking"},
+		{name: "profile 未知", body: `{"vision_options": {"profile": "photo"}}`, wantErr: "profile"},
+		{name: "context_prompt 类型错误", body: `{"vision_options": {"context_prompt": 1}}`, wantErr: "context_prompt"},
+		{name: "failure_policy 未知", body: `{"vision_options": {"failure_policy": "retry"}}`, wantErr: "failure_policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestData map[string]any
+			if err := json.Unmarshal([]byte(tt.body), &requestData); err != nil {
+				t.Fatal(err)
+			}
+			options, err := imageProcessOptions(visionOptionsContext(tt.headers), requestData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 扩展字段不转发给上游
+			if _, exists := requestData[visionOptionsField]; exists {
+				t.Errorf("%s 字段未从请求中删除", visionOptionsField)
+			}
+			tt.check(t, options)
+		})
+	}
+}
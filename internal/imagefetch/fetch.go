@@ -0,0 +1,244 @@
+package imagefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"glm-tool/config"
+
+	"github.com/gophertool/tool/log"
+)
+
+// maxRedirects 最多跟随的重定向次数
+const maxRedirects = 3
+
+// ErrDisabled 未启用远程图片下载
+var ErrDisabled = errors.New("远程图片下载未启用")
+
+// blockedPrefixes 私有网络、回环、链路本地等不允许访问的地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试网络
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64
+}
+
+// Options 远程图片下载配置
+type Options struct {
+	MaxBytes     int64         // 单张图片的最大字节数
+	Timeout      time.Duration // 单次下载的总超时时间（包括重定向）
+	AllowedHosts []string      // 允许下载的域名模式（支持通配符），为空时不限制
+	AllowPrivate bool          // 是否允许访问私有网络和回环地址
+}
+
+// Image 下载得到的图片
+type Image struct {
+	Data      []byte
+	MediaType string // 根据文件内容嗅探得到的类型，如 image/png
+}
+
+// Fetcher 远程图片下载器，带 SSRF 防护
+type Fetcher struct {
+	options Options
+	client  *http.Client
+}
+
+// New 创建下载器
+// 地址检查在建立连接时对解析后的 IP 进行，重定向和 DNS 重绑定都无法绕过
+func New(options Options) *Fetcher {
+	f := &Fetcher{options: options}
+
+	dialer := &net.Dialer{
+		Timeout: options.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return fmt.Errorf("无效的地址: %s", host)
+			}
+			if !options.AllowPrivate && isBlockedAddr(addr) {
+				return fmt.Errorf("禁止访问内网地址: %s", addr)
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: options.Timeout,
+		Transport: &http.Transport{
+			// 不使用环境变量中的代理，否则地址检查只会作用于代理本身
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   options.Timeout,
+			ResponseHeaderTimeout: options.Timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("重定向次数过多")
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Fetch 下载图片，校验大小和内容类型
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的图片地址: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "image/*")
+	req.Header.Set("User-Agent", "glm-tool")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败 (状态码: %d)", resp.StatusCode)
+	}
+	if f.options.MaxBytes > 0 && resp.ContentLength > f.options.MaxBytes {
+		return nil, fmt.Errorf("图片过大: %d 字节（上限 %d 字节）", resp.ContentLength, f.options.MaxBytes)
+	}
+
+	reader := io.Reader(resp.Body)
+	if f.options.MaxBytes > 0 {
+		reader = io.LimitReader(resp.Body, f.options.MaxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	if f.options.MaxBytes > 0 && int64(len(data)) > f.options.MaxBytes {
+		return nil, fmt.Errorf("图片过大（上限 %d 字节）", f.options.MaxBytes)
+	}
+
+	// 不信任响应头中的 Content-Type，以文件内容为准
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("不是图片内容: %s", mediaType)
+	}
+
+	return &Image{Data: data, MediaType: mediaType}, nil
+}
+
+// checkURL 检查协议和域名白名单；IP 字面量地址在这里提前拒绝，域名在连接时检查
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("不支持的图片地址协议: %s", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("图片地址缺少主机名")
+	}
+	if len(f.options.AllowedHosts) > 0 && !matchHost(f.options.AllowedHosts, host) {
+		return fmt.Errorf("图片地址不在白名单中: %s", host)
+	}
+	if !f.options.AllowPrivate {
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return fmt.Errorf("禁止访问内网地址: %s", host)
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && isBlockedAddr(addr) {
+			return fmt.Errorf("禁止访问内网地址: %s", host)
+		}
+	}
+	return nil
+}
+
+// matchHost 域名是否匹配任一模式（如 *.example.com）
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), host); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlockedAddr 是否为私有网络、回环、链路本地、组播等地址
+func isBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	defaultFetcher *Fetcher
+	once           sync.Once
+)
+
+// getFetcher 按配置延迟创建默认下载器，未启用时返回 nil
+func getFetcher() *Fetcher {
+	once.Do(func() {
+		if !config.AppConfig.ImageFetchEnabled {
+			return
+		}
+		defaultFetcher = New(Options{
+			MaxBytes:     config.AppConfig.ImageFetchMaxBytes,
+			Timeout:      time.Duration(config.AppConfig.ImageFetchTimeoutSeconds) * time.Second,
+			AllowedHosts: config.AppConfig.ImageFetchAllowedHosts,
+			AllowPrivate: config.AppConfig.ImageFetchAllowPrivate,
+		})
+		log.Infof("远程图片下载已启用 (上限 %d 字节, 白名单 %v)", config.AppConfig.ImageFetchMaxBytes, config.AppConfig.ImageFetchAllowedHosts)
+	})
+	return defaultFetcher
+}
+
+// Enabled 是否启用远程图片下载
+func Enabled() bool {
+	return getFetcher() != nil
+}
+
+// IsRemoteURL 是否为 http(s) 图片地址
+func IsRemoteURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Fetch 使用默认配置下载远程图片
+func Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	f := getFetcher()
+	if f == nil {
+		return nil, ErrDisabled
+	}
+	return f.Fetch(ctx, rawURL)
+}
@@ -0,0 +1,155 @@
+package imagefetch
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsBlockedAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // 云服务元数据地址
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"::", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+		{"::ffff:127.0.0.1", true}, // IPv4 映射地址
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2001:4860:4860::8888", false},
+		{"::ffff:8.8.8.8", false},
+	}
+	for _, tt := range tests {
+		if got := isBlockedAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isBlockedAddr(%s) = %v，期望 %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		url     string
+		wantErr string
+	}{
+		{name: "公网地址", url: "https://example.com/cat.png"},
+		{name: "公网 IP", url: "http://8.8.8.8/cat.png"},
+		{name: "不支持的协议", url: "file:///etc/passwd", wantErr: "协议"},
+		{name: "FTP", url: "ftp://example.com/cat.png", wantErr: "协议"},
+		{name: "缺少主机名", url: "http:///cat.png", wantErr: "主机名"},
+		{name: "localhost", url: "http://localhost:8080/", wantErr: "内网"},
+		{name: "localhost 子域名", url: "http://api.LOCALHOST/", wantErr: "内网"},
+		{name: "回环 IP", url: "http://127.0.0.1/", wantErr: "内网"},
+		{name: "IPv6 回环", url: "http://[::1]/", wantErr: "内网"},
+		{name: "元数据地址", url: "http://169.254.169.254/latest/meta-data", wantErr: "内网"},
+		{name: "允许内网", options: Options{AllowPrivate: true}, url: "http://127.0.0.1/"},
+		{name: "白名单通配符", options: Options{AllowedHosts: []string{"*.Example.com"}}, url: "https://cdn.example.com/cat.png"},
+		{name: "不在白名单", options: Options{AllowedHosts: []string{"*.example.com"}}, url: "https://example.org/cat.png", wantErr: "白名单"},
+		{name: "白名单不匹配根域名", options: Options{AllowedHosts: []string{"*.example.com"}}, url: "https://example.com/cat.png", wantErr: "白名单"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = New(tt.options).checkURL(u)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkURL(%s) = %v，期望通过", tt.url, err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkURL(%s) = %v，期望包含 %q", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// pngData 生成 1x1 的 PNG
+func pngData(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetch(t *testing.T) {
+	data := pngData(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "text/plain") // 以文件内容为准
+			w.Write(data)
+		case "/text":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<html>not an image</html>"))
+		case "/large":
+			w.Write(bytes.Repeat([]byte{0}, 1024))
+		case "/redirect":
+			// 重定向到同一服务的另一个主机名
+			_, port, _ := net.SplitHostPort(r.Host)
+			http.Redirect(w, r, "http://localhost:"+port+"/cat.png", http.StatusFound)
+		case "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options Options
+		path    string
+		wantErr string
+	}{
+		{name: "默认禁止内网", options: Options{}, path: "/cat.png", wantErr: "内网"},
+		{name: "下载图片", options: Options{AllowPrivate: true}, path: "/cat.png"},
+		{name: "不是图片", options: Options{AllowPrivate: true}, path: "/text", wantErr: "不是图片"},
+		{name: "超过大小上限", options: Options{AllowPrivate: true, MaxBytes: 100}, path: "/large", wantErr: "过大"},
+		{name: "状态码错误", options: Options{AllowPrivate: true}, path: "/missing", wantErr: "状态码"},
+		{name: "重定向到白名单之外", options: Options{AllowPrivate: true, AllowedHosts: []string{"127.0.0.1"}}, path: "/redirect", wantErr: "白名单"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Timeout = 5 * time.Second
+			img, err := New(tt.options).Fetch(context.Background(), server.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.MediaType != "image/png" || !bytes.Equal(img.Data, data) {
+				t.Errorf("下载结果类型 %s，%d 字节", img.MediaType, len(img.Data))
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/png"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// 动画抽帧方式
+const (
+	SamplingKeyframes = "keyframes" // 第一帧、中间帧、最后一帧
+	SamplingInterval  = "interval"  // 每隔 N 帧抽取一帧，N 为 0 时在整个动画中均匀抽取
+)
+
+// maxCanvasPixels 动画画布的像素上限，超过时按静态图片处理
+const maxCanvasPixels = 16 * 1000 * 1000
+
+// maxAnimationFrames 动画帧数上限，超过时按静态图片处理
+const maxAnimationFrames = 1000
+
+// maxGIFPixels GIF 所有帧的像素总数上限（gif.DecodeAll 会同时解码全部帧）
+const maxGIFPixels = 64 * 1000 * 1000
+
+// FrameOptions 动画抽帧配置
+type FrameOptions struct {
+	Sampling  string // SamplingKeyframes 或 SamplingInterval
+	Interval  int    // SamplingInterval 的抽帧间隔，0 表示均匀抽取
+	MaxFrames int    // 最多抽取的帧数
+}
+
+// Frame 抽取的一帧（已与之前的帧合成为完整画面）
+type Frame struct {
+	Image     image.Image
+	Index     int           // 帧序号（从 0 开始）
+	Timestamp time.Duration // 该帧开始显示的时间
+}
+
+// Animation 抽帧结果
+type Animation struct {
+	TotalFrames int           // 动画总帧数
+	Duration    time.Duration // 动画总时长
+	Frames      []Frame       // 按时间顺序抽取的帧
+}
+
+// 帧显示结束后画布区域的处理方式
+const (
+	disposeNone       = iota // 保留
+	disposeBackground        // 清除为透明
+	disposePrevious          // 恢复为绘制该帧之前的内容
+)
+
+// animationFrame 解析出的原始帧，draw 将该帧绘制到画布上
+type animationFrame struct {
+	delay    time.Duration
+	rect     image.Rectangle // 帧在画布上的区域
+	disposal int
+	draw     func(canvas *image.RGBA) error
+}
+
+// ExtractFrames 按配置从 GIF/APNG/WebP 动画中抽帧
+// 不是动画（只有一帧）时返回 nil；mediaType 为空时按文件头识别
+func ExtractFrames(data []byte, mediaType string, options FrameOptions) (*Animation, error) {
+	if mediaType == "" {
+		mediaType = DetectMediaType(data)
+	}
+
+	var (
+		width, height int
+		frames        []animationFrame
+		err           error
+	)
+	switch mediaType {
+	case MediaTypeGIF:
+		width, height, frames, err = gifFrames(data)
+	case MediaTypePNG:
+		width, height, frames, err = apngFrames(data)
+	case MediaTypeWebP:
+		width, height, frames, err = webpFrames(data)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) <= 1 {
+		return nil, nil
+	}
+	if err := checkCanvas(width, height, len(frames)); err != nil {
+		return nil, err
+	}
+	// 帧在解码前检查是否位于画布内，避免按声明的尺寸分配过大的内存
+	bounds := image.Rect(0, 0, width, height)
+	for i, frame := range frames {
+		if frame.rect.Empty() || !frame.rect.In(bounds) {
+			return nil, fmt.Errorf("第 %d 帧区域 %v 超出画布 %dx%d", i+1, frame.rect, width, height)
+		}
+	}
+
+	sampled := make(map[int]bool)
+	for _, i := range SampleFrameIndices(len(frames), options) {
+		sampled[i] = true
+	}
+
+	animation := &Animation{TotalFrames: len(frames)}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	var previous *image.RGBA
+	for i, frame := range frames {
+		if frame.disposal == disposePrevious {
+			previous = copyRGBA(canvas)
+		}
+		if err := frame.draw(canvas); err != nil {
+			return nil, fmt.Errorf("解码第 %d 帧失败: %w", i+1, err)
+		}
+		if sampled[i] {
+			animation.Frames = append(animation.Frames, Frame{Image: copyRGBA(canvas), Index: i, Timestamp: animation.Duration})
+		}
+		animation.Duration += frame.delay
+		switch frame.disposal {
+		case disposeBackground:
+			draw.Draw(canvas, frame.rect, image.Transparent, image.Point{}, draw.Src)
+		case disposePrevious:
+			draw.Draw(canvas, frame.rect, previous, frame.rect.Min, draw.Src)
+		}
+	}
+	return animation, nil
+}
+
+// checkCanvas 检查动画的画布尺寸和帧数
+func checkCanvas(width, height, frames int) error {
+	if width <= 0 || height <= 0 || width*height > maxCanvasPixels {
+		return fmt.Errorf("动画尺寸 %dx%d 超出限制", width, height)
+	}
+	if frames > maxAnimationFrames {
+		return fmt.Errorf("动画帧数 %d 超出限制", frames)
+	}
+	return nil
+}
+
+// SampleFrameIndices 按配置计算要抽取的帧序号（升序、不重复）
+func SampleFrameIndices(total int, options FrameOptions) []int {
+	limit := options.MaxFrames
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+
+	var indices []int
+	add := func(i int) {
+		if len(indices) == 0 || indices[len(indices)-1] != i {
+			indices = append(indices, i)
+		}
+	}
+	switch {
+	case options.Sampling == SamplingInterval && options.Interval > 0:
+		for i := 0; i < total && len(indices) < limit; i += options.Interval {
+			add(i)
+		}
+	case options.Sampling == SamplingInterval:
+		if limit == 1 {
+			return []int{0}
+		}
+		for k := 0; k < limit; k++ {
+			add(k * (total - 1) / (limit - 1))
+		}
+	default:
+		for _, i := range []int{0, total / 2, total - 1} {
+			if len(indices) < limit {
+				add(i)
+			}
+		}
+	}
+	return indices
+}
+
+// copyRGBA 复制画布
+func copyRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// gifFrames 解析 GIF 动画的所有帧，静态 GIF 返回空列表
+// gif.DecodeAll 一次解码全部帧，解码前先检查画布尺寸、帧数和像素总数
+func gifFrames(data []byte) (int, int, []animationFrame, error) {
+	cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("读取 GIF 尺寸失败: %w", err)
+	}
+	count, pixels, err := scanGIF(data)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("解析 GIF 失败: %w", err)
+	}
+	if count <= 1 {
+		return cfg.Width, cfg.Height, nil, nil
+	}
+	if err := checkCanvas(cfg.Width, cfg.Height, count); err != nil {
+		return 0, 0, nil, err
+	}
+	if pixels > maxGIFPixels {
+		return 0, 0, nil, fmt.Errorf("GIF 动画共 %d 帧、%d 像素，超出限制", count, pixels)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("解码 GIF 失败: %w", err)
+	}
+
+	frames := make([]animationFrame, len(g.Image))
+	for i, paletted := range g.Image {
+		paletted := paletted
+		rect := paletted.Bounds()
+		frame := animationFrame{
+			delay: time.Duration(g.Delay[i]) * 10 * time.Millisecond,
+			rect:  rect,
+			draw: func(canvas *image.RGBA) error {
+				draw.Draw(canvas, rect, paletted, rect.Min, draw.Over)
+				return nil
+			},
+		}
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				frame.disposal = disposeBackground
+			case gif.DisposalPrevious:
+				frame.disposal = disposePrevious
+			}
+		}
+		frames[i] = frame
+	}
+	return g.Config.Width, g.Config.Height, frames, nil
+}
+
+// scanGIF 不解码像素，遍历 GIF 数据块统计帧数和所有帧的像素总数
+func scanGIF(data []byte) (frames int, pixels int, err error) {
+	if len(data) < 13 {
+		return 0, 0, errors.New("GIF 文件头不完整")
+	}
+	offset := 13
+	if data[10]&0x80 != 0 {
+		// 全局颜色表
+		offset += 3 << (data[10]&0x07 + 1)
+	}
+	skipSubBlocks := func() error {
+		for {
+			if offset >= len(data) {
+				return errors.New("GIF 数据不完整")
+			}
+			size := int(data[offset])
+			offset += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	for offset < len(data) {
+		switch data[offset] {
+		case 0x21: // 扩展块：标签之后是数据子块
+			offset += 2
+			if err := skipSubBlocks(); err != nil {
+				return frames, pixels, err
+			}
+		case 0x2C: // 图像描述符
+			if offset+10 > len(data) {
+				return frames, pixels, errors.New("GIF 图像描述符不完整")
+			}
+			width := int(binary.LittleEndian.Uint16(data[offset+5:]))
+			height := int(binary.LittleEndian.Uint16(data[offset+7:]))
+			packed := data[offset+9]
+			offset += 10
+			if packed&0x80 != 0 {
+				// 局部颜色表
+				offset += 3 << (packed&0x07 + 1)
+			}
+			// LZW 最小码长之后是图像数据子块
+			offset++
+			if err := skipSubBlocks(); err != nil {
+				return frames, pixels, err
+			}
+			frames++
+			pixels += width * height
+		case 0x3B: // 结束标记
+			return frames, pixels, nil
+		default:
+			return frames, pixels, fmt.Errorf("无效的 GIF 数据块 0x%02x", data[offset])
+		}
+	}
+	return frames, pixels, nil
+}
+
+// pngChunk PNG 数据块
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// apngFrames 解析 APNG 动画的所有帧，普通 PNG 返回空列表
+// 每一帧重新组装为独立的 PNG（替换 IHDR 中的尺寸，fdAT 转换为 IDAT）后解码
+func apngFrames(data []byte) (int, int, []animationFrame, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return 0, 0, nil, errors.New("不是 PNG 文件")
+	}
+
+	var (
+		ihdr      []byte
+		shared    []pngChunk // 解码每一帧都需要的 PLTE、tRNS 等数据块
+		animated  bool
+		seenIDAT  bool
+		control   []byte   // 当前帧的 fcTL
+		frameData [][]byte // 当前帧的图像数据
+		frames    []animationFrame
+		width     int
+		height    int
+	)
+
+	flush := func() {
+		if control != nil {
+			frames = append(frames, apngFrame(ihdr, shared, control, frameData, len(frames) == 0))
+			control, frameData = nil, nil
+		}
+	}
+
+	for offset := len(signature); offset+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		typ := string(data[offset+4 : offset+8])
+		start := offset + 8
+		if length < 0 || start+length+4 > len(data) {
+			return 0, 0, nil, errors.New("PNG 数据块长度无效")
+		}
+		chunk := data[start : start+length]
+		offset = start + length + 4
+
+		switch typ {
+		case "IHDR":
+			if len(chunk) < 13 {
+				return 0, 0, nil, errors.New("IHDR 数据块无效")
+			}
+			ihdr = chunk
+			width = int(binary.BigEndian.Uint32(chunk[0:4]))
+			height = int(binary.BigEndian.Uint32(chunk[4:8]))
+		case "acTL":
+			animated = true
+		case "fcTL":
+			flush()
+			if len(chunk) < 26 {
+				return 0, 0, nil, errors.New("fcTL 数据块无效")
+			}
+			control = chunk
+		case "IDAT":
+			seenIDAT = true
+			// 默认图片之前没有 fcTL 时，默认图片不属于动画
+			if control != nil {
+				frameData = append(frameData, chunk)
+			}
+		case "fdAT":
+			if control != nil && len(chunk) >= 4 {
+				frameData = append(frameData, chunk[4:])
+			}
+		case "IEND":
+			offset = len(data)
+		default:
+			if !seenIDAT && control == nil {
+				shared = append(shared, pngChunk{typ: typ, data: chunk})
+			}
+		}
+	}
+	if !animated {
+		return width, height, nil, nil
+	}
+	flush()
+	return width, height, frames, nil
+}
+
+// apngFrame 根据 fcTL 和图像数据生成一帧
+func apngFrame(ihdr []byte, shared []pngChunk, control []byte, frameData [][]byte, first bool) animationFrame {
+	width := binary.BigEndian.Uint32(control[4:8])
+	height := binary.BigEndian.Uint32(control[8:12])
+	x := int(binary.BigEndian.Uint32(control[12:16]))
+	y := int(binary.BigEndian.Uint32(control[16:20]))
+	delayNum := binary.BigEndian.Uint16(control[20:22])
+	delayDen := binary.BigEndian.Uint16(control[22:24])
+	disposeOp := control[24]
+	blendOp := control[25]
+
+	if delayDen == 0 {
+		delayDen = 100
+	}
+	rect := image.Rect(x, y, x+int(width), y+int(height))
+
+	// 组装为独立的 PNG
+	header := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(header[0:4], width)
+	binary.BigEndian.PutUint32(header[4:8], height)
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	writePNGChunk(&buf, "IHDR", header)
+	for _, chunk := range shared {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+	}
+	writePNGChunk(&buf, "IDAT", bytes.Join(frameData, nil))
+	writePNGChunk(&buf, "IEND", nil)
+	encoded := buf.Bytes()
+
+	frame := animationFrame{
+		delay: time.Duration(delayNum) * time.Second / time.Duration(delayDen),
+		rect:  rect,
+		draw: func(canvas *image.RGBA) error {
+			img, err := png.Decode(bytes.NewReader(encoded))
+			if err != nil {
+				return err
+			}
+			op := draw.Over
+			if blendOp == 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, rect, img, image.Point{}, op)
+			return nil
+		},
+	}
+	// 第一帧的 APNG_DISPOSE_OP_PREVIOUS 按 APNG_DISPOSE_OP_BACKGROUND 处理
+	switch {
+	case disposeOp == 1 || (disposeOp == 2 && first):
+		frame.disposal = disposeBackground
+	case disposeOp == 2:
+		frame.disposal = disposePrevious
+	}
+	return frame
+}
+
+// writePNGChunk 写入一个带 CRC 的 PNG 数据块
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], typ)
+	buf.Write(header[:])
+	buf.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// webpFrames 解析 WebP 动画的所有帧，静态 WebP 返回空列表
+// 每一帧（ALPH + VP8/VP8L）重新组装为独立的 WebP 后解码
+func webpFrames(data []byte) (int, int, []animationFrame, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, nil, errors.New("不是 WebP 文件")
+	}
+
+	var (
+		width, height int
+		frames        []animationFrame
+	)
+	for _, chunk := range riffChunks(data[12:]) {
+		switch chunk.typ {
+		case "VP8X":
+			if len(chunk.data) < 10 {
+				return 0, 0, nil, errors.New("VP8X 数据块无效")
+			}
+			if chunk.data[0]&0x02 == 0 {
+				// 没有动画标志
+				return 0, 0, nil, nil
+			}
+			width = int(uint24(chunk.data[4:7])) + 1
+			height = int(uint24(chunk.data[7:10])) + 1
+		case "ANMF":
+			if len(chunk.data) < 16 {
+				return 0, 0, nil, errors.New("ANMF 数据块无效")
+			}
+			frames = append(frames, webpFrame(chunk.data))
+		}
+	}
+	return width, height, frames, nil
+}
+
+// webpFrame 根据 ANMF 数据块生成一帧
+func webpFrame(anmf []byte) animationFrame {
+	x := int(uint24(anmf[0:3])) * 2
+	y := int(uint24(anmf[3:6])) * 2
+	width := int(uint24(anmf[6:9])) + 1
+	height := int(uint24(anmf[9:12])) + 1
+	duration := time.Duration(uint24(anmf[12:15])) * time.Millisecond
+	flags := anmf[15]
+	rect := image.Rect(x, y, x+width, y+height)
+
+	frame := animationFrame{
+		delay: duration,
+		rect:  rect,
+		draw: func(canvas *image.RGBA) error {
+			img, err := webp.Decode(bytes.NewReader(standaloneWebP(anmf[16:], width, height)))
+			if err != nil {
+				return err
+			}
+			op := draw.Over
+			if flags&0x02 != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, rect, img, image.Point{}, op)
+			return nil
+		},
+	}
+	if flags&0x01 != 0 {
+		frame.disposal = disposeBackground
+	}
+	return frame
+}
+
+// standaloneWebP 将帧数据组装为独立的 WebP 文件，带透明通道时使用 VP8X 扩展格式
+func standaloneWebP(frameData []byte, width, height int) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	hasAlpha := false
+	for _, chunk := range riffChunks(frameData) {
+		if chunk.typ == "ALPH" {
+			hasAlpha = true
+		}
+	}
+	if hasAlpha {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		putUint24(vp8x[4:7], uint32(width-1))
+		putUint24(vp8x[7:10], uint32(height-1))
+		writeRIFFChunk(&body, "VP8X", vp8x)
+	}
+	body.Write(frameData)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// riffChunk RIFF 数据块
+type riffChunk struct {
+	typ  string
+	data []byte
+}
+
+// riffChunks 拆分 RIFF 数据块（数据长度为奇数时有一个字节的填充）
+func riffChunks(data []byte) []riffChunk {
+	var chunks []riffChunk
+	for offset := 0; offset+8 <= len(data); {
+		typ := string(data[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		start := offset + 8
+		if length < 0 || start+length > len(data) {
+			break
+		}
+		chunks = append(chunks, riffChunk{typ: typ, data: data[start : start+length]})
+		offset = start + length + length%2
+	}
+	return chunks
+}
+
+// writeRIFFChunk 写入一个 RIFF 数据块
+func writeRIFFChunk(buf *bytes.Buffer, typ string, data []byte) {
+	buf.WriteString(typ)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// uint24 读取 3 字节小端整数
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// putUint24 写入 3 字节小端整数
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// EncodeFrame 将抽取的帧编码为 PNG
+func EncodeFrame(frame Frame) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frame.Image); err != nil {
+		return nil, fmt.Errorf("编码 PNG 失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
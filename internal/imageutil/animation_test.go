@@ -0,0 +1,203 @@
+package imageutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleFrameIndices(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		options FrameOptions
+		want    []int
+	}{
+		{name: "关键帧", total: 10, options: FrameOptions{Sampling: SamplingKeyframes, MaxFrames: 6}, want: []int{0, 5, 9}},
+		{name: "关键帧受帧数限制", total: 10, options: FrameOptions{Sampling: SamplingKeyframes, MaxFrames: 2}, want: []int{0, 5}},
+		{name: "两帧动画的关键帧去重", total: 2, options: FrameOptions{Sampling: SamplingKeyframes, MaxFrames: 6}, want: []int{0, 1}},
+		{name: "固定间隔", total: 10, options: FrameOptions{Sampling: SamplingInterval, Interval: 3, MaxFrames: 6}, want: []int{0, 3, 6, 9}},
+		{name: "固定间隔受帧数限制", total: 10, options: FrameOptions{Sampling: SamplingInterval, Interval: 2, MaxFrames: 3}, want: []int{0, 2, 4}},
+		{name: "均匀抽取", total: 10, options: FrameOptions{Sampling: SamplingInterval, MaxFrames: 4}, want: []int{0, 3, 6, 9}},
+		{name: "均匀抽取一帧", total: 10, options: FrameOptions{Sampling: SamplingInterval, MaxFrames: 1}, want: []int{0}},
+		{name: "帧数不足", total: 3, options: FrameOptions{Sampling: SamplingInterval, MaxFrames: 6}, want: []int{0, 1, 2}},
+		{name: "不限制帧数", total: 4, options: FrameOptions{Sampling: SamplingInterval}, want: []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SampleFrameIndices(tt.total, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SampleFrameIndices(%d, %+v) = %v，期望 %v", tt.total, tt.options, got, tt.want)
+			}
+		})
+	}
+}
+
+// encodeGIF 生成每帧纯色的 GIF 动画，delay 单位为 10ms
+func encodeGIF(t *testing.T, colors []color.Color, delay int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White, color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}}
+	g := &gif.GIF{}
+	for _, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+		index := uint8(palette.Index(c))
+		for i := range frame.Pix {
+			frame.Pix[i] = index
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, delay)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractFramesGIF(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	data := encodeGIF(t, []color.Color{red, color.White, green}, 50)
+
+	animation, err := ExtractFrames(data, "", FrameOptions{Sampling: SamplingKeyframes, MaxFrames: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if animation.TotalFrames != 3 || len(animation.Frames) != 3 || animation.Duration != 1500*time.Millisecond {
+		t.Fatalf("帧数 %d，抽取 %d 帧，时长 %v", animation.TotalFrames, len(animation.Frames), animation.Duration)
+	}
+	wantColors := []color.RGBA{red, {R: 255, G: 255, B: 255, A: 255}, green}
+	for i, frame := range animation.Frames {
+		if got := color.RGBAModel.Convert(frame.Image.At(1, 1)); got != wantColors[i] {
+			t.Errorf("第 %d 帧颜色 = %v，期望 %v", i, got, wantColors[i])
+		}
+		if frame.Timestamp != time.Duration(i)*500*time.Millisecond {
+			t.Errorf("第 %d 帧时间 = %v", i, frame.Timestamp)
+		}
+	}
+
+	static := encodeGIF(t, []color.Color{red}, 0)
+	if animation, err := ExtractFrames(static, MediaTypeGIF, FrameOptions{MaxFrames: 6}); animation != nil || err != nil {
+		t.Errorf("静态 GIF 返回 %v, %v，期望 nil", animation, err)
+	}
+}
+
+// gifBomb 生成只包含文件头和图像描述符的 GIF：每帧都声明为整个画布大小，像素数据为空
+func gifBomb(width, height uint16, frames int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	binary.Write(&buf, binary.LittleEndian, width)
+	binary.Write(&buf, binary.LittleEndian, height)
+	buf.Write([]byte{0x80, 0, 0})             // 两种颜色的全局颜色表
+	buf.Write([]byte{0, 0, 0, 255, 255, 255}) // 颜色表
+	for i := 0; i < frames; i++ {
+		buf.WriteByte(0x2C)
+		binary.Write(&buf, binary.LittleEndian, [4]uint16{0, 0, width, height})
+		buf.WriteByte(0)
+		buf.Write([]byte{2, 2, 0x4C, 0x01, 0}) // LZW 最小码长 2：清除码 + 结束码
+	}
+	buf.WriteByte(0x3B)
+	return buf.Bytes()
+}
+
+func TestExtractFramesRejectsOversizedGIF(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "画布过大", data: gifBomb(60000, 60000, 2), want: "尺寸"},
+		{name: "帧数过多", data: gifBomb(2, 2, maxAnimationFrames+1), want: "帧数"},
+		{name: "像素总数过多", data: gifBomb(4000, 4000, 5), want: "像素"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractFrames(tt.data, MediaTypeGIF, FrameOptions{MaxFrames: 6})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// rgbaIDAT 生成纯色 8 位 RGBA 图像的 IDAT 数据（每行一个无过滤标记）
+func rgbaIDAT(t *testing.T, width, height int, c color.NRGBA) []byte {
+	t.Helper()
+	var raw bytes.Buffer
+	for y := 0; y < height; y++ {
+		raw.WriteByte(0)
+		for x := 0; x < width; x++ {
+			raw.Write([]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(raw.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// fcTL 生成 APNG 帧控制数据块
+func fcTL(sequence, width, height, x, y uint32) []byte {
+	chunk := make([]byte, 26)
+	binary.BigEndian.PutUint32(chunk[0:], sequence)
+	binary.BigEndian.PutUint32(chunk[4:], width)
+	binary.BigEndian.PutUint32(chunk[8:], height)
+	binary.BigEndian.PutUint32(chunk[12:], x)
+	binary.BigEndian.PutUint32(chunk[16:], y)
+	binary.BigEndian.PutUint16(chunk[20:], 1)  // delay_num
+	binary.BigEndian.PutUint16(chunk[22:], 10) // delay_den
+	return chunk
+}
+
+// encodeAPNG 生成两帧的 APNG，第二帧使用指定的尺寸和位置
+func encodeAPNG(t *testing.T, width, height, x, y uint32) []byte {
+	t.Helper()
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 2)
+	binary.BigEndian.PutUint32(ihdr[4:], 2)
+	ihdr[8], ihdr[9] = 8, 6 // 8 位 RGBA
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], 2)
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	writePNGChunk(&buf, "IHDR", ihdr)
+	writePNGChunk(&buf, "acTL", actl)
+	writePNGChunk(&buf, "fcTL", fcTL(0, 2, 2, 0, 0))
+	writePNGChunk(&buf, "IDAT", rgbaIDAT(t, 2, 2, color.NRGBA{R: 255, G: 255, B: 255, A: 255}))
+	writePNGChunk(&buf, "fcTL", fcTL(1, width, height, x, y))
+	writePNGChunk(&buf, "fdAT", append([]byte{0, 0, 0, 2}, rgbaIDAT(t, int(width), int(height), color.NRGBA{A: 255})...))
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func TestExtractFramesAPNG(t *testing.T) {
+	animation, err := ExtractFrames(encodeAPNG(t, 1, 1, 1, 1), "", FrameOptions{Sampling: SamplingInterval, MaxFrames: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if animation.TotalFrames != 2 || animation.Duration != 200*time.Millisecond {
+		t.Fatalf("帧数 %d，时长 %v", animation.TotalFrames, animation.Duration)
+	}
+	last := animation.Frames[1].Image
+	if got := color.RGBAModel.Convert(last.At(1, 1)); got != (color.RGBA{A: 255}) {
+		t.Errorf("第二帧区域颜色 = %v，期望黑色", got)
+	}
+	if got := color.RGBAModel.Convert(last.At(0, 0)); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("第二帧区域外颜色 = %v，期望保留第一帧的白色", got)
+	}
+
+	// fcTL 声明的区域超出画布时不解码
+	if _, err := ExtractFrames(encodeAPNG(t, 3, 3, 0, 0), MediaTypePNG, FrameOptions{MaxFrames: 6}); err == nil || !strings.Contains(err.Error(), "超出画布") {
+		t.Errorf("err = %v，期望拒绝超出画布的帧", err)
+	}
+}
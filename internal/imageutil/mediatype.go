@@ -0,0 +1,96 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+)
+
+// 支持识别的图片类型
+const (
+	MediaTypePNG  = "image/png"
+	MediaTypeJPEG = "image/jpeg"
+	MediaTypeGIF  = "image/gif"
+	MediaTypeWebP = "image/webp"
+	MediaTypeBMP  = "image/bmp"
+)
+
+// sniffLen 检测文件头所需的最大字节数
+const sniffLen = 12
+
+// DetectMediaType 根据文件头（magic bytes）识别图片类型，无法识别时返回空字符串
+func DetectMediaType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return MediaTypePNG
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return MediaTypeJPEG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return MediaTypeGIF
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return MediaTypeWebP
+	case bytes.HasPrefix(data, []byte("BM")):
+		return MediaTypeBMP
+	}
+	return ""
+}
+
+// DetectBase64MediaType 只解码 base64 的开头部分识别图片类型
+func DetectBase64MediaType(b64 string) string {
+	// 每 4 个 base64 字符对应 3 个字节
+	n := (sniffLen + 2) / 3 * 4
+	if len(b64) < n {
+		n = len(b64) / 4 * 4
+	}
+	head, err := base64.StdEncoding.DecodeString(b64[:n])
+	if err != nil {
+		return ""
+	}
+	return DetectMediaType(head)
+}
+
+// DetectDataMediaType 识别 data URI 或 base64 图片的类型
+// 以文件头为准，无法识别时使用 data URI 中声明的类型
+func DetectDataMediaType(s string) string {
+	declared, b64 := SplitDataURI(s)
+	if mediaType := DetectBase64MediaType(b64); mediaType != "" {
+		return mediaType
+	}
+	if strings.HasPrefix(declared, "image/") {
+		return declared
+	}
+	return ""
+}
+
+// DecodeBase64 解码 base64 数据，兼容换行和缺少填充的情况
+func DecodeBase64(b64 string) ([]byte, error) {
+	b64 = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, b64)
+	if data, err := base64.StdEncoding.DecodeString(b64); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(b64, "="))
+}
+
+// SplitDataURI 拆分 data URI，返回声明的媒体类型和 base64 数据
+// 不是 data URI 时原样返回数据，媒体类型为空
+func SplitDataURI(s string) (mediaType string, data string) {
+	if !strings.HasPrefix(s, "data:") {
+		return "", s
+	}
+	meta, payload, found := strings.Cut(s[len("data:"):], ",")
+	if !found {
+		return "", s
+	}
+	mediaType, _, _ = strings.Cut(meta, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType)), payload
+}
+
+// DataURI 生成 base64 编码的 data URI
+func DataURI(mediaType string, b64 string) string {
+	return "data:" + mediaType + ";base64," + b64
+}
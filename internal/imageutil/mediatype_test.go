@@ -0,0 +1,99 @@
+package imageutil
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+// 各格式的文件头
+var (
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	jpegHeader = []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01}
+	gifHeader  = []byte("GIF89a\x01\x00\x01\x00\x80\x00")
+	webpHeader = []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+	bmpHeader  = []byte("BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00")
+)
+
+func TestDetectMediaType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "PNG", data: pngHeader, want: MediaTypePNG},
+		{name: "JPEG", data: jpegHeader, want: MediaTypeJPEG},
+		{name: "GIF89a", data: gifHeader, want: MediaTypeGIF},
+		{name: "GIF87a", data: []byte("GIF87a\x01\x00"), want: MediaTypeGIF},
+		{name: "WebP", data: webpHeader, want: MediaTypeWebP},
+		{name: "BMP", data: bmpHeader, want: MediaTypeBMP},
+		{name: "未知格式", data: []byte("%PDF-1.7\n%\xe2\xe3"), want: ""},
+		{name: "空数据", data: nil, want: ""},
+		{name: "截断的 PNG", data: pngHeader[:4], want: ""},
+		{name: "截断的 JPEG", data: jpegHeader[:2], want: ""},
+		{name: "截断的 GIF", data: gifHeader[:4], want: ""},
+		{name: "截断的 WebP", data: webpHeader[:11], want: ""},
+		{name: "RIFF 但不是 WebP", data: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMediaType(tt.data); got != tt.want {
+				t.Errorf("DetectMediaType = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectBase64MediaType(t *testing.T) {
+	encode := func(data []byte) string {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	tests := []struct {
+		name string
+		b64  string
+		want string
+	}{
+		{name: "PNG", b64: encode(append(pngHeader, make([]byte, 100)...)), want: MediaTypePNG},
+		{name: "JPEG", b64: encode(jpegHeader), want: MediaTypeJPEG},
+		{name: "GIF", b64: encode(gifHeader), want: MediaTypeGIF},
+		{name: "WebP", b64: encode(webpHeader), want: MediaTypeWebP},
+		{name: "BMP", b64: encode(bmpHeader), want: MediaTypeBMP},
+		{name: "未知格式", b64: encode([]byte("hello, world!")), want: ""},
+		{name: "空字符串", b64: "", want: ""},
+		{name: "不足一组", b64: "iVB", want: ""},
+		{name: "截断后仍有完整文件头", b64: encode(pngHeader)[:12], want: MediaTypePNG},
+		{name: "截断到文件头之内", b64: encode(pngHeader)[:8], want: ""},
+		{name: "无效的 base64", b64: "!!!!!!!!!!!!!!!!", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectBase64MediaType(tt.b64); got != tt.want {
+				t.Errorf("DetectBase64MediaType(%.20q) = %q，期望 %q", tt.b64, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDataMediaType(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(pngHeader)
+	unknown := base64.StdEncoding.EncodeToString([]byte("\x00\x00\x00\x18ftypheic"))
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{name: "声明与内容一致", s: DataURI(MediaTypePNG, png), want: MediaTypePNG},
+		{name: "声明与内容不一致时以文件头为准", s: DataURI(MediaTypeJPEG, png), want: MediaTypePNG},
+		{name: "无法识别时使用声明的图片类型", s: DataURI("image/heic", unknown), want: "image/heic"},
+		{name: "声明的类型不是图片", s: DataURI("application/pdf", unknown), want: ""},
+		{name: "声明的类型大小写", s: "data:IMAGE/HEIC;base64," + unknown, want: "image/heic"},
+		{name: "裸 base64", s: png, want: MediaTypePNG},
+		{name: "缺少逗号", s: "data:image/png;base64" + png, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectDataMediaType(tt.s); got != tt.want {
+				t.Errorf("DetectDataMediaType = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// 预处理输出格式
+const (
+	FormatAuto = ""     // JPEG 保持 JPEG，其他格式输出 PNG
+	FormatJPEG = "jpeg" // 统一转换为 JPEG
+)
+
+// maxDecodePixels 解码图片的像素上限，防止文件很小但声明了超大尺寸的图片（解压炸弹）耗尽内存
+const maxDecodePixels = 40 * 1000 * 1000
+
+// Options 发送给视觉模型前的图片预处理配置
+type Options struct {
+	MaxDimension  int    // 最长边上限（像素），0 表示不限制
+	MaxPixels     int    // 总像素上限，0 表示不限制
+	Format        string // 输出格式：FormatAuto 或 FormatJPEG
+	JPEGQuality   int    // JPEG 编码质量（1-100）
+	StripMetadata bool   // 是否重新编码以去除 EXIF 等元数据
+}
+
+// IsVisionSupported 视觉模型可以直接处理的图片类型
+func IsVisionSupported(mediaType string) bool {
+	return mediaType == MediaTypePNG || mediaType == MediaTypeJPEG
+}
+
+// decode 按已识别的类型解码图片（GIF 只取第一帧）
+func decode(data []byte, mediaType string) (image.Image, error) {
+	reader := bytes.NewReader(data)
+	switch mediaType {
+	case MediaTypePNG:
+		return png.Decode(reader)
+	case MediaTypeJPEG:
+		return jpeg.Decode(reader)
+	case MediaTypeGIF:
+		return gif.Decode(reader)
+	case MediaTypeWebP:
+		return webp.Decode(reader)
+	case MediaTypeBMP:
+		return bmp.Decode(reader)
+	}
+	img, _, err := image.Decode(reader)
+	return img, err
+}
+
+// decodeConfig 只读取图片尺寸，不解码像素
+func decodeConfig(data []byte, mediaType string) (image.Config, error) {
+	reader := bytes.NewReader(data)
+	switch mediaType {
+	case MediaTypePNG:
+		return png.DecodeConfig(reader)
+	case MediaTypeJPEG:
+		return jpeg.DecodeConfig(reader)
+	case MediaTypeGIF:
+		return gif.DecodeConfig(reader)
+	case MediaTypeWebP:
+		return webp.DecodeConfig(reader)
+	case MediaTypeBMP:
+		return bmp.DecodeConfig(reader)
+	}
+	cfg, _, err := image.DecodeConfig(reader)
+	return cfg, err
+}
+
+// targetSize 按最长边和总像素上限计算缩放后的尺寸，不需要缩放时返回原尺寸
+func targetSize(width, height int, options Options) (int, int) {
+	scale := 1.0
+	if options.MaxDimension > 0 {
+		if longest := max(width, height); longest > options.MaxDimension {
+			scale = float64(options.MaxDimension) / float64(longest)
+		}
+	}
+	if options.MaxPixels > 0 && width*height > options.MaxPixels {
+		scale = min(scale, math.Sqrt(float64(options.MaxPixels)/float64(width*height)))
+	}
+	if scale >= 1 {
+		return width, height
+	}
+	return max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+}
+
+// Preprocess 按配置缩放、转换格式并去除元数据，返回处理后的图片数据和类型
+// 视觉模型支持的格式在无需任何处理时原样返回
+func Preprocess(data []byte, mediaType string, options Options) ([]byte, string, error) {
+	cfg, err := decodeConfig(data, mediaType)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取 %s 图片尺寸失败: %w", mediaType, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxDecodePixels {
+		return nil, "", fmt.Errorf("%s 图片尺寸 %dx%d 超出限制", mediaType, cfg.Width, cfg.Height)
+	}
+	width, height := targetSize(cfg.Width, cfg.Height, options)
+	resize := width != cfg.Width || height != cfg.Height
+
+	outputType := MediaTypePNG
+	if options.Format == FormatJPEG || (options.Format == FormatAuto && mediaType == MediaTypeJPEG) {
+		outputType = MediaTypeJPEG
+	}
+
+	if IsVisionSupported(mediaType) && !resize && !options.StripMetadata && outputType == mediaType {
+		return data, mediaType, nil
+	}
+
+	img, err := decode(data, mediaType)
+	if err != nil {
+		return nil, "", fmt.Errorf("解码 %s 图片失败: %w", mediaType, err)
+	}
+
+	if resize {
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	if outputType == MediaTypeJPEG {
+		// JPEG 不支持透明通道，铺白色背景
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		quality := options.JPEGQuality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("编码 JPEG 失败: %w", err)
+		}
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("编码 PNG 失败: %w", err)
+		}
+	}
+	return buf.Bytes(), outputType, nil
+}
+
+// PrepareForVision 将 base64 图片整理为视觉模型可以处理的 data URI
+// 按文件头识别类型：不支持的格式转换为 PNG，并按 options 缩放和重新编码；
+// 无法识别的类型不做处理，按请求中声明的类型 mediaType 原样转发（未声明时返回原始 base64 数据）
+func PrepareForVision(b64 string, mediaType string, options Options) (string, error) {
+	data, err := DecodeBase64(b64)
+	if err != nil {
+		return "", fmt.Errorf("解码 base64 图片失败: %w", err)
+	}
+
+	detected := DetectMediaType(data)
+	if detected == "" {
+		if mediaType == "" {
+			return b64, nil
+		}
+		return DataURI(mediaType, b64), nil
+	}
+
+	out, outputType, err := Preprocess(data, detected, options)
+	if err != nil {
+		return "", err
+	}
+	return DataURI(outputType, base64.StdEncoding.EncodeToString(out)), nil
+}
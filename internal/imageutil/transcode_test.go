@@ -0,0 +1,167 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// encodePNG 生成指定尺寸的纯色 PNG
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngBomb 生成只有 IHDR 的 PNG：文件很小，但声明了超大尺寸
+func pngBomb(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8] = 8 // 位深
+	ihdr[9] = 2 // RGB
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(ihdr)))
+	buf.Write(length[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte("IHDR"))
+	crc.Write(ihdr)
+	buf.WriteString("IHDR")
+	buf.Write(ihdr)
+	binary.Write(&buf, binary.BigEndian, crc.Sum32())
+	return buf.Bytes()
+}
+
+// bmpBomb 生成只有文件头的 24 位 BMP，声明了超大尺寸
+func bmpBomb(width, height int32) []byte {
+	header := make([]byte, 54)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[2:], 54)
+	binary.LittleEndian.PutUint32(header[10:], 54)
+	binary.LittleEndian.PutUint32(header[14:], 40)
+	binary.LittleEndian.PutUint32(header[18:], uint32(width))
+	binary.LittleEndian.PutUint32(header[22:], uint32(height))
+	binary.LittleEndian.PutUint16(header[26:], 1)
+	binary.LittleEndian.PutUint16(header[28:], 24)
+	return header
+}
+
+func TestPreprocessRejectsDecompressionBombs(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		mediaType string
+	}{
+		{name: "PNG", data: pngBomb(60000, 60000), mediaType: MediaTypePNG},
+		{name: "BMP", data: bmpBomb(60000, 60000), mediaType: MediaTypeBMP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Preprocess(tt.data, tt.mediaType, Options{MaxDimension: 1024})
+			if err == nil || !strings.Contains(err.Error(), "超出限制") {
+				t.Errorf("err = %v，期望拒绝超大尺寸的图片", err)
+			}
+		})
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	small := encodePNG(t, 40, 20)
+	tests := []struct {
+		name       string
+		options    Options
+		wantType   string
+		wantWidth  int
+		wantHeight int
+		passthru   bool
+	}{
+		{name: "无需处理时原样返回", options: Options{}, wantType: MediaTypePNG, wantWidth: 40, wantHeight: 20, passthru: true},
+		{name: "按最长边缩放", options: Options{MaxDimension: 20}, wantType: MediaTypePNG, wantWidth: 20, wantHeight: 10},
+		{name: "按总像素缩放", options: Options{MaxPixels: 200}, wantType: MediaTypePNG, wantWidth: 20, wantHeight: 10},
+		{name: "转换为 JPEG", options: Options{Format: FormatJPEG, JPEGQuality: 80}, wantType: MediaTypeJPEG, wantWidth: 40, wantHeight: 20},
+		{name: "去除元数据", options: Options{StripMetadata: true}, wantType: MediaTypePNG, wantWidth: 40, wantHeight: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, outputType, err := Preprocess(small, MediaTypePNG, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outputType != tt.wantType || DetectMediaType(out) != tt.wantType {
+				t.Errorf("输出类型 = %s（文件头 %s），期望 %s", outputType, DetectMediaType(out), tt.wantType)
+			}
+			if tt.passthru && !bytes.Equal(out, small) {
+				t.Error("期望原样返回")
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+				t.Errorf("尺寸 = %dx%d，期望 %dx%d", cfg.Width, cfg.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestTargetSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		options       Options
+		wantW, wantH  int
+	}{
+		{4000, 3000, Options{}, 4000, 3000},
+		{4000, 3000, Options{MaxDimension: 2000}, 2000, 1500},
+		{3000, 4000, Options{MaxDimension: 2000}, 1500, 2000},
+		{4000, 3000, Options{MaxPixels: 3000000}, 2000, 1500},
+		{4000, 3000, Options{MaxDimension: 1000, MaxPixels: 3000000}, 1000, 750},
+		{10000, 1, Options{MaxDimension: 100}, 100, 1},
+	}
+	for _, tt := range tests {
+		w, h := targetSize(tt.width, tt.height, tt.options)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("targetSize(%dx%d, %+v) = %dx%d，期望 %dx%d", tt.width, tt.height, tt.options, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestPrepareForVision(t *testing.T) {
+	small := base64.StdEncoding.EncodeToString(encodePNG(t, 40, 20))
+	unknown := base64.StdEncoding.EncodeToString([]byte("\x00\x00\x00\x18ftypheic"))
+	tests := []struct {
+		name      string
+		b64       string
+		mediaType string
+		want      string
+	}{
+		{name: "支持的类型原样转发", b64: small, want: DataURI(MediaTypePNG, small)},
+		{name: "以文件头为准", b64: small, mediaType: MediaTypeJPEG, want: DataURI(MediaTypePNG, small)},
+		{name: "无法识别时使用声明的类型", b64: unknown, mediaType: "image/heic", want: DataURI("image/heic", unknown)},
+		{name: "无法识别且未声明", b64: unknown, want: unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PrepareForVision(tt.b64, tt.mediaType, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("PrepareForVision = %.60q，期望 %.60q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MediaType PDF 文档的媒体类型
+const MediaType = "application/pdf"
+
+// maxImagePixels 解码嵌入图片的像素上限，防止超大图片占用过多内存
+const maxImagePixels = 40 * 1000 * 1000
+
+// Options PDF 解析配置
+type Options struct {
+	MaxPages         int // 最多处理的页数，0 表示不限制
+	MinTextChars     int // 文字少于该字符数（不含空白）的页面视为扫描页，改为提取页面中的图片
+	MaxImagesPerPage int // 扫描页最多提取的图片数量，0 表示不限制
+}
+
+// Image 页面中提取的图片
+type Image struct {
+	Data      []byte
+	MediaType string // image/jpeg 或 image/png
+}
+
+// Page 单页解析结果
+type Page struct {
+	Number int     // 页码（从 1 开始）
+	Text   string  // 文字层内容
+	Images []Image // 文字不足时提取的图片
+}
+
+// Document PDF 解析结果
+type Document struct {
+	NumPages int    // 文档总页数
+	Pages    []Page // 已处理的页面（受 MaxPages 限制）
+}
+
+// IsPDF 根据文件头判断数据是否为 PDF
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// Parse 解析 PDF：提取每页的文字层，文字不足的页面提取嵌入图片（JPEG 原样返回，其他格式转换为 PNG）
+// 不支持页面渲染；既没有文字也没有可提取图片的页面（包括解析失败的页面）返回空内容
+func Parse(data []byte, options Options) (doc *Document, err error) {
+	// 第三方库遇到不支持的格式时会 panic；单个页面的 panic 在 parsePage 中处理，不影响其他页面
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("解析 PDF 失败: %v", r)
+		}
+	}()
+
+	if !IsPDF(data) {
+		return nil, errors.New("不是 PDF 文件")
+	}
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("解析 PDF 失败: %w", err)
+	}
+	p := &parser{
+		data:      data,
+		encrypted: !reader.Trailer().Key("Encrypt").IsNull(),
+		options:   options,
+	}
+
+	doc = &Document{NumPages: reader.NumPage()}
+	numPages := doc.NumPages
+	if options.MaxPages > 0 && numPages > options.MaxPages {
+		numPages = options.MaxPages
+	}
+
+	for i := 1; i <= numPages; i++ {
+		if page, ok := p.parsePage(reader, i); ok {
+			doc.Pages = append(doc.Pages, page)
+		}
+	}
+	return doc, nil
+}
+
+// parser 单个文档的解析状态
+type parser struct {
+	data      []byte
+	encrypted bool
+	options   Options
+
+	// 文件中所有以 JPEG 文件头开始的流的起始位置，第一次需要时扫描一次
+	jpegStarts []int
+	indexed    bool
+}
+
+// parsePage 解析第 i 页，页面不存在时返回 false
+// 第三方库在该页 panic 时返回空页面，其余页面照常解析
+func (p *parser) parsePage(reader *pdf.Reader, i int) (result Page, ok bool) {
+	result = Page{Number: i}
+	defer func() {
+		if r := recover(); r != nil {
+			result, ok = Page{Number: i}, true
+		}
+	}()
+
+	page := reader.Page(i)
+	if page.V.IsNull() {
+		return result, false
+	}
+	if text, err := page.GetPlainText(nil); err == nil {
+		result.Text = strings.TrimSpace(text)
+	}
+	if countChars(result.Text) < p.options.MinTextChars {
+		result.Images = p.pageImages(page)
+	}
+	return result, true
+}
+
+// countChars 统计非空白字符数
+func countChars(s string) int {
+	n := 0
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// pageImages 提取页面资源中的图片（按资源名顺序）
+func (p *parser) pageImages(page pdf.Page) []Image {
+	limit := p.options.MaxImagesPerPage
+	xobjects := page.Resources().Key("XObject")
+	var images []Image
+	for _, name := range xobjects.Keys() {
+		if limit > 0 && len(images) >= limit {
+			break
+		}
+		xobject := xobjects.Key(name)
+		if xobject.Key("Subtype").Name() != "Image" {
+			continue
+		}
+		if img, ok := p.extractImage(xobject); ok {
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
+// extractImage 提取单个图片对象，不支持的编码返回 false
+func (p *parser) extractImage(xobject pdf.Value) (img Image, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+
+	width := int(xobject.Key("Width").Int64())
+	height := int(xobject.Key("Height").Int64())
+	if width <= 0 || height <= 0 || width*height > maxImagePixels {
+		return Image{}, false
+	}
+
+	switch filter := xobject.Key("Filter"); filter.Kind() {
+	case pdf.Name:
+		switch filter.Name() {
+		case "DCTDecode":
+			// JPEG 数据无需解码，直接从文件中定位原始流
+			if p.encrypted {
+				return Image{}, false
+			}
+			raw := p.findJPEGStream(xobject.Key("Length").Int64(), width, height)
+			if raw == nil {
+				return Image{}, false
+			}
+			return Image{Data: raw, MediaType: "image/jpeg"}, true
+		case "FlateDecode":
+			return decodeRawImage(xobject, width, height)
+		}
+	case pdf.Null:
+		return decodeRawImage(xobject, width, height)
+	}
+	return Image{}, false
+}
+
+// decodeRawImage 将未压缩（或 Flate 压缩）的 8 位像素数据转换为 PNG
+func decodeRawImage(xobject pdf.Value, width, height int) (Image, bool) {
+	if xobject.Key("BitsPerComponent").Int64() != 8 {
+		return Image{}, false
+	}
+	components := colorComponents(xobject.Key("ColorSpace"))
+	if components == 0 {
+		return Image{}, false
+	}
+
+	rd := xobject.Reader()
+	defer rd.Close()
+	pixels, err := io.ReadAll(io.LimitReader(rd, int64(width*height*components)))
+	if err != nil || len(pixels) < width*height*components {
+		return Image{}, false
+	}
+
+	var img image.Image
+	rect := image.Rect(0, 0, width, height)
+	switch components {
+	case 1:
+		img = &image.Gray{Pix: pixels, Stride: width, Rect: rect}
+	case 3:
+		rgba := image.NewRGBA(rect)
+		for i, j := 0, 0; i < len(rgba.Pix); i, j = i+4, j+3 {
+			rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3] = pixels[j], pixels[j+1], pixels[j+2], 0xff
+		}
+		img = rgba
+	case 4:
+		cmyk := image.NewRGBA(rect)
+		for i, j := 0, 0; i < len(cmyk.Pix); i, j = i+4, j+4 {
+			r, g, b := color.CMYKToRGB(pixels[j], pixels[j+1], pixels[j+2], pixels[j+3])
+			cmyk.Pix[i], cmyk.Pix[i+1], cmyk.Pix[i+2], cmyk.Pix[i+3] = r, g, b, 0xff
+		}
+		img = cmyk
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Image{}, false
+	}
+	return Image{Data: buf.Bytes(), MediaType: "image/png"}, true
+}
+
+// colorComponents 返回颜色空间的分量数，不支持时返回 0
+func colorComponents(colorSpace pdf.Value) int {
+	name := colorSpace.Name()
+	if colorSpace.Kind() == pdf.Array && colorSpace.Len() > 0 {
+		name = colorSpace.Index(0).Name()
+		if name == "ICCBased" && colorSpace.Len() > 1 {
+			n := int(colorSpace.Index(1).Key("N").Int64())
+			if n == 1 || n == 3 || n == 4 {
+				return n
+			}
+			return 0
+		}
+	}
+	switch name {
+	case "DeviceGray", "CalGray":
+		return 1
+	case "DeviceRGB", "CalRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	}
+	return 0
+}
+
+// findJPEGStream 查找长度为 length、尺寸匹配的 JPEG 流
+// PDF 解析库不支持读取 DCTDecode 流的原始数据，按 stream 关键字和流长度定位
+func (p *parser) findJPEGStream(length int64, width, height int) []byte {
+	if length <= 0 {
+		return nil
+	}
+	if !p.indexed {
+		p.jpegStarts = indexJPEGStreams(p.data)
+		p.indexed = true
+	}
+	for _, start := range p.jpegStarts {
+		end := start + int(length)
+		if end > len(p.data) {
+			continue
+		}
+		if !bytes.HasPrefix(bytes.TrimLeft(p.data[end:], "\r\n \t"), []byte("endstream")) {
+			continue
+		}
+		raw := p.data[start:end]
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(raw))
+		if err != nil || cfg.Width != width || cfg.Height != height {
+			continue
+		}
+		return raw
+	}
+	return nil
+}
+
+// indexJPEGStreams 扫描一次文件，返回所有以 JPEG 文件头开始的流数据的起始位置
+func indexJPEGStreams(data []byte) []int {
+	var starts []int
+	marker := []byte("stream")
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], marker)
+		if i < 0 {
+			return starts
+		}
+		start := offset + i + len(marker)
+		offset = start
+
+		// stream 关键字后紧跟 CRLF 或 LF
+		if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+			start += 2
+		} else if bytes.HasPrefix(data[start:], []byte("\n")) {
+			start++
+		} else {
+			continue
+		}
+		if bytes.HasPrefix(data[start:], []byte{0xFF, 0xD8, 0xFF}) {
+			starts = append(starts, start)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// encodeJPEG 生成指定尺寸的 JPEG
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFindJPEGStream(t *testing.T) {
+	small := encodeJPEG(t, 8, 4)
+	large := encodeJPEG(t, 16, 16)
+
+	var data bytes.Buffer
+	data.WriteString("%PDF-1.4\n1 0 obj\n<< /Length 5 >>\nstream\nhello\nendstream\nendobj\n")
+	data.WriteString("2 0 obj\n<< /Filter /DCTDecode >>\nstream\r\n")
+	data.Write(small)
+	data.WriteString("\r\nendstream\nendobj\n3 0 obj\n<< /Filter /DCTDecode >>\nstream\n")
+	data.Write(large)
+	data.WriteString("\nendstream\nendobj\n")
+
+	p := &parser{data: data.Bytes()}
+	tests := []struct {
+		name          string
+		length        int64
+		width, height int
+		want          []byte
+	}{
+		{name: "第一个流", length: int64(len(small)), width: 8, height: 4, want: small},
+		{name: "第二个流", length: int64(len(large)), width: 16, height: 16, want: large},
+		{name: "尺寸不匹配", length: int64(len(large)), width: 8, height: 8},
+		{name: "长度不匹配", length: int64(len(small)) - 1, width: 8, height: 4},
+		{name: "长度无效", length: 0, width: 8, height: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.findJPEGStream(tt.length, tt.width, tt.height); !bytes.Equal(got, tt.want) {
+				t.Errorf("找到 %d 字节，期望 %d 字节", len(got), len(tt.want))
+			}
+		})
+	}
+	if len(p.jpegStarts) != 2 {
+		t.Errorf("索引了 %d 个 JPEG 流，期望 2 个", len(p.jpegStarts))
+	}
+}
+
+func TestParseInvalidPDF(t *testing.T) {
+	if _, err := Parse([]byte("not a pdf"), Options{}); err == nil {
+		t.Error("期望返回错误")
+	}
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// UpstreamError 上游返回的非 200 响应，保留原始状态码、响应体和响应头
+type UpstreamError struct {
+	StatusCode int
+	Body       []byte
+	Header     http.Header
+}
+
+func newUpstreamError(resp *http.Response, body []byte) *UpstreamError {
+	return &UpstreamError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Header:     resp.Header.Clone(),
+	}
+}
+
+// LocalError 本地处理失败（序列化请求、构建请求等），与上游无关
+type LocalError struct {
+	Err error
+}
+
+// localErrorf 创建本地错误
+func localErrorf(format string, args ...any) error {
+	return &LocalError{Err: fmt.Errorf(format, args...)}
+}
+
+func (e *LocalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LocalError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("目标 API 返回错误 (状态码: %d): %s", e.StatusCode, string(e.Body))
+}
+
+// Message 提取上游错误信息，兼容 OpenAI、Anthropic 和智谱的错误格式，无法解析时返回原始响应体
+func (e *UpstreamError) Message() string {
+	var payload map[string]any
+	if err := json.Unmarshal(e.Body, &payload); err == nil {
+		if errObj, ok := payload["error"].(map[string]any); ok {
+			if message, ok := errObj["message"].(string); ok && message != "" {
+				return message
+			}
+		}
+		if message, ok := payload["message"].(string); ok && message != "" {
+			return message
+		}
+	}
+
+	if body := strings.TrimSpace(string(e.Body)); body != "" {
+		return body
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// Code 提取上游错误码（如智谱的业务错误码），不存在时返回空
+func (e *UpstreamError) Code() any {
+	var payload map[string]any
+	if err := json.Unmarshal(e.Body, &payload); err != nil {
+		return nil
+	}
+	if errObj, ok := payload["error"].(map[string]any); ok {
+		return errObj["code"]
+	}
+	return nil
+}
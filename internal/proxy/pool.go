@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophertool/tool/log"
+)
+
+// 负载均衡策略
+const (
+	BalanceRoundRobin    = "round_robin"    // 平滑加权轮询
+	BalanceLeastInFlight = "least_inflight" // 最少在途请求
+)
+
+// Endpoint 上游节点
+type Endpoint struct {
+	URL    string
+	Weight int
+
+	inFlight            int
+	currentWeight       int
+	consecutiveFailures int
+	ejectedUntil        time.Time
+	probeHealthy        bool
+}
+
+// PoolOptions 节点池配置
+type PoolOptions struct {
+	Balance       string        // 负载均衡策略
+	MaxFailures   int           // 连续失败多少次后摘除节点
+	EjectDuration time.Duration // 节点被摘除的时长
+}
+
+// Pool 同一 API 族（OpenAI / Anthropic）的上游节点池
+type Pool struct {
+	name      string
+	options   PoolOptions
+	mutex     sync.Mutex
+	endpoints []*Endpoint
+}
+
+// NewPool 创建节点池
+// specs: 节点列表，格式为 url 或 url|weight
+func NewPool(name string, specs []string, options PoolOptions) *Pool {
+	pool := &Pool{name: name, options: options}
+	for _, spec := range specs {
+		url, weightStr, _ := strings.Cut(spec, "|")
+		weight := 1
+		if w, err := strconv.Atoi(strings.TrimSpace(weightStr)); err == nil && w > 0 {
+			weight = w
+		}
+		pool.endpoints = append(pool.endpoints, &Endpoint{
+			URL:          strings.TrimRight(strings.TrimSpace(url), "/"),
+			Weight:       weight,
+			probeHealthy: true,
+		})
+	}
+	return pool
+}
+
+// available 节点当前是否可用（未被被动摘除且主动探测健康）
+func (e *Endpoint) available(now time.Time) bool {
+	return e.probeHealthy && !now.Before(e.ejectedUntil)
+}
+
+// Acquire 选择一个节点并记为在途；exclude 中的节点（本次请求已失败过）仅在没有其他选择时使用
+// 所有节点都不可用时仍返回一个节点，避免完全拒绝服务
+func (p *Pool) Acquire(exclude map[*Endpoint]bool) *Endpoint {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	var candidates []*Endpoint
+	for _, e := range p.endpoints {
+		if e.available(now) && !exclude[e] {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, e := range p.endpoints {
+			if e.available(now) {
+				candidates = append(candidates, e)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		// 全部不可用：选择最早恢复的节点
+		candidates = []*Endpoint{p.endpoints[0]}
+		for _, e := range p.endpoints[1:] {
+			if e.ejectedUntil.Before(candidates[0].ejectedUntil) {
+				candidates[0] = e
+			}
+		}
+		log.Warnf("%s 上游节点全部不可用，尝试使用 %s", p.name, candidates[0].URL)
+	}
+
+	var selected *Endpoint
+	if p.options.Balance == BalanceLeastInFlight {
+		// 按权重归一化后选择在途请求最少的节点
+		for _, e := range candidates {
+			if selected == nil || e.inFlight*selected.Weight < selected.inFlight*e.Weight {
+				selected = e
+			}
+		}
+	} else {
+		// 平滑加权轮询
+		total := 0
+		for _, e := range candidates {
+			e.currentWeight += e.Weight
+			total += e.Weight
+			if selected == nil || e.currentWeight > selected.currentWeight {
+				selected = e
+			}
+		}
+		selected.currentWeight -= total
+	}
+
+	selected.inFlight++
+	return selected
+}
+
+// Release 请求结束时释放节点，并根据结果更新被动健康状态
+func (p *Pool) Release(e *Endpoint, success bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	e.inFlight--
+	if success {
+		e.consecutiveFailures = 0
+		return
+	}
+
+	e.consecutiveFailures++
+	if p.options.MaxFailures > 0 && e.consecutiveFailures >= p.options.MaxFailures {
+		e.ejectedUntil = time.Now().Add(p.options.EjectDuration)
+		e.consecutiveFailures = 0
+		log.Warnf("%s 上游节点 %s 连续失败 %d 次，摘除 %v", p.name, e.URL, p.options.MaxFailures, p.options.EjectDuration)
+	}
+}
+
+// StartHealthCheck 定期主动探测所有节点，interval <= 0 或只有一个节点时不启动（唯一的节点摘除后也只能继续使用它）
+// 能返回任意非 5xx 响应（包括 401/404）即视为节点可达
+func (p *Pool) StartHealthCheck(interval time.Duration, timeout time.Duration) {
+	if interval <= 0 || len(p.endpoints) < 2 {
+		return
+	}
+	client := &http.Client{Timeout: timeout}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			p.probeAll(client)
+		}
+	}()
+}
+
+func (p *Pool) probeAll(client *http.Client) {
+	p.mutex.Lock()
+	endpoints := append([]*Endpoint(nil), p.endpoints...)
+	p.mutex.Unlock()
+
+	for _, e := range endpoints {
+		healthy := probe(client, e.URL)
+
+		p.mutex.Lock()
+		if healthy != e.probeHealthy {
+			if healthy {
+				log.Infof("%s 上游节点 %s 探测恢复", p.name, e.URL)
+				e.ejectedUntil = time.Time{}
+				e.consecutiveFailures = 0
+			} else {
+				log.Warnf("%s 上游节点 %s 探测失败，暂停使用", p.name, e.URL)
+			}
+			e.probeHealthy = healthy
+		}
+		p.mutex.Unlock()
+	}
+}
+
+func probe(client *http.Client, url string) bool {
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// releaseOnClose 在响应体关闭时释放节点，使流式请求在整个传输期间都计为在途
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewPoolParsesWeights(t *testing.T) {
+	pool := NewPool("OpenAI", []string{"http://a/v1/", "http://b|3", "http://c|x", " http://d | 0 "}, PoolOptions{})
+	want := []struct {
+		url    string
+		weight int
+	}{
+		{"http://a/v1", 1},
+		{"http://b", 3},
+		{"http://c", 1},
+		{"http://d", 1},
+	}
+	if len(pool.endpoints) != len(want) {
+		t.Fatalf("节点数 = %d，期望 %d", len(pool.endpoints), len(want))
+	}
+	for i, w := range want {
+		if e := pool.endpoints[i]; e.URL != w.url || e.Weight != w.weight {
+			t.Errorf("节点 %d = %s|%d，期望 %s|%d", i, e.URL, e.Weight, w.url, w.weight)
+		}
+	}
+}
+
+func TestPoolRoundRobinWeights(t *testing.T) {
+	pool := NewPool("OpenAI", []string{"http://a|3", "http://b|1"}, PoolOptions{Balance: BalanceRoundRobin})
+	counts := make(map[string]int)
+	var sequence []string
+	for i := 0; i < 8; i++ {
+		e := pool.Acquire(nil)
+		counts[e.URL]++
+		sequence = append(sequence, e.URL)
+		pool.Release(e, true)
+	}
+	if counts["http://a"] != 6 || counts["http://b"] != 2 {
+		t.Errorf("选择次数 = %v，期望按 3:1 分配", counts)
+	}
+	// 平滑加权轮询不会连续选择同一个低权重节点
+	for i := 1; i < len(sequence); i++ {
+		if sequence[i] == "http://b" && sequence[i-1] == "http://b" {
+			t.Errorf("低权重节点被连续选择: %v", sequence)
+		}
+	}
+}
+
+func TestPoolLeastInFlight(t *testing.T) {
+	pool := NewPool("OpenAI", []string{"http://a", "http://b|2"}, PoolOptions{Balance: BalanceLeastInFlight})
+	first := pool.Acquire(nil)
+	second := pool.Acquire(nil)
+	if first == second {
+		t.Fatalf("两次都选择了 %s", first.URL)
+	}
+	// b 的权重为 2：各有一个在途请求时 b 的归一化负载更低
+	third := pool.Acquire(nil)
+	if third.URL != "http://b" {
+		t.Errorf("第三次选择 %s，期望 http://b", third.URL)
+	}
+}
+
+func TestPoolExcludeAndEject(t *testing.T) {
+	pool := NewPool("OpenAI", []string{"http://a", "http://b"}, PoolOptions{MaxFailures: 2, EjectDuration: time.Hour})
+	a, b := pool.endpoints[0], pool.endpoints[1]
+
+	// 本次请求已失败过的节点仅在没有其他选择时使用
+	for i := 0; i < 4; i++ {
+		e := pool.Acquire(map[*Endpoint]bool{a: true})
+		if e != b {
+			t.Fatalf("排除 a 后选择了 %s", e.URL)
+		}
+		pool.Release(e, true)
+	}
+	e := pool.Acquire(map[*Endpoint]bool{a: true, b: true})
+	pool.Release(e, true)
+
+	// 连续失败达到阈值后摘除
+	for i := 0; i < 2; i++ {
+		pool.Release(acquireEndpoint(t, pool, a), false)
+	}
+	for i := 0; i < 4; i++ {
+		e := pool.Acquire(nil)
+		if e != b {
+			t.Fatalf("a 被摘除后仍选择了 %s", e.URL)
+		}
+		pool.Release(e, true)
+	}
+
+	// 全部不可用时选择最早恢复的节点
+	for i := 0; i < 2; i++ {
+		pool.Release(acquireEndpoint(t, pool, b), false)
+	}
+	a.ejectedUntil = time.Now().Add(time.Minute)
+	if e := pool.Acquire(nil); e != a {
+		t.Errorf("全部摘除时选择了 %s，期望最早恢复的 http://a", e.URL)
+	}
+}
+
+// acquireEndpoint 选择到指定节点为止（测试中用于给特定节点记录失败）
+func acquireEndpoint(t *testing.T, p *Pool, target *Endpoint) *Endpoint {
+	t.Helper()
+	exclude := make(map[*Endpoint]bool)
+	for _, e := range p.endpoints {
+		if e != target {
+			exclude[e] = true
+		}
+	}
+	e := p.Acquire(exclude)
+	if e != target {
+		t.Fatalf("无法选择节点 %s", target.URL)
+	}
+	return e
+}
+
+func TestStartHealthCheckSkipsSingleEndpoint(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+	}))
+	defer server.Close()
+
+	NewPool("OpenAI", []string{server.URL}, PoolOptions{}).StartHealthCheck(5*time.Millisecond, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if got := probes.Load(); got != 0 {
+		t.Errorf("只有一个节点时探测了 %d 次", got)
+	}
+
+	NewPool("OpenAI", []string{server.URL, server.URL + "/other"}, PoolOptions{}).StartHealthCheck(5*time.Millisecond, time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for probes.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if probes.Load() == 0 {
+		t.Error("多个节点时没有探测")
+	}
+}
+
+func TestProbeAllRestoresEndpoint(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized) // 非 5xx 即视为可达
+	}))
+	defer server.Close()
+
+	pool := NewPool("OpenAI", []string{server.URL, server.URL + "/other"}, PoolOptions{})
+	e := pool.endpoints[0]
+	client := &http.Client{Timeout: time.Second}
+
+	pool.probeAll(client)
+	if e.probeHealthy {
+		t.Fatal("5xx 响应后节点仍为健康")
+	}
+	e.ejectedUntil = time.Now().Add(time.Hour)
+	healthy.Store(true)
+	pool.probeAll(client)
+	if !e.available(time.Now()) {
+		t.Error("探测恢复后节点仍不可用")
+	}
+}
@@ -2,7 +2,7 @@ package proxy
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,44 +16,56 @@ import (
 )
 
 type Proxy struct {
-	targetURL string
-	anthropicURL string
-	client    *http.Client
+	targets    *Pool // OpenAI 兼容上游节点池
+	anthropics *Pool // Anthropic 兼容上游节点池
+	client     *http.Client
+	retry      RetryPolicy
 }
 
 func NewProxy() *Proxy {
+	options := PoolOptions{
+		Balance:       config.AppConfig.UpstreamBalance,
+		MaxFailures:   config.AppConfig.UpstreamEjectFailures,
+		EjectDuration: time.Duration(config.AppConfig.UpstreamEjectSeconds) * time.Second,
+	}
+	targets := NewPool("OpenAI", config.AppConfig.TargetAPIURLs, options)
+	anthropics := NewPool("Anthropic", config.AppConfig.AnthropicAPIURLs, options)
+
+	probeInterval := time.Duration(config.AppConfig.UpstreamHealthCheckSeconds) * time.Second
+	targets.StartHealthCheck(probeInterval, 10*time.Second)
+	anthropics.StartHealthCheck(probeInterval, 10*time.Second)
+
 	return &Proxy{
-		targetURL: config.AppConfig.TargetAPIURL,
-		anthropicURL: config.AppConfig.AnthropicAPIURL,
+		targets:    targets,
+		anthropics: anthropics,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		retry: RetryPolicy{
+			MaxRetries: config.AppConfig.UpstreamMaxRetries,
+			BaseDelay:  time.Duration(config.AppConfig.UpstreamRetryBaseMs) * time.Millisecond,
+			MaxDelay:   time.Duration(config.AppConfig.UpstreamRetryMaxMs) * time.Millisecond,
+			Deadline:   time.Duration(config.AppConfig.UpstreamRetryDeadlineSeconds) * time.Second,
+		},
 	}
 }
 
-func (p *Proxy) ForwardRequest(requestData map[string]any, authHeader string) (map[string]any, error) {
+func (p *Proxy) ForwardRequest(ctx context.Context, requestData map[string]any, authHeader string) (map[string]any, error) {
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, localErrorf("序列化请求失败: %w", err)
 	}
 
-	log.Infof("转发请求到: %s/chat/completions", p.targetURL)
+	log.Infof("转发请求: /chat/completions")
 	log.Infof("请求体: %s", string(requestBody))
 
-	targetReq, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/chat/completions", p.targetURL),
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	targetReq.Header.Set("Content-Type", "application/json")
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
 	// 直接透传客户端的 Authorization header
-	targetReq.Header.Set("Authorization", authHeader)
+	header.Set("Authorization", authHeader)
 
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.targets, "POST", "/chat/completions", requestBody, header)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -68,7 +80,7 @@ func (p *Proxy) ForwardRequest(requestData map[string]any, authHeader string) (m
 	log.Infof("响应体: %s", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return nil, newUpstreamError(resp, respBody)
 	}
 
 	var responseData map[string]any
@@ -79,19 +91,15 @@ func (p *Proxy) ForwardRequest(requestData map[string]any, authHeader string) (m
 	return responseData, nil
 }
 
-func (p *Proxy) ForwardGetRequest(endpoint string, authHeader string) (map[string]any, error) {
-	targetURL := fmt.Sprintf("%s/%s", p.targetURL, endpoint)
-	log.Infof("转发 GET 请求到: %s", targetURL)
-
-	targetReq, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
+func (p *Proxy) ForwardGetRequest(ctx context.Context, endpoint string, authHeader string) (map[string]any, error) {
+	log.Infof("转发 GET 请求: /%s", endpoint)
 
+	header := make(http.Header)
 	// 直接透传客户端的 Authorization header
-	targetReq.Header.Set("Authorization", authHeader)
+	header.Set("Authorization", authHeader)
 
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.targets, "GET", "/"+endpoint, nil, header)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -106,7 +114,7 @@ func (p *Proxy) ForwardGetRequest(endpoint string, authHeader string) (map[strin
 	log.Infof("响应体: %s", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return nil, newUpstreamError(resp, respBody)
 	}
 
 	var responseData map[string]any
@@ -117,30 +125,38 @@ func (p *Proxy) ForwardGetRequest(endpoint string, authHeader string) (map[strin
 	return responseData, nil
 }
 
+// StreamConverter 流式响应转换器：将上游 SSE 数据转换为客户端期望的格式
+type StreamConverter interface {
+	// ConvertLine 处理上游的一行数据，返回需要写给客户端的内容（可以为空）
+	ConvertLine(line []byte) []byte
+	// Finish 上游流结束时调用，返回需要补发给客户端的内容
+	Finish() []byte
+}
+
 // ForwardStreamRequest 转发流式请求并流式返回响应
 func (p *Proxy) ForwardStreamRequest(c *gin.Context, requestData map[string]any, authHeader string) error {
+	return p.ForwardStreamRequestWithConverter(c, requestData, authHeader, nil)
+}
+
+// ForwardStreamRequestWithConverter 转发流式请求，并使用 converter 转换每一行响应（为 nil 时原样透传）
+func (p *Proxy) ForwardStreamRequestWithConverter(c *gin.Context, requestData map[string]any, authHeader string, converter StreamConverter) error {
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
+		return localErrorf("序列化请求失败: %w", err)
 	}
 
-	log.Infof("转发流式请求到: %s/chat/completions", p.targetURL)
+	log.Infof("转发流式请求: /chat/completions")
 
-	targetReq, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/chat/completions", p.targetURL),
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return fmt.Errorf("创建请求失败: %w", err)
-	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", authHeader)
+	header.Set("Accept", "text/event-stream")
 
-	targetReq.Header.Set("Content-Type", "application/json")
-	targetReq.Header.Set("Authorization", authHeader)
-	targetReq.Header.Set("Accept", "text/event-stream")
+	// 客户端断开时取消上游请求
+	ctx := c.Request.Context()
 
-	// 发送请求
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.targets, "POST", "/chat/completions", requestBody, header)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -149,70 +165,30 @@ func (p *Proxy) ForwardStreamRequest(c *gin.Context, requestData map[string]any,
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return newUpstreamError(resp, respBody)
 	}
 
-	// 设置响应头为 SSE 格式
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Transfer-Encoding", "chunked")
-
-	// 创建一个 flusher
-	flusher, ok := c.Writer.(http.Flusher)
-	if !ok {
-		return fmt.Errorf("streaming not supported")
-	}
-
-	// 逐行读取并转发响应
-	reader := bufio.NewReader(resp.Body)
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				log.Infof("流式响应完成")
-				break
-			}
-			return fmt.Errorf("读取流式响应失败: %w", err)
-		}
-
-		// 写入客户端
-		if _, err := c.Writer.Write(line); err != nil {
-			return fmt.Errorf("写入响应失败: %w", err)
-		}
-
-		// 立即刷新
-		flusher.Flush()
-	}
-
-	return nil
+	return pipeStream(c, resp.Body, converter, "流式响应完成")
 }
 
 // ForwardAnthropicRequest 转发 Anthropic 格式的请求
-func (p *Proxy) ForwardAnthropicRequest(requestData map[string]any, authHeader string) (map[string]any, error) {
+func (p *Proxy) ForwardAnthropicRequest(ctx context.Context, requestData map[string]any, authHeader string) (map[string]any, error) {
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, localErrorf("序列化请求失败: %w", err)
 	}
 
-	log.Infof("转发 Anthropic 请求到: %s/v1/messages", p.anthropicURL)
+	log.Infof("转发 Anthropic 请求: /v1/messages")
 	log.Infof("请求体: %s", string(requestBody))
 
-	targetReq, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/v1/messages", p.anthropicURL),
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	targetReq.Header.Set("Content-Type", "application/json")
-	targetReq.Header.Set("Authorization", authHeader)
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", authHeader)
 	// Anthropic API 需要 x-api-key header 或者使用特定的 version
-	targetReq.Header.Set("anthropic-version", "2023-06-01")
+	header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.anthropics, "POST", "/v1/messages", requestBody, header)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -227,7 +203,7 @@ func (p *Proxy) ForwardAnthropicRequest(requestData map[string]any, authHeader s
 	log.Infof("Anthropic 响应体: %s", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return nil, newUpstreamError(resp, respBody)
 	}
 
 	var responseData map[string]any
@@ -240,29 +216,29 @@ func (p *Proxy) ForwardAnthropicRequest(requestData map[string]any, authHeader s
 
 // ForwardAnthropicStreamRequest 转发 Anthropic 流式请求并流式返回响应
 func (p *Proxy) ForwardAnthropicStreamRequest(c *gin.Context, requestData map[string]any, authHeader string) error {
+	return p.ForwardAnthropicStreamRequestWithConverter(c, requestData, authHeader, nil)
+}
+
+// ForwardAnthropicStreamRequestWithConverter 转发 Anthropic 流式请求，并使用 converter 转换每一行响应（为 nil 时原样透传）
+func (p *Proxy) ForwardAnthropicStreamRequestWithConverter(c *gin.Context, requestData map[string]any, authHeader string, converter StreamConverter) error {
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
+		return localErrorf("序列化请求失败: %w", err)
 	}
 
-	log.Infof("转发 Anthropic 流式请求到: %s/v1/messages", p.anthropicURL)
+	log.Infof("转发 Anthropic 流式请求: /v1/messages")
 
-	targetReq, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/v1/messages", p.anthropicURL),
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return fmt.Errorf("创建请求失败: %w", err)
-	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", authHeader)
+	header.Set("anthropic-version", "2023-06-01")
+	header.Set("Accept", "text/event-stream")
 
-	targetReq.Header.Set("Content-Type", "application/json")
-	targetReq.Header.Set("Authorization", authHeader)
-	targetReq.Header.Set("anthropic-version", "2023-06-01")
-	targetReq.Header.Set("Accept", "text/event-stream")
+	// 客户端断开时取消上游请求
+	ctx := c.Request.Context()
 
-	// 发送请求
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.anthropics, "POST", "/v1/messages", requestBody, header)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -271,69 +247,29 @@ func (p *Proxy) ForwardAnthropicStreamRequest(c *gin.Context, requestData map[st
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return newUpstreamError(resp, respBody)
 	}
 
-	// 设置响应头为 SSE 格式
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Transfer-Encoding", "chunked")
-
-	// 创建一个 flusher
-	flusher, ok := c.Writer.(http.Flusher)
-	if !ok {
-		return fmt.Errorf("streaming not supported")
-	}
-
-	// 逐行读取并转发响应
-	reader := bufio.NewReader(resp.Body)
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				log.Infof("Anthropic 流式响应完成")
-				break
-			}
-			return fmt.Errorf("读取流式响应失败: %w", err)
-		}
-
-		// 写入客户端
-		if _, err := c.Writer.Write(line); err != nil {
-			return fmt.Errorf("写入响应失败: %w", err)
-		}
-
-		// 立即刷新
-		flusher.Flush()
-	}
-
-	return nil
+	return pipeStream(c, resp.Body, converter, "Anthropic 流式响应完成")
 }
 
 // ForwardAnthropicCountTokensRequest 转发 Anthropic Count Tokens 请求
-func (p *Proxy) ForwardAnthropicCountTokensRequest(requestData map[string]any, authHeader string) (map[string]any, error) {
+func (p *Proxy) ForwardAnthropicCountTokensRequest(ctx context.Context, requestData map[string]any, authHeader string) (map[string]any, error) {
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, localErrorf("序列化请求失败: %w", err)
 	}
 
-	log.Infof("转发 Anthropic Count Tokens 请求到: %s/v1/messages/count_tokens", p.anthropicURL)
+	log.Infof("转发 Anthropic Count Tokens 请求: /v1/messages/count_tokens")
 	log.Infof("请求体: %s", string(requestBody))
 
-	targetReq, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/v1/messages/count_tokens", p.anthropicURL),
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	targetReq.Header.Set("Content-Type", "application/json")
-	targetReq.Header.Set("Authorization", authHeader)
-	targetReq.Header.Set("anthropic-version", "2023-06-01")
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", authHeader)
+	header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := p.client.Do(targetReq)
+	// 发送请求（失败时按重试策略重试）
+	resp, err := p.send(ctx, p.anthropics, "POST", "/v1/messages/count_tokens", requestBody, header)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -348,7 +284,7 @@ func (p *Proxy) ForwardAnthropicCountTokensRequest(requestData map[string]any, a
 	log.Infof("Anthropic Count Tokens 响应体: %s", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("目标 API 返回错误 (状态码: %d): %s", resp.StatusCode, string(respBody))
+		return nil, newUpstreamError(resp, respBody)
 	}
 
 	var responseData map[string]any
@@ -358,3 +294,60 @@ func (p *Proxy) ForwardAnthropicCountTokensRequest(requestData map[string]any, a
 
 	return responseData, nil
 }
+
+// pipeStream 以 SSE 格式逐行读取上游响应并写给客户端
+func pipeStream(c *gin.Context, body io.Reader, converter StreamConverter, doneMessage string) error {
+	// 设置响应头为 SSE 格式
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Transfer-Encoding", "chunked")
+
+	// 创建一个 flusher
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		return localErrorf("streaming not supported")
+	}
+
+	write := func(data []byte) error {
+		if len(data) == 0 {
+			return nil
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return fmt.Errorf("写入响应失败: %w", err)
+		}
+		// 立即刷新
+		flusher.Flush()
+		return nil
+	}
+
+	// 逐行读取并转发响应
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if converter != nil {
+				line = converter.ConvertLine(line)
+			}
+			if werr := write(line); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				log.Info(doneMessage)
+				break
+			}
+			if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+				log.Infof("客户端已断开，停止读取上游流式响应")
+				return ctxErr
+			}
+			return fmt.Errorf("读取流式响应失败: %w", err)
+		}
+	}
+
+	if converter != nil {
+		return write(converter.Finish())
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gophertool/tool/log"
+)
+
+// RetryPolicy 上游请求重试策略
+type RetryPolicy struct {
+	MaxRetries int           // 最大重试次数（不含首次请求）
+	BaseDelay  time.Duration // 首次重试的基础等待时间，之后按指数增长
+	MaxDelay   time.Duration // 单次等待时间上限（不限制 Retry-After）
+	Deadline   time.Duration // 所有尝试的总时长上限，0 表示不限制
+}
+
+// send 从节点池中选择上游发送请求，对连接错误、429 和 5xx 响应按策略重试（优先换用其他节点）
+// 每次尝试都会重新构建请求体；返回的响应由调用方负责关闭。
+// 流式请求在拿到 200 响应之前不会向客户端写入任何内容，因此重试只会发生在首字节之前。
+// ctx 取消（客户端断开或服务关闭）时立即中止请求和重试等待。
+func (p *Proxy) send(ctx context.Context, pool *Pool, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	policy := p.retry
+	start := time.Now()
+	failed := make(map[*Endpoint]bool)
+
+	for attempt := 0; ; attempt++ {
+		endpoint := pool.Acquire(failed)
+		url := endpoint.URL + path
+		log.Infof("请求上游: %s %s", method, url)
+
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+		// 记录请求头是否已经写出：写出之后的失败无法确定上游是否已经处理
+		var written atomic.Bool
+		trace := &httptrace.ClientTrace{WroteHeaders: func() { written.Store(true) }}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, url, bodyReader)
+		if err != nil {
+			pool.Release(endpoint, true)
+			return nil, &LocalError{Err: err}
+		}
+		req.Header = header.Clone()
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			pool.Release(endpoint, errors.Is(err, context.Canceled))
+			failed[endpoint] = true
+		} else {
+			success := resp.StatusCode < http.StatusInternalServerError
+			if !success {
+				failed[endpoint] = true
+			}
+			resp.Body = &releaseOnClose{
+				ReadCloser: resp.Body,
+				release:    func() { pool.Release(endpoint, success) },
+			}
+		}
+
+		retryable := false
+		var delay time.Duration
+		if err != nil {
+			retryable = isRetryableError(err, written.Load())
+		} else if isRetryableStatus(resp.StatusCode) {
+			retryable = true
+			delay = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if !retryable || attempt >= policy.MaxRetries {
+			return resp, err
+		}
+
+		if delay <= 0 {
+			delay = backoffDelay(policy, attempt)
+		}
+		if policy.Deadline > 0 && time.Since(start)+delay > policy.Deadline {
+			log.Warnf("上游重试超出总时长限制 (%v)，不再重试", policy.Deadline)
+			return resp, err
+		}
+
+		if err != nil {
+			log.Warnf("请求上游失败，%v 后进行第 %d 次重试: %v", delay, attempt+1, err)
+		} else {
+			log.Warnf("上游返回状态码 %d，%v 后进行第 %d 次重试", resp.StatusCode, delay, attempt+1)
+			// 丢弃本次响应，复用连接
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			log.Infof("客户端已取消请求，停止重试")
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// isRetryableStatus 429 和 5xx（501 除外）可以重试
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests ||
+		(statusCode >= 500 && statusCode != http.StatusNotImplemented)
+}
+
+// isRetryableError 只重试上游一定没有收到请求的错误：建立连接、DNS 解析、TLS 握手失败，
+// 或写出请求头之前连接就已断开（requestWritten 为 false）
+// 请求发出后的 EOF、连接重置可能发生在上游已接受请求之后，重试 POST 会产生重复的计费调用，因此不重试；
+// 超时和主动取消也不重试
+func isRetryableError(err error, requestWritten bool) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	return !requestWritten
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoffDelay 计算指数退避等待时间，并加入随机抖动（[delay/2, delay)）
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.BaseDelay << attempt
+	if delay <= 0 || (policy.MaxDelay > 0 && delay > policy.MaxDelay) {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
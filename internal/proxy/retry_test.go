@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		written bool
+		want    bool
+	}{
+		{name: "建立连接失败", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, written: false, want: true},
+		{name: "连接被拒绝", err: fmt.Errorf("post: %w", syscall.ECONNREFUSED), written: false, want: true},
+		{name: "DNS 解析失败", err: &net.DNSError{Err: "no such host", Name: "upstream"}, written: false, want: true},
+		{name: "发出请求前连接断开", err: io.EOF, written: false, want: true},
+		{name: "发出请求后连接断开", err: io.EOF, written: true, want: false},
+		{name: "发出请求后连接重置", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, written: true, want: false},
+		{name: "发出请求后响应不完整", err: io.ErrUnexpectedEOF, written: true, want: false},
+		{name: "客户端取消", err: context.Canceled, written: false, want: false},
+		{name: "超时", err: fmt.Errorf("post: %w", context.DeadlineExceeded), written: false, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err, tt.written); got != tt.want {
+				t.Errorf("isRetryableError(%v, %v) = %v，期望 %v", tt.err, tt.written, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusBadRequest:          false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusNotImplemented:      false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+	}
+	for status, want := range tests {
+		if got := isRetryableStatus(status); got != want {
+			t.Errorf("isRetryableStatus(%d) = %v，期望 %v", status, got, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{value: "-1", min: 0, max: 0},
+		{value: "soon", min: 0, max: 0},
+		{value: time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat), min: 3 * time.Second, max: 5 * time.Second},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v，期望在 [%v, %v] 之间", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration // 抖动前的等待时间，实际结果在 [max/2, max] 之间
+	}{
+		{attempt: 0, max: 100 * time.Millisecond},
+		{attempt: 1, max: 200 * time.Millisecond},
+		{attempt: 3, max: 800 * time.Millisecond},
+		{attempt: 4, max: time.Second},
+		{attempt: 70, max: time.Second}, // 移位溢出时使用上限
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := backoffDelay(policy, tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("backoffDelay(attempt=%d) = %v，期望在 [%v, %v] 之间", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+	if got := backoffDelay(RetryPolicy{}, 2); got != 0 {
+		t.Errorf("未配置等待时间时 backoffDelay = %v，期望 0", got)
+	}
+}
+
+// newTestProxy 创建使用指定节点的代理，重试等待时间很短
+func newTestProxy(urls ...string) (*Proxy, *Pool) {
+	pool := NewPool("OpenAI", urls, PoolOptions{Balance: BalanceRoundRobin})
+	return &Proxy{
+		targets: pool,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		retry:   RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+	}, pool
+}
+
+func TestSendDoesNotRetryAfterRequestWritten(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.ReadAll(r.Body)
+		// 读取请求后直接断开连接，上游可能已经开始处理
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	p, pool := newTestProxy(server.URL)
+	_, err := p.send(context.Background(), pool, "POST", "/chat/completions", []byte(`{}`), make(http.Header))
+	if err == nil {
+		t.Fatal("期望返回错误")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("上游收到 %d 次请求，期望 1 次（不重试）", got)
+	}
+}
+
+func TestSendRetriesConnectionRefused(t *testing.T) {
+	// 关闭的端口：连接被拒绝，上游一定没有收到请求
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + listener.Addr().String()
+	listener.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, pool := newTestProxy(refused, server.URL)
+	// 轮询从第一个节点开始，失败后换用第二个节点
+	resp, err := p.send(context.Background(), pool, "POST", "/chat/completions", []byte(`{}`), make(http.Header))
+	if err != nil {
+		t.Fatalf("期望重试后成功: %v", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("可用节点收到 %d 次请求，期望 1 次", got)
+	}
+}
+
+func TestSendRetriesRetryableStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, pool := newTestProxy(server.URL)
+	resp, err := p.send(context.Background(), pool, "POST", "/chat/completions", []byte(`{}`), make(http.Header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests.Load() != 3 {
+		t.Errorf("状态码 %d，请求 %d 次，期望重试两次后返回 200", resp.StatusCode, requests.Load())
+	}
+}
+
+func TestSendStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	p, pool := newTestProxy(server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := p.send(ctx, pool, "POST", "/chat/completions", []byte(`{}`), make(http.Header))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v，期望 context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("取消后仍等待了 %v", elapsed)
+	}
+}
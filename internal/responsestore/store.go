@@ -0,0 +1,117 @@
+package responsestore
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Entry 保存的响应记录
+type Entry struct {
+	ID        string
+	Owner     string         // 所属 API Key 的哈希，防止跨用户读取
+	Response  map[string]any // Responses API 的 response 对象
+	Messages  []any          // 截至该响应的完整对话（chat 格式，不含 instructions）
+	ExpiresAt time.Time
+}
+
+// Store 进程内的响应存储，按最近使用淘汰，并在过期后失效
+type Store struct {
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	maxEntries int
+	ttl        time.Duration
+}
+
+// New 创建响应存储
+func New(maxEntries int, ttl time.Duration) *Store {
+	return &Store{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Save 保存响应记录，超过容量时淘汰最久未使用的记录
+func (s *Store) Save(id, owner string, response map[string]any, messages []any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &Entry{
+		ID:        id,
+		Owner:     owner,
+		Response:  response,
+		Messages:  messages,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	if elem, ok := s.entries[id]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[id] = s.order.PushFront(entry)
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.removeElement(s.order.Back())
+	}
+}
+
+// Get 读取响应记录，记录不存在、已过期或不属于 owner 时返回 false
+func (s *Store) Get(id, owner string) (*Entry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*Entry)
+	if time.Now().After(entry.ExpiresAt) {
+		s.removeElement(elem)
+		return nil, false
+	}
+	if entry.Owner != owner {
+		return nil, false
+	}
+	s.order.MoveToFront(elem)
+
+	// 返回消息的深拷贝，避免后续请求（如图片识别）原地修改已保存的历史
+	result := *entry
+	result.Messages = cloneMessages(entry.Messages)
+	return &result, true
+}
+
+// Delete 删除响应记录
+func (s *Store) Delete(id, owner string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok || elem.Value.(*Entry).Owner != owner {
+		return false
+	}
+	s.removeElement(elem)
+	return true
+}
+
+func (s *Store) removeElement(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*Entry).ID)
+}
+
+// cloneMessages 通过 JSON 往返深拷贝消息
+func cloneMessages(messages []any) []any {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return messages
+	}
+	var cloned []any
+	if err := json.Unmarshal(data, &cloned); err != nil {
+		return messages
+	}
+	return cloned
+}
@@ -0,0 +1,102 @@
+package responsestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreGet(t *testing.T) {
+	s := New(10, time.Hour)
+	s.Save("resp_1", "alice", map[string]any{"id": "resp_1"}, []any{map[string]any{"role": "user", "content": "hi"}})
+
+	tests := []struct {
+		name  string
+		id    string
+		owner string
+		want  bool
+	}{
+		{name: "存在", id: "resp_1", owner: "alice", want: true},
+		{name: "其他用户", id: "resp_1", owner: "bob"},
+		{name: "不存在", id: "resp_2", owner: "alice"},
+	}
+	for _, tt := range tests {
+		if _, found := s.Get(tt.id, tt.owner); found != tt.want {
+			t.Errorf("%s: Get(%s, %s) = %v，期望 %v", tt.name, tt.id, tt.owner, found, tt.want)
+		}
+	}
+
+	if s.Delete("resp_1", "bob") {
+		t.Error("不应删除其他用户的记录")
+	}
+	if !s.Delete("resp_1", "alice") {
+		t.Error("期望删除成功")
+	}
+	if _, found := s.Get("resp_1", "alice"); found {
+		t.Error("删除后不应再读到记录")
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	s := New(10, 10*time.Millisecond)
+	s.Save("resp_1", "alice", map[string]any{}, nil)
+	if _, found := s.Get("resp_1", "alice"); !found {
+		t.Fatal("记录过期前期望读到")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, found := s.Get("resp_1", "alice"); found {
+		t.Error("记录过期后不应再读到")
+	}
+	if len(s.entries) != 0 || s.order.Len() != 0 {
+		t.Errorf("过期记录没有被移除: %d 条", len(s.entries))
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	s := New(2, time.Hour)
+	s.Save("resp_1", "alice", map[string]any{}, nil)
+	s.Save("resp_2", "alice", map[string]any{}, nil)
+	// 读取 resp_1 后 resp_2 成为最久未使用的记录
+	s.Get("resp_1", "alice")
+	s.Save("resp_3", "alice", map[string]any{}, nil)
+
+	for id, want := range map[string]bool{"resp_1": true, "resp_2": false, "resp_3": true} {
+		if _, found := s.Get(id, "alice"); found != want {
+			t.Errorf("%s 是否保留 = %v，期望 %v", id, found, want)
+		}
+	}
+
+	// 重复保存同一 ID 只更新记录，不淘汰其他记录
+	s.Save("resp_3", "alice", map[string]any{"status": "completed"}, nil)
+	entry, found := s.Get("resp_3", "alice")
+	if !found || entry.Response["status"] != "completed" || s.order.Len() != 2 {
+		t.Errorf("更新后的记录 = %v，共 %d 条", entry, s.order.Len())
+	}
+}
+
+// TestStoreChain 按 previous_response_id 串联多轮对话：每轮读取上一轮的消息，追加后保存为新的响应
+func TestStoreChain(t *testing.T) {
+	s := New(10, time.Hour)
+	s.Save("resp_1", "alice", map[string]any{"id": "resp_1"}, []any{
+		map[string]any{"role": "user", "content": "hi"},
+		map[string]any{"role": "assistant", "content": "hello"},
+	})
+
+	previous, found := s.Get("resp_1", "alice")
+	if !found {
+		t.Fatal("期望读到 resp_1")
+	}
+	// 修改读到的历史（如图片识别原地替换 content）不影响已保存的记录
+	previous.Messages[0].(map[string]any)["content"] = "changed"
+	messages := append(previous.Messages, map[string]any{"role": "user", "content": "again"}, map[string]any{"role": "assistant", "content": "ok"})
+	s.Save("resp_2", "alice", map[string]any{"id": "resp_2"}, messages)
+
+	first, _ := s.Get("resp_1", "alice")
+	if len(first.Messages) != 2 || first.Messages[0].(map[string]any)["content"] != "hi" {
+		t.Errorf("resp_1 的消息被修改: %v", first.Messages)
+	}
+	second, _ := s.Get("resp_2", "alice")
+	if len(second.Messages) != 4 || second.Messages[2].(map[string]any)["content"] != "again" {
+		t.Errorf("resp_2 的消息 = %v", second.Messages)
+	}
+}
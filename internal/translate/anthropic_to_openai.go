@@ -0,0 +1,349 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnthropicToOpenAIRequest 将 Anthropic Messages 请求转换为 OpenAI chat/completions 请求
+func AnthropicToOpenAIRequest(req map[string]any) (map[string]any, error) {
+	messages, ok := req["messages"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("缺少 messages 字段")
+	}
+
+	out := map[string]any{}
+	copyFields(out, req, "model", "max_tokens", "temperature", "top_p", "stream", "thinking")
+
+	// 流式请求需要上游在最后返回 usage，用于生成 message_delta
+	if stream, ok := req["stream"].(bool); ok && stream {
+		out["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if stops, ok := req["stop_sequences"].([]any); ok && len(stops) > 0 {
+		out["stop"] = stops
+	}
+	if metadata, ok := req["metadata"].(map[string]any); ok {
+		if userID, ok := metadata["user_id"].(string); ok && userID != "" {
+			out["user"] = userID
+		}
+	}
+
+	// 工具定义
+	if tools, ok := req["tools"].([]any); ok && len(tools) > 0 {
+		out["tools"] = convertAnthropicTools(tools)
+	}
+	if choice, ok := req["tool_choice"].(map[string]any); ok {
+		if toolChoice := convertAnthropicToolChoice(choice); toolChoice != nil {
+			out["tool_choice"] = toolChoice
+		}
+		if disable, ok := choice["disable_parallel_tool_use"].(bool); ok && disable {
+			out["parallel_tool_calls"] = false
+		}
+	}
+
+	var outMessages []any
+
+	// system 可以是字符串或文本块数组
+	if system := contentText(req["system"]); system != "" {
+		outMessages = append(outMessages, map[string]any{"role": "system", "content": system})
+	}
+
+	for _, item := range messages {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role, _ := msg["role"].(string)
+		if role == "assistant" {
+			if converted := convertAnthropicAssistantMessage(msg["content"]); converted != nil {
+				outMessages = append(outMessages, converted)
+			}
+			continue
+		}
+		outMessages = append(outMessages, convertAnthropicUserMessage(msg["content"])...)
+	}
+
+	out["messages"] = outMessages
+	return out, nil
+}
+
+// convertAnthropicUserMessage 转换 user 消息
+// tool_result 块拆分为独立的 tool 消息（放在前面），其余内容合并为一条 user 消息
+func convertAnthropicUserMessage(content any) []any {
+	if text, ok := content.(string); ok {
+		return []any{map[string]any{"role": "user", "content": text}}
+	}
+
+	blocks, ok := content.([]any)
+	if !ok {
+		return nil
+	}
+
+	var toolMessages []any
+	var parts []any
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "tool_result":
+			text, images := splitToolResultContent(block["content"])
+			if isError, ok := block["is_error"].(bool); ok && isError && text != "" {
+				text = "[error] " + text
+			}
+			toolMessages = append(toolMessages, map[string]any{
+				"role":         "tool",
+				"tool_call_id": block["tool_use_id"],
+				"content":      text,
+			})
+			// OpenAI 的 tool 消息不支持图片，放到随后的 user 消息中
+			parts = append(parts, images...)
+		default:
+			if part := convertAnthropicBlockToPart(block); part != nil {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	result := toolMessages
+	if len(parts) > 0 {
+		result = append(result, map[string]any{"role": "user", "content": parts})
+	}
+	return result
+}
+
+// convertAnthropicAssistantMessage 转换 assistant 消息，tool_use 块转换为 tool_calls
+func convertAnthropicAssistantMessage(content any) map[string]any {
+	if text, ok := content.(string); ok {
+		return map[string]any{"role": "assistant", "content": text}
+	}
+
+	blocks, ok := content.([]any)
+	if !ok {
+		return nil
+	}
+
+	var textParts []string
+	var toolCalls []any
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "text":
+			if text, ok := block["text"].(string); ok {
+				textParts = append(textParts, text)
+			}
+		case "tool_use":
+			toolCalls = append(toolCalls, map[string]any{
+				"id":   block["id"],
+				"type": "function",
+				"function": map[string]any{
+					"name":      block["name"],
+					"arguments": marshalArguments(block["input"]),
+				},
+			})
+		}
+	}
+
+	message := map[string]any{"role": "assistant"}
+	if len(textParts) > 0 || len(toolCalls) == 0 {
+		message["content"] = strings.Join(textParts, "")
+	} else {
+		message["content"] = nil
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+	return message
+}
+
+// convertAnthropicBlockToPart 将 Anthropic 内容块转换为 OpenAI content part
+func convertAnthropicBlockToPart(block map[string]any) map[string]any {
+	switch block["type"] {
+	case "text":
+		if text, ok := block["text"].(string); ok {
+			return map[string]any{"type": "text", "text": text}
+		}
+	case "image":
+		source, _ := block["source"].(map[string]any)
+		switch source["type"] {
+		case "base64":
+			mediaType, _ := source["media_type"].(string)
+			data, _ := source["data"].(string)
+			return map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": fmt.Sprintf("data:%s;base64,%s", mediaType, data)},
+			}
+		case "url":
+			return map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": source["url"]},
+			}
+		}
+	}
+	return nil
+}
+
+// splitToolResultContent 拆分 tool_result 的 content：文本合并为字符串，图片转换为 image_url part
+func splitToolResultContent(content any) (string, []any) {
+	if text, ok := content.(string); ok {
+		return text, nil
+	}
+	blocks, ok := content.([]any)
+	if !ok {
+		return "", nil
+	}
+
+	var textParts []string
+	var images []any
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		part := convertAnthropicBlockToPart(block)
+		if part == nil {
+			continue
+		}
+		if part["type"] == "text" {
+			textParts = append(textParts, part["text"].(string))
+		} else {
+			images = append(images, part)
+		}
+	}
+	return strings.Join(textParts, "\n"), images
+}
+
+// convertAnthropicTools 将 Anthropic 工具定义转换为 OpenAI function 工具
+func convertAnthropicTools(tools []any) []any {
+	var out []any
+	for _, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		// 服务端工具（如 web_search）带有 type 字段，OpenAI 格式无法表达，跳过
+		if toolType, ok := tool["type"].(string); ok && toolType != "" && toolType != "custom" {
+			continue
+		}
+		fn := map[string]any{"name": tool["name"]}
+		if desc, ok := tool["description"].(string); ok && desc != "" {
+			fn["description"] = desc
+		}
+		if schema, ok := tool["input_schema"].(map[string]any); ok {
+			fn["parameters"] = schema
+		}
+		out = append(out, map[string]any{"type": "function", "function": fn})
+	}
+	return out
+}
+
+// convertAnthropicToolChoice 将 Anthropic tool_choice 转换为 OpenAI tool_choice
+func convertAnthropicToolChoice(choice map[string]any) any {
+	switch choice["type"] {
+	case "auto":
+		return "auto"
+	case "any":
+		return "required"
+	case "none":
+		return "none"
+	case "tool":
+		return map[string]any{"type": "function", "function": map[string]any{"name": choice["name"]}}
+	}
+	return nil
+}
+
+// OpenAIToAnthropicResponse 将 OpenAI chat.completion 响应转换为 Anthropic message 对象
+func OpenAIToAnthropicResponse(resp map[string]any, model string) map[string]any {
+	var content []any
+	finishReason := ""
+
+	if choices, ok := resp["choices"].([]any); ok && len(choices) > 0 {
+		choice, _ := choices[0].(map[string]any)
+		finishReason, _ = choice["finish_reason"].(string)
+		message, _ := choice["message"].(map[string]any)
+
+		if reasoning, ok := message["reasoning_content"].(string); ok && reasoning != "" {
+			content = append(content, map[string]any{"type": "thinking", "thinking": reasoning})
+		}
+		if text := contentText(message["content"]); text != "" {
+			content = append(content, map[string]any{"type": "text", "text": text})
+		}
+		if toolCalls, ok := message["tool_calls"].([]any); ok {
+			for _, tc := range toolCalls {
+				call, ok := tc.(map[string]any)
+				if !ok {
+					continue
+				}
+				fn, _ := call["function"].(map[string]any)
+				id, _ := call["id"].(string)
+				if id == "" {
+					id = newID("toolu_")
+				}
+				content = append(content, map[string]any{
+					"type":  "tool_use",
+					"id":    id,
+					"name":  fn["name"],
+					"input": parseArguments(fn["arguments"]),
+				})
+			}
+		}
+	}
+
+	if content == nil {
+		content = []any{}
+	}
+	if respModel, ok := resp["model"].(string); ok && respModel != "" {
+		model = respModel
+	}
+
+	return map[string]any{
+		"id":            newID("msg_"),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         model,
+		"content":       content,
+		"stop_reason":   OpenAIFinishReasonToAnthropic(finishReason),
+		"stop_sequence": nil,
+		"usage":         OpenAIUsageToAnthropic(resp["usage"]),
+	}
+}
+
+// OpenAIFinishReasonToAnthropic 将 OpenAI finish_reason 映射为 Anthropic stop_reason
+func OpenAIFinishReasonToAnthropic(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter", "sensitive":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// OpenAIUsageToAnthropic 将 OpenAI usage 转换为 Anthropic usage
+func OpenAIUsageToAnthropic(usage any) map[string]any {
+	u, _ := usage.(map[string]any)
+	prompt, _ := toInt(u["prompt_tokens"])
+	completion, _ := toInt(u["completion_tokens"])
+
+	cached := 0
+	if details, ok := u["prompt_tokens_details"].(map[string]any); ok {
+		cached, _ = toInt(details["cached_tokens"])
+	}
+
+	result := map[string]any{
+		"input_tokens":  prompt - cached,
+		"output_tokens": completion,
+	}
+	if cached > 0 {
+		result["cache_read_input_tokens"] = cached
+	}
+	return result
+}
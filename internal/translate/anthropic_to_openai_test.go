@@ -0,0 +1,99 @@
+package translate
+
+import "testing"
+
+func TestAnthropicToOpenAIRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  string
+		want string
+	}{
+		{
+			name: "基本字段和 system",
+			req: `{"model":"claude","max_tokens":100,"temperature":0.5,"stream":true,"stop_sequences":["END"],"metadata":{"user_id":"u1"},
+				"system":[{"type":"text","text":"be brief"}],"messages":[{"role":"user","content":"hi"}]}`,
+			want: `{"model":"claude","max_tokens":100,"temperature":0.5,"stream":true,"stream_options":{"include_usage":true},"stop":["END"],"user":"u1",
+				"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name: "图片块",
+			req: `{"messages":[{"role":"user","content":[{"type":"text","text":"look"},
+				{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}},
+				{"type":"image","source":{"type":"url","url":"https://example.com/cat.png"}}]}]}`,
+			want: `{"messages":[{"role":"user","content":[{"type":"text","text":"look"},
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]}`,
+		},
+		{
+			name: "工具调用和结果",
+			req: `{"tools":[{"name":"weather","description":"get weather","input_schema":{"type":"object"}},{"type":"web_search_20250305","name":"web_search"}],
+				"tool_choice":{"type":"tool","name":"weather","disable_parallel_tool_use":true},
+				"messages":[
+					{"role":"assistant","content":[{"type":"text","text":"checking"},{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}]},
+					{"role":"user","content":[
+						{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"sunny"},{"type":"image","source":{"type":"url","url":"https://example.com/map.png"}}]},
+						{"type":"tool_result","tool_use_id":"toolu_2","is_error":true,"content":"timeout"},
+						{"type":"text","text":"thanks"}]}]}`,
+			want: `{"tools":[{"type":"function","function":{"name":"weather","description":"get weather","parameters":{"type":"object"}}}],
+				"tool_choice":{"type":"function","function":{"name":"weather"}},"parallel_tool_calls":false,
+				"messages":[
+					{"role":"assistant","content":"checking","tool_calls":[{"id":"toolu_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+					{"role":"tool","tool_call_id":"toolu_1","content":"sunny"},
+					{"role":"tool","tool_call_id":"toolu_2","content":"[error] timeout"},
+					{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/map.png"}},{"type":"text","text":"thanks"}]}]}`,
+		},
+		{
+			name: "只有工具调用的 assistant 消息",
+			req:  `{"tool_choice":{"type":"any"},"messages":[{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"ls","input":{}}]}]}`,
+			want: `{"tool_choice":"required","messages":[{"role":"assistant","content":null,"tool_calls":[{"id":"toolu_1","type":"function","function":{"name":"ls","arguments":"{}"}}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := AnthropicToOpenAIRequest(decodeJSON(t, tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkJSON(t, out, tt.want)
+		})
+	}
+
+	if _, err := AnthropicToOpenAIRequest(map[string]any{"model": "claude"}); err == nil {
+		t.Error("缺少 messages 时期望返回错误")
+	}
+}
+
+func TestOpenAIToAnthropicResponse(t *testing.T) {
+	resp := decodeJSON(t, `{"model":"glm-4.6","choices":[{"finish_reason":"tool_calls","message":{
+		"reasoning_content":"thinking...","content":"let me check",
+		"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]}}],
+		"usage":{"prompt_tokens":100,"completion_tokens":20,"prompt_tokens_details":{"cached_tokens":30}}}`)
+	out := OpenAIToAnthropicResponse(resp, "claude")
+	delete(out, "id")
+	checkJSON(t, out, `{"type":"message","role":"assistant","model":"glm-4.6","stop_reason":"tool_use","stop_sequence":null,
+		"content":[{"type":"thinking","thinking":"thinking..."},{"type":"text","text":"let me check"},
+			{"type":"tool_use","id":"call_1","name":"weather","input":{"city":"Paris"}}],
+		"usage":{"input_tokens":70,"output_tokens":20,"cache_read_input_tokens":30}}`)
+
+	empty := OpenAIToAnthropicResponse(map[string]any{}, "claude")
+	delete(empty, "id")
+	checkJSON(t, empty, `{"type":"message","role":"assistant","model":"claude","content":[],"stop_reason":"end_turn","stop_sequence":null,
+		"usage":{"input_tokens":0,"output_tokens":0}}`)
+}
+
+func TestOpenAIFinishReasonToAnthropic(t *testing.T) {
+	tests := map[string]string{
+		"stop":           "end_turn",
+		"length":         "max_tokens",
+		"tool_calls":     "tool_use",
+		"function_call":  "tool_use",
+		"content_filter": "refusal",
+		"sensitive":      "refusal",
+		"":               "end_turn",
+	}
+	for reason, want := range tests {
+		if got := OpenAIFinishReasonToAnthropic(reason); got != want {
+			t.Errorf("OpenAIFinishReasonToAnthropic(%q) = %s，期望 %s", reason, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package translate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// newID 生成带前缀的随机 ID
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// copyFields 将 src 中存在的字段原样复制到 dst
+func copyFields(dst, src map[string]any, keys ...string) {
+	for _, key := range keys {
+		if value, ok := src[key]; ok && value != nil {
+			dst[key] = value
+		}
+	}
+}
+
+// toInt 将 JSON 数字转换为 int
+func toInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	}
+	return 0, false
+}
+
+// contentText 提取 content 中的全部文本（content 可以是字符串或内容块数组）
+func contentText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, item := range v {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if text, ok := block["text"].(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// parseDataURI 解析 data URI，返回媒体类型与 base64 数据
+func parseDataURI(uri string) (mediaType string, data string, ok bool) {
+	if !strings.HasPrefix(uri, "data:") {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, _, _ = strings.Cut(header, ";")
+	if mediaType == "" {
+		mediaType = "image/jpeg"
+	}
+	return mediaType, data, true
+}
+
+// parseArguments 将工具调用参数字符串解析为对象，解析失败时保留原始字符串
+func parseArguments(arguments any) any {
+	str, ok := arguments.(string)
+	if !ok {
+		if arguments == nil {
+			return map[string]any{}
+		}
+		return arguments
+	}
+	if strings.TrimSpace(str) == "" {
+		return map[string]any{}
+	}
+	var parsed any
+	if err := json.Unmarshal([]byte(str), &parsed); err != nil {
+		return map[string]any{"_raw": str}
+	}
+	return parsed
+}
+
+// marshalArguments 将工具调用参数对象序列化为字符串
+func marshalArguments(input any) string {
+	if input == nil {
+		return "{}"
+	}
+	if str, ok := input.(string); ok {
+		return str
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
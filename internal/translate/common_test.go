@@ -0,0 +1,100 @@
+package translate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decodeJSON 将测试用的 JSON 文本解析为对象
+func decodeJSON(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("无效的测试 JSON %q: %v", s, err)
+	}
+	return v
+}
+
+// checkJSON 比较转换结果与期望的 JSON（经过一次序列化，忽略 Go 类型差异）
+func checkJSON(t *testing.T, got any, want string) {
+	t.Helper()
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	json.Unmarshal(data, &gotValue)
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("无效的期望 JSON %q: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("转换结果\n  %s\n期望\n  %s", data, want)
+	}
+}
+
+func TestParseDataURI(t *testing.T) {
+	tests := []struct {
+		uri           string
+		wantMediaType string
+		wantData      string
+		wantOK        bool
+	}{
+		{uri: "data:image/png;base64,AAAA", wantMediaType: "image/png", wantData: "AAAA", wantOK: true},
+		{uri: "data:;base64,AAAA", wantMediaType: "image/jpeg", wantData: "AAAA", wantOK: true},
+		{uri: "data:image/png;base64", wantOK: false},
+		{uri: "https://example.com/cat.png", wantOK: false},
+	}
+	for _, tt := range tests {
+		mediaType, data, ok := parseDataURI(tt.uri)
+		if mediaType != tt.wantMediaType || data != tt.wantData || ok != tt.wantOK {
+			t.Errorf("parseDataURI(%q) = %q, %q, %v", tt.uri, mediaType, data, ok)
+		}
+	}
+}
+
+func TestArguments(t *testing.T) {
+	parseTests := []struct {
+		arguments any
+		want      string
+	}{
+		{arguments: `{"city":"Paris"}`, want: `{"city":"Paris"}`},
+		{arguments: "", want: `{}`},
+		{arguments: nil, want: `{}`},
+		{arguments: `{"city":`, want: `{"_raw":"{\"city\":"}`},
+		{arguments: map[string]any{"a": 1}, want: `{"a":1}`},
+	}
+	for _, tt := range parseTests {
+		checkJSON(t, parseArguments(tt.arguments), tt.want)
+	}
+
+	marshalTests := []struct {
+		input any
+		want  string
+	}{
+		{input: map[string]any{"city": "Paris"}, want: `{"city":"Paris"}`},
+		{input: nil, want: `{}`},
+		{input: `{"raw":true}`, want: `{"raw":true}`},
+	}
+	for _, tt := range marshalTests {
+		if got := marshalArguments(tt.input); got != tt.want {
+			t.Errorf("marshalArguments(%v) = %s，期望 %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestContentText(t *testing.T) {
+	tests := []struct {
+		content any
+		want    string
+	}{
+		{content: "hello", want: "hello"},
+		{content: []any{map[string]any{"type": "text", "text": "a"}, map[string]any{"type": "image"}, map[string]any{"type": "text", "text": "b"}}, want: "a\nb"},
+		{content: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := contentText(tt.content); got != tt.want {
+			t.Errorf("contentText(%v) = %q，期望 %q", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OpenAIToAnthropicRequest 将 OpenAI chat/completions 请求转换为 Anthropic Messages 请求
+// defaultMaxTokens: 请求未指定 max_tokens 时使用的默认值（Anthropic 要求必填）
+func OpenAIToAnthropicRequest(req map[string]any, defaultMaxTokens int) (map[string]any, error) {
+	messages, ok := req["messages"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("缺少 messages 字段")
+	}
+
+	out := map[string]any{}
+	copyFields(out, req, "model", "temperature", "top_p", "stream", "thinking")
+
+	// max_tokens（新版 SDK 使用 max_completion_tokens）
+	maxTokens := defaultMaxTokens
+	if v, ok := toInt(req["max_completion_tokens"]); ok && v > 0 {
+		maxTokens = v
+	} else if v, ok := toInt(req["max_tokens"]); ok && v > 0 {
+		maxTokens = v
+	}
+	out["max_tokens"] = maxTokens
+
+	// stop 可以是字符串或字符串数组
+	switch stop := req["stop"].(type) {
+	case string:
+		if stop != "" {
+			out["stop_sequences"] = []any{stop}
+		}
+	case []any:
+		if len(stop) > 0 {
+			out["stop_sequences"] = stop
+		}
+	}
+
+	if user, ok := req["user"].(string); ok && user != "" {
+		out["metadata"] = map[string]any{"user_id": user}
+	}
+
+	// 工具定义
+	if tools, ok := req["tools"].([]any); ok && len(tools) > 0 {
+		out["tools"] = convertOpenAITools(tools)
+	}
+	if toolChoice := convertOpenAIToolChoice(req["tool_choice"]); toolChoice != nil {
+		if parallel, ok := req["parallel_tool_calls"].(bool); ok && !parallel {
+			toolChoice["disable_parallel_tool_use"] = true
+		}
+		out["tool_choice"] = toolChoice
+	}
+
+	// 消息：system/developer 合并为顶层 system，其余按角色转换为内容块
+	var systemParts []string
+	var converted []map[string]any
+	appendMessage := func(role string, blocks []any) {
+		if len(blocks) == 0 {
+			return
+		}
+		// Anthropic 要求 user/assistant 交替出现，连续的同角色消息合并
+		if n := len(converted); n > 0 && converted[n-1]["role"] == role {
+			converted[n-1]["content"] = append(converted[n-1]["content"].([]any), blocks...)
+			return
+		}
+		converted = append(converted, map[string]any{"role": role, "content": blocks})
+	}
+
+	for _, item := range messages {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role, _ := msg["role"].(string)
+
+		switch role {
+		case "system", "developer":
+			if text := contentText(msg["content"]); text != "" {
+				systemParts = append(systemParts, text)
+			}
+		case "assistant":
+			// reasoning_content 没有签名，无法还原为 thinking 块，直接丢弃
+			blocks := convertOpenAIContent(msg["content"])
+			if toolCalls, ok := msg["tool_calls"].([]any); ok {
+				for _, tc := range toolCalls {
+					call, ok := tc.(map[string]any)
+					if !ok {
+						continue
+					}
+					fn, _ := call["function"].(map[string]any)
+					name, _ := fn["name"].(string)
+					id, _ := call["id"].(string)
+					if id == "" {
+						id = newID("toolu_")
+					}
+					blocks = append(blocks, map[string]any{
+						"type":  "tool_use",
+						"id":    id,
+						"name":  name,
+						"input": parseArguments(fn["arguments"]),
+					})
+				}
+			}
+			appendMessage("assistant", blocks)
+		case "tool", "function":
+			toolCallID, _ := msg["tool_call_id"].(string)
+			result := map[string]any{
+				"type":        "tool_result",
+				"tool_use_id": toolCallID,
+			}
+			if blocks := convertOpenAIContent(msg["content"]); len(blocks) > 0 {
+				result["content"] = blocks
+			} else {
+				result["content"] = ""
+			}
+			appendMessage("user", []any{result})
+		default:
+			appendMessage("user", convertOpenAIContent(msg["content"]))
+		}
+	}
+
+	if len(systemParts) > 0 {
+		out["system"] = strings.Join(systemParts, "\n\n")
+	}
+
+	outMessages := make([]any, 0, len(converted))
+	for _, m := range converted {
+		outMessages = append(outMessages, m)
+	}
+	out["messages"] = outMessages
+
+	return out, nil
+}
+
+// convertOpenAIContent 将 OpenAI 消息 content（字符串或 parts 数组）转换为 Anthropic 内容块
+func convertOpenAIContent(content any) []any {
+	switch v := content.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []any{map[string]any{"type": "text", "text": v}}
+	case []any:
+		var blocks []any
+		for _, item := range v {
+			part, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			partType, _ := part["type"].(string)
+			switch partType {
+			case "text":
+				if text, ok := part["text"].(string); ok && text != "" {
+					blocks = append(blocks, map[string]any{"type": "text", "text": text})
+				}
+			case "image_url":
+				if block := convertOpenAIImage(part["image_url"]); block != nil {
+					blocks = append(blocks, block)
+				}
+			default:
+				// 已经是 Anthropic 格式的内容块（例如图片识别后插入的 text），原样保留
+				blocks = append(blocks, part)
+			}
+		}
+		return blocks
+	}
+	return nil
+}
+
+// convertOpenAIImage 将 image_url 转换为 Anthropic image 块
+func convertOpenAIImage(imageURL any) map[string]any {
+	var url string
+	switch v := imageURL.(type) {
+	case string:
+		url = v
+	case map[string]any:
+		url, _ = v["url"].(string)
+	}
+	if url == "" {
+		return nil
+	}
+
+	if mediaType, data, ok := parseDataURI(url); ok {
+		return map[string]any{
+			"type": "image",
+			"source": map[string]any{
+				"type":       "base64",
+				"media_type": mediaType,
+				"data":       data,
+			},
+		}
+	}
+
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return map[string]any{
+			"type": "image",
+			"source": map[string]any{
+				"type": "url",
+				"url":  url,
+			},
+		}
+	}
+
+	// 裸 base64 数据
+	return map[string]any{
+		"type": "image",
+		"source": map[string]any{
+			"type":       "base64",
+			"media_type": "image/jpeg",
+			"data":       url,
+		},
+	}
+}
+
+// convertOpenAITools 将 OpenAI function 工具转换为 Anthropic 工具定义
+func convertOpenAITools(tools []any) []any {
+	var out []any
+	for _, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		fn, ok := tool["function"].(map[string]any)
+		if !ok {
+			continue
+		}
+		converted := map[string]any{"name": fn["name"]}
+		if desc, ok := fn["description"].(string); ok && desc != "" {
+			converted["description"] = desc
+		}
+		if params, ok := fn["parameters"].(map[string]any); ok {
+			converted["input_schema"] = params
+		} else {
+			converted["input_schema"] = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		out = append(out, converted)
+	}
+	return out
+}
+
+// convertOpenAIToolChoice 将 OpenAI tool_choice 转换为 Anthropic tool_choice
+func convertOpenAIToolChoice(choice any) map[string]any {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return map[string]any{"type": "auto"}
+		case "none":
+			return map[string]any{"type": "none"}
+		case "required":
+			return map[string]any{"type": "any"}
+		}
+	case map[string]any:
+		if fn, ok := v["function"].(map[string]any); ok {
+			return map[string]any{"type": "tool", "name": fn["name"]}
+		}
+	}
+	return nil
+}
+
+// AnthropicToOpenAIResponse 将 Anthropic message 响应转换为 OpenAI chat.completion 对象
+func AnthropicToOpenAIResponse(resp map[string]any, model string) map[string]any {
+	var textParts []string
+	var reasoningParts []string
+	var toolCalls []any
+
+	content, _ := resp["content"].([]any)
+	for _, item := range content {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "text":
+			if text, ok := block["text"].(string); ok {
+				textParts = append(textParts, text)
+			}
+		case "thinking":
+			if thinking, ok := block["thinking"].(string); ok {
+				reasoningParts = append(reasoningParts, thinking)
+			}
+		case "tool_use":
+			toolCalls = append(toolCalls, map[string]any{
+				"id":   block["id"],
+				"type": "function",
+				"function": map[string]any{
+					"name":      block["name"],
+					"arguments": marshalArguments(block["input"]),
+				},
+			})
+		}
+	}
+
+	message := map[string]any{
+		"role":    "assistant",
+		"content": strings.Join(textParts, ""),
+	}
+	if len(reasoningParts) > 0 {
+		message["reasoning_content"] = strings.Join(reasoningParts, "")
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	if respModel, ok := resp["model"].(string); ok && respModel != "" {
+		model = respModel
+	}
+	id, _ := resp["id"].(string)
+	if id == "" {
+		id = newID("chatcmpl-")
+	}
+	stopReason, _ := resp["stop_reason"].(string)
+
+	return map[string]any{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []any{
+			map[string]any{
+				"index":         0,
+				"message":       message,
+				"finish_reason": AnthropicStopReasonToOpenAI(stopReason),
+			},
+		},
+		"usage": AnthropicUsageToOpenAI(resp["usage"]),
+	}
+}
+
+// AnthropicStopReasonToOpenAI 将 Anthropic stop_reason 映射为 OpenAI finish_reason
+func AnthropicStopReasonToOpenAI(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// AnthropicUsageToOpenAI 将 Anthropic usage 转换为 OpenAI usage
+func AnthropicUsageToOpenAI(usage any) map[string]any {
+	u, _ := usage.(map[string]any)
+	input, _ := toInt(u["input_tokens"])
+	output, _ := toInt(u["output_tokens"])
+	cacheRead, _ := toInt(u["cache_read_input_tokens"])
+	cacheCreation, _ := toInt(u["cache_creation_input_tokens"])
+
+	prompt := input + cacheRead + cacheCreation
+	result := map[string]any{
+		"prompt_tokens":     prompt,
+		"completion_tokens": output,
+		"total_tokens":      prompt + output,
+	}
+	if cacheRead > 0 {
+		result["prompt_tokens_details"] = map[string]any{"cached_tokens": cacheRead}
+	}
+	return result
+}
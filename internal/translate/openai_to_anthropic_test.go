@@ -0,0 +1,108 @@
+package translate
+
+import "testing"
+
+func TestOpenAIToAnthropicRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  string
+		want string
+	}{
+		{
+			name: "默认 max_tokens 和 system 合并",
+			req: `{"model":"glm-4.6","stop":"END","user":"u1","messages":[
+				{"role":"system","content":"be brief"},{"role":"developer","content":[{"type":"text","text":"use chinese"}]},
+				{"role":"user","content":"hi"}]}`,
+			want: `{"model":"glm-4.6","max_tokens":4096,"stop_sequences":["END"],"metadata":{"user_id":"u1"},"system":"be brief\n\nuse chinese",
+				"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+		{
+			name: "max_completion_tokens 优先",
+			req:  `{"max_tokens":100,"max_completion_tokens":200,"stop":["a","b"],"messages":[{"role":"user","content":"hi"}]}`,
+			want: `{"max_tokens":200,"stop_sequences":["a","b"],"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+		{
+			name: "图片",
+			req: `{"messages":[{"role":"user","content":[
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"image_url","image_url":"https://example.com/cat.png"},
+				{"type":"image_url","image_url":{"url":"BBBB"}},
+				{"type":"text","text":""}]}]}`,
+			want: `{"max_tokens":4096,"messages":[{"role":"user","content":[
+				{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}},
+				{"type":"image","source":{"type":"url","url":"https://example.com/cat.png"}},
+				{"type":"image","source":{"type":"base64","media_type":"image/jpeg","data":"BBBB"}}]}]}`,
+		},
+		{
+			name: "工具调用和结果合并为交替的消息",
+			req: `{"tools":[{"type":"function","function":{"name":"weather","description":"get weather","parameters":{"type":"object"}}},{"type":"function","function":{"name":"ls"}}],
+				"tool_choice":"required","parallel_tool_calls":false,"messages":[
+				{"role":"user","content":"weather?"},
+				{"role":"assistant","content":"checking","tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"ls","arguments":""}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"},
+				{"role":"tool","tool_call_id":"call_2","content":""},
+				{"role":"user","content":"thanks"}]}`,
+			want: `{"max_tokens":4096,
+				"tools":[{"name":"weather","description":"get weather","input_schema":{"type":"object"}},{"name":"ls","input_schema":{"type":"object","properties":{}}}],
+				"tool_choice":{"type":"any","disable_parallel_tool_use":true},
+				"messages":[
+					{"role":"user","content":[{"type":"text","text":"weather?"}]},
+					{"role":"assistant","content":[{"type":"text","text":"checking"},
+						{"type":"tool_use","id":"call_1","name":"weather","input":{"city":"Paris"}},
+						{"type":"tool_use","id":"call_2","name":"ls","input":{}}]},
+					{"role":"user","content":[
+						{"type":"tool_result","tool_use_id":"call_1","content":[{"type":"text","text":"sunny"}]},
+						{"type":"tool_result","tool_use_id":"call_2","content":""},
+						{"type":"text","text":"thanks"}]}]}`,
+		},
+		{
+			name: "指定工具",
+			req:  `{"tool_choice":{"type":"function","function":{"name":"weather"}},"messages":[]}`,
+			want: `{"max_tokens":4096,"tool_choice":{"type":"tool","name":"weather"},"messages":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := OpenAIToAnthropicRequest(decodeJSON(t, tt.req), 4096)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkJSON(t, out, tt.want)
+		})
+	}
+
+	if _, err := OpenAIToAnthropicRequest(map[string]any{}, 4096); err == nil {
+		t.Error("缺少 messages 时期望返回错误")
+	}
+}
+
+func TestAnthropicToOpenAIResponse(t *testing.T) {
+	resp := decodeJSON(t, `{"id":"msg_1","model":"claude","stop_reason":"tool_use","content":[
+		{"type":"thinking","thinking":"hmm","signature":"sig"},{"type":"text","text":"let me "},{"type":"text","text":"check"},
+		{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}],
+		"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":20,"cache_creation_input_tokens":3}}`)
+	out := AnthropicToOpenAIResponse(resp, "glm-4.6")
+	delete(out, "created")
+	checkJSON(t, out, `{"id":"msg_1","object":"chat.completion","model":"claude","choices":[{"index":0,"finish_reason":"tool_calls",
+		"message":{"role":"assistant","content":"let me check","reasoning_content":"hmm",
+			"tool_calls":[{"id":"toolu_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]}}],
+		"usage":{"prompt_tokens":33,"completion_tokens":5,"total_tokens":38,"prompt_tokens_details":{"cached_tokens":20}}}`)
+}
+
+func TestAnthropicStopReasonToOpenAI(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"max_tokens":    "length",
+		"tool_use":      "tool_calls",
+		"refusal":       "content_filter",
+		"":              "stop",
+	}
+	for reason, want := range tests {
+		if got := AnthropicStopReasonToOpenAI(reason); got != want {
+			t.Errorf("AnthropicStopReasonToOpenAI(%q) = %s，期望 %s", reason, got, want)
+		}
+	}
+}
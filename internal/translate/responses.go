@@ -0,0 +1,344 @@
+package translate
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewResponseID 生成 Responses API 的响应 ID
+func NewResponseID() string {
+	return newID("resp_")
+}
+
+// ResponsesToChatRequest 将 OpenAI Responses 请求转换为 chat/completions 请求
+// history: previous_response_id 对应的历史消息（chat 格式，不含 instructions）
+// 返回转换后的请求，以及本轮对话的完整消息（历史 + 本轮输入，用于保存）
+func ResponsesToChatRequest(req map[string]any, history []any) (map[string]any, []any, error) {
+	input, err := ResponsesInputToMessages(req["input"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversation := make([]any, 0, len(history)+len(input))
+	conversation = append(conversation, history...)
+	conversation = append(conversation, input...)
+
+	// instructions 不会随 previous_response_id 延续，只作用于本轮
+	messages := make([]any, 0, len(conversation)+1)
+	if instructions, ok := req["instructions"].(string); ok && instructions != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": instructions})
+	}
+	messages = append(messages, conversation...)
+
+	out := map[string]any{"messages": messages}
+	copyFields(out, req, "model", "temperature", "top_p", "stream", "parallel_tool_calls", "user")
+
+	if v, ok := toInt(req["max_output_tokens"]); ok && v > 0 {
+		out["max_tokens"] = v
+	}
+	if stream, ok := req["stream"].(bool); ok && stream {
+		out["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if tools, ok := req["tools"].([]any); ok && len(tools) > 0 {
+		if converted := convertResponsesTools(tools); len(converted) > 0 {
+			out["tools"] = converted
+		}
+	}
+	switch choice := req["tool_choice"].(type) {
+	case string:
+		out["tool_choice"] = choice
+	case map[string]any:
+		if name, ok := choice["name"].(string); ok {
+			out["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": name}}
+		}
+	}
+
+	// text.format -> response_format
+	if text, ok := req["text"].(map[string]any); ok {
+		if format, ok := text["format"].(map[string]any); ok {
+			switch format["type"] {
+			case "json_object":
+				out["response_format"] = map[string]any{"type": "json_object"}
+			case "json_schema":
+				out["response_format"] = map[string]any{
+					"type": "json_schema",
+					"json_schema": map[string]any{
+						"name":   format["name"],
+						"schema": format["schema"],
+						"strict": format["strict"],
+					},
+				}
+			}
+		}
+	}
+
+	return out, conversation, nil
+}
+
+// ResponsesInputToMessages 将 Responses 的 input（字符串或 item 数组）转换为 chat 消息
+func ResponsesInputToMessages(input any) ([]any, error) {
+	switch v := input.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []any{map[string]any{"role": "user", "content": v}}, nil
+	case []any:
+		var messages []any
+		for _, item := range v {
+			entry, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			itemType, _ := entry["type"].(string)
+			switch itemType {
+			case "function_call":
+				call := map[string]any{
+					"id":   entry["call_id"],
+					"type": "function",
+					"function": map[string]any{
+						"name":      entry["name"],
+						"arguments": entry["arguments"],
+					},
+				}
+				// 连续的 function_call 合并到同一条 assistant 消息
+				if n := len(messages); n > 0 {
+					if last, ok := messages[n-1].(map[string]any); ok && last["role"] == "assistant" {
+						toolCalls, _ := last["tool_calls"].([]any)
+						last["tool_calls"] = append(toolCalls, call)
+						continue
+					}
+				}
+				messages = append(messages, map[string]any{
+					"role":       "assistant",
+					"content":    nil,
+					"tool_calls": []any{call},
+				})
+			case "function_call_output":
+				messages = append(messages, map[string]any{
+					"role":         "tool",
+					"tool_call_id": entry["call_id"],
+					"content":      contentTextOrString(entry["output"]),
+				})
+			case "message", "":
+				role, _ := entry["role"].(string)
+				if role == "" {
+					role = "user"
+				}
+				if role == "developer" {
+					role = "system"
+				}
+				messages = append(messages, map[string]any{
+					"role":    role,
+					"content": convertResponsesContent(entry["content"], role),
+				})
+			case "reasoning", "item_reference":
+				// 推理摘要和引用项无法映射到 chat 格式，忽略
+			default:
+				return nil, fmt.Errorf("不支持的 input 类型: %s", itemType)
+			}
+		}
+		return messages, nil
+	}
+	return nil, fmt.Errorf("input 字段格式无效")
+}
+
+// contentTextOrString 将 function_call_output 的 output 转换为字符串
+func contentTextOrString(output any) string {
+	if str, ok := output.(string); ok {
+		return str
+	}
+	return contentText(output)
+}
+
+// convertResponsesContent 将 Responses 的消息内容转换为 chat content
+func convertResponsesContent(content any, role string) any {
+	parts, ok := content.([]any)
+	if !ok {
+		return content
+	}
+
+	// assistant 消息只保留文本
+	if role == "assistant" {
+		return contentText(parts)
+	}
+
+	var out []any
+	for _, item := range parts {
+		part, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch part["type"] {
+		case "input_text", "output_text", "text":
+			out = append(out, map[string]any{"type": "text", "text": part["text"]})
+		case "input_image":
+			url, _ := part["image_url"].(string)
+			if url == "" {
+				continue
+			}
+			imageURL := map[string]any{"url": url}
+			if detail, ok := part["detail"].(string); ok && detail != "" {
+				imageURL["detail"] = detail
+			}
+			out = append(out, map[string]any{"type": "image_url", "image_url": imageURL})
+		}
+	}
+	return out
+}
+
+// convertResponsesTools 将 Responses 的 function 工具转换为 chat 工具定义（内置工具忽略）
+func convertResponsesTools(tools []any) []any {
+	var out []any
+	for _, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok || tool["type"] != "function" {
+			continue
+		}
+		fn := map[string]any{"name": tool["name"]}
+		copyFields(fn, tool, "description", "parameters", "strict")
+		out = append(out, map[string]any{"type": "function", "function": fn})
+	}
+	return out
+}
+
+// ChatToResponsesResponse 将 chat.completion 响应转换为 Responses 的 response 对象
+// 返回 response 对象以及对应的 assistant 消息（chat 格式，用于保存历史）
+func ChatToResponsesResponse(chatResp map[string]any, responseID string, req map[string]any) (map[string]any, map[string]any) {
+	var text string
+	var toolCalls []any
+	finishReason := ""
+
+	if choices, ok := chatResp["choices"].([]any); ok && len(choices) > 0 {
+		choice, _ := choices[0].(map[string]any)
+		finishReason, _ = choice["finish_reason"].(string)
+		message, _ := choice["message"].(map[string]any)
+		text = contentText(message["content"])
+		toolCalls, _ = message["tool_calls"].([]any)
+	}
+
+	var output []any
+	if text != "" {
+		output = append(output, responsesMessageItem(newID("msg_"), text))
+	}
+	for _, tc := range toolCalls {
+		call, ok := tc.(map[string]any)
+		if !ok {
+			continue
+		}
+		fn, _ := call["function"].(map[string]any)
+		arguments, _ := fn["arguments"].(string)
+		name, _ := fn["name"].(string)
+		callID, _ := call["id"].(string)
+		output = append(output, responsesFunctionCallItem(newID("fc_"), callID, name, arguments))
+	}
+
+	model, _ := chatResp["model"].(string)
+	if model == "" {
+		model, _ = req["model"].(string)
+	}
+
+	resp := newResponsesObject(responseID, model, req, "completed")
+	resp["output"] = nonNilSlice(output)
+	resp["usage"] = chatUsageToResponses(chatResp["usage"])
+	if finishReason == "length" {
+		resp["status"] = "incomplete"
+		resp["incomplete_details"] = map[string]any{"reason": "max_output_tokens"}
+	}
+
+	return resp, chatAssistantMessage(text, toolCalls)
+}
+
+// newResponsesObject 生成 response 对象的公共字段
+func newResponsesObject(responseID, model string, req map[string]any, status string) map[string]any {
+	resp := map[string]any{
+		"id":                   responseID,
+		"object":               "response",
+		"created_at":           time.Now().Unix(),
+		"status":               status,
+		"model":                model,
+		"output":               []any{},
+		"parallel_tool_calls":  true,
+		"previous_response_id": nil,
+		"instructions":         nil,
+		"incomplete_details":   nil,
+		"error":                nil,
+		"usage":                nil,
+		"metadata":             map[string]any{},
+		"tools":                []any{},
+		"tool_choice":          "auto",
+	}
+	copyFields(resp, req, "previous_response_id", "instructions", "metadata", "tools", "tool_choice",
+		"temperature", "top_p", "max_output_tokens", "parallel_tool_calls", "user")
+	return resp
+}
+
+// responsesMessageItem 生成 message 类型的输出项
+func responsesMessageItem(itemID, text string) map[string]any {
+	return map[string]any{
+		"type":   "message",
+		"id":     itemID,
+		"status": "completed",
+		"role":   "assistant",
+		"content": []any{map[string]any{
+			"type":        "output_text",
+			"text":        text,
+			"annotations": []any{},
+		}},
+	}
+}
+
+// responsesFunctionCallItem 生成 function_call 类型的输出项
+func responsesFunctionCallItem(itemID, callID, name, arguments string) map[string]any {
+	return map[string]any{
+		"type":      "function_call",
+		"id":        itemID,
+		"status":    "completed",
+		"call_id":   callID,
+		"name":      name,
+		"arguments": arguments,
+	}
+}
+
+// chatAssistantMessage 根据输出文本和工具调用生成 chat 格式的 assistant 消息
+func chatAssistantMessage(text string, toolCalls []any) map[string]any {
+	message := map[string]any{"role": "assistant", "content": text}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+		if text == "" {
+			message["content"] = nil
+		}
+	}
+	return message
+}
+
+// chatUsageToResponses 将 chat usage 转换为 Responses usage
+func chatUsageToResponses(usage any) map[string]any {
+	u, _ := usage.(map[string]any)
+	prompt, _ := toInt(u["prompt_tokens"])
+	completion, _ := toInt(u["completion_tokens"])
+
+	cached := 0
+	if details, ok := u["prompt_tokens_details"].(map[string]any); ok {
+		cached, _ = toInt(details["cached_tokens"])
+	}
+	reasoning := 0
+	if details, ok := u["completion_tokens_details"].(map[string]any); ok {
+		reasoning, _ = toInt(details["reasoning_tokens"])
+	}
+
+	return map[string]any{
+		"input_tokens":          prompt,
+		"input_tokens_details":  map[string]any{"cached_tokens": cached},
+		"output_tokens":         completion,
+		"output_tokens_details": map[string]any{"reasoning_tokens": reasoning},
+		"total_tokens":          prompt + completion,
+	}
+}
+
+func nonNilSlice(items []any) []any {
+	if items == nil {
+		return []any{}
+	}
+	return items
+}
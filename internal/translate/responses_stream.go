@@ -0,0 +1,303 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// ResponsesCompleteFunc 流式 Responses 完成时的回调：完整的 response 对象与 chat 格式的 assistant 消息
+type ResponsesCompleteFunc func(resp map[string]any, assistantMessage map[string]any)
+
+// responsesToolCall 流式过程中累积的函数调用
+type responsesToolCall struct {
+	itemID      string
+	callID      string
+	name        string
+	arguments   strings.Builder
+	outputIndex int
+}
+
+// ChatToResponsesStream 将 chat.completion.chunk 流转换为 Responses API 的类型化事件流
+type ChatToResponsesStream struct {
+	responseID string
+	model      string
+	req        map[string]any
+	onComplete ResponsesCompleteFunc
+
+	sequence int
+	started  bool
+	finished bool
+
+	// 文本输出项
+	messageID          string
+	messageOutputIndex int
+	messageOpen        bool
+	text               strings.Builder
+
+	// chat tool_calls 的 index -> 函数调用输出项
+	toolCalls   map[int]*responsesToolCall
+	toolOrder   []int
+	outputCount int
+
+	finishReason string
+	usage        any
+}
+
+// NewChatToResponsesStream 创建 chat -> Responses 流式转换器
+func NewChatToResponsesStream(responseID string, req map[string]any, onComplete ResponsesCompleteFunc) *ChatToResponsesStream {
+	model, _ := req["model"].(string)
+	return &ChatToResponsesStream{
+		responseID: responseID,
+		model:      model,
+		req:        req,
+		onComplete: onComplete,
+		toolCalls:  make(map[int]*responsesToolCall),
+	}
+}
+
+// ConvertLine 处理一行 chat SSE 数据
+func (s *ChatToResponsesStream) ConvertLine(line []byte) []byte {
+	data, ok := parseSSEData(line)
+	if !ok || len(data) == 0 || s.finished {
+		return nil
+	}
+	if string(data) == "[DONE]" {
+		return s.finish(true)
+	}
+
+	var chunk map[string]any
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil
+	}
+
+	var out bytes.Buffer
+
+	// 上游在流中返回错误
+	if errObj, ok := chunk["error"].(map[string]any); ok {
+		return s.Fail("server_error", errObj["message"])
+	}
+
+	if model, ok := chunk["model"].(string); ok && model != "" && !s.started {
+		s.model = model
+	}
+	out.Write(s.start())
+
+	if usage, ok := chunk["usage"].(map[string]any); ok {
+		s.usage = usage
+	}
+
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return out.Bytes()
+	}
+	choice, _ := choices[0].(map[string]any)
+	delta, _ := choice["delta"].(map[string]any)
+
+	if text, ok := delta["content"].(string); ok && text != "" {
+		if !s.messageOpen {
+			out.Write(s.openMessage())
+		}
+		s.text.WriteString(text)
+		out.Write(s.event("response.output_text.delta", map[string]any{
+			"item_id":       s.messageID,
+			"output_index":  s.messageOutputIndex,
+			"content_index": 0,
+			"delta":         text,
+		}))
+	}
+
+	if toolCalls, ok := delta["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			call, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			index, _ := toInt(call["index"])
+			fn, _ := call["function"].(map[string]any)
+
+			state, known := s.toolCalls[index]
+			if !known {
+				callID, _ := call["id"].(string)
+				if callID == "" {
+					callID = newID("call_")
+				}
+				name, _ := fn["name"].(string)
+				state = &responsesToolCall{
+					itemID:      newID("fc_"),
+					callID:      callID,
+					name:        name,
+					outputIndex: s.outputCount,
+				}
+				s.outputCount++
+				s.toolCalls[index] = state
+				s.toolOrder = append(s.toolOrder, index)
+
+				item := responsesFunctionCallItem(state.itemID, state.callID, state.name, "")
+				item["status"] = "in_progress"
+				out.Write(s.event("response.output_item.added", map[string]any{
+					"output_index": state.outputIndex,
+					"item":         item,
+				}))
+			}
+
+			if args, ok := fn["arguments"].(string); ok && args != "" {
+				state.arguments.WriteString(args)
+				out.Write(s.event("response.function_call_arguments.delta", map[string]any{
+					"item_id":      state.itemID,
+					"output_index": state.outputIndex,
+					"delta":        args,
+				}))
+			}
+		}
+	}
+
+	if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+		s.finishReason = reason
+	}
+
+	return out.Bytes()
+}
+
+// Finish 上游流结束时补发完成事件
+// 没有收到 [DONE] 和 finish_reason 说明上游流被截断，以 response.failed 结束，不保存响应
+func (s *ChatToResponsesStream) Finish() []byte {
+	if s.finished {
+		return nil
+	}
+	return s.finish(s.finishReason != "")
+}
+
+// Fail 以 response.failed 事件结束流，用于读取上游流失败等转换器之外的错误，不保存响应
+func (s *ChatToResponsesStream) Fail(code string, message any) []byte {
+	if s.finished {
+		return nil
+	}
+	var out bytes.Buffer
+	out.Write(s.start())
+	resp := newResponsesObject(s.responseID, s.model, s.req, "failed")
+	resp["error"] = map[string]any{"code": code, "message": message}
+	out.Write(s.event("response.failed", map[string]any{"response": resp}))
+	s.finished = true
+	return out.Bytes()
+}
+
+func (s *ChatToResponsesStream) event(eventType string, payload map[string]any) []byte {
+	payload["type"] = eventType
+	payload["sequence_number"] = s.sequence
+	s.sequence++
+	return formatSSEEvent(eventType, payload)
+}
+
+func (s *ChatToResponsesStream) start() []byte {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	var out bytes.Buffer
+	resp := newResponsesObject(s.responseID, s.model, s.req, "in_progress")
+	out.Write(s.event("response.created", map[string]any{"response": resp}))
+	out.Write(s.event("response.in_progress", map[string]any{"response": resp}))
+	return out.Bytes()
+}
+
+func (s *ChatToResponsesStream) openMessage() []byte {
+	s.messageOpen = true
+	s.messageID = newID("msg_")
+	s.messageOutputIndex = s.outputCount
+	s.outputCount++
+
+	var out bytes.Buffer
+	out.Write(s.event("response.output_item.added", map[string]any{
+		"output_index": s.messageOutputIndex,
+		"item": map[string]any{
+			"type":    "message",
+			"id":      s.messageID,
+			"status":  "in_progress",
+			"role":    "assistant",
+			"content": []any{},
+		},
+	}))
+	out.Write(s.event("response.content_part.added", map[string]any{
+		"item_id":       s.messageID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": 0,
+		"part":          map[string]any{"type": "output_text", "text": "", "annotations": []any{}},
+	}))
+	return out.Bytes()
+}
+
+// finish 发送各输出项的完成事件和最终的 response 事件，complete 为 false 表示上游流中断
+func (s *ChatToResponsesStream) finish(complete bool) []byte {
+	var out bytes.Buffer
+	out.Write(s.start())
+
+	output := make([]any, s.outputCount)
+
+	if s.messageOpen {
+		text := s.text.String()
+		part := map[string]any{"type": "output_text", "text": text, "annotations": []any{}}
+		out.Write(s.event("response.output_text.done", map[string]any{
+			"item_id":       s.messageID,
+			"output_index":  s.messageOutputIndex,
+			"content_index": 0,
+			"text":          text,
+		}))
+		out.Write(s.event("response.content_part.done", map[string]any{
+			"item_id":       s.messageID,
+			"output_index":  s.messageOutputIndex,
+			"content_index": 0,
+			"part":          part,
+		}))
+		item := responsesMessageItem(s.messageID, text)
+		out.Write(s.event("response.output_item.done", map[string]any{
+			"output_index": s.messageOutputIndex,
+			"item":         item,
+		}))
+		output[s.messageOutputIndex] = item
+	}
+
+	var chatToolCalls []any
+	for _, index := range s.toolOrder {
+		state := s.toolCalls[index]
+		arguments := state.arguments.String()
+		out.Write(s.event("response.function_call_arguments.done", map[string]any{
+			"item_id":      state.itemID,
+			"output_index": state.outputIndex,
+			"arguments":    arguments,
+		}))
+		item := responsesFunctionCallItem(state.itemID, state.callID, state.name, arguments)
+		out.Write(s.event("response.output_item.done", map[string]any{
+			"output_index": state.outputIndex,
+			"item":         item,
+		}))
+		output[state.outputIndex] = item
+		chatToolCalls = append(chatToolCalls, map[string]any{
+			"id":       state.callID,
+			"type":     "function",
+			"function": map[string]any{"name": state.name, "arguments": arguments},
+		})
+	}
+
+	resp := newResponsesObject(s.responseID, s.model, s.req, "completed")
+	resp["output"] = output
+	resp["usage"] = chatUsageToResponses(s.usage)
+	eventType := "response.completed"
+	switch {
+	case !complete:
+		resp["status"] = "failed"
+		resp["error"] = map[string]any{"code": "server_error", "message": truncatedStreamMessage}
+		eventType = "response.failed"
+	case s.finishReason == "length":
+		resp["status"] = "incomplete"
+		resp["incomplete_details"] = map[string]any{"reason": "max_output_tokens"}
+		eventType = "response.incomplete"
+	}
+	out.Write(s.event(eventType, map[string]any{"response": resp}))
+	s.finished = true
+
+	if complete && s.onComplete != nil {
+		s.onComplete(resp, chatAssistantMessage(s.text.String(), chatToolCalls))
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,93 @@
+package translate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChatToResponsesStreamFinish(t *testing.T) {
+	tests := []struct {
+		name      string
+		finish    any  // 最后一个 chunk 的 finish_reason
+		done      bool // 是否收到 [DONE]
+		wantEvent string
+		wantSaved bool
+	}{
+		{name: "正常结束", finish: "stop", done: true, wantEvent: "response.completed", wantSaved: true},
+		{name: "只有 finish_reason", finish: "stop", wantEvent: "response.completed", wantSaved: true},
+		{name: "达到长度上限", finish: "length", done: true, wantEvent: "response.incomplete", wantSaved: true},
+		{name: "流被截断", finish: nil, wantEvent: "response.failed", wantSaved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := false
+			s := NewChatToResponsesStream("resp_test", map[string]any{"model": "gpt-test"}, func(resp map[string]any, message map[string]any) {
+				saved = true
+				if message["content"] != "你好" {
+					t.Errorf("保存的消息内容 = %v", message["content"])
+				}
+			})
+
+			var raw bytes.Buffer
+			raw.Write(s.ConvertLine(chatChunk(t, map[string]any{"content": "你好"}, nil)))
+			if tt.finish != nil {
+				raw.Write(s.ConvertLine(chatChunk(t, map[string]any{}, tt.finish)))
+			}
+			if tt.done {
+				raw.Write(s.ConvertLine([]byte("data: [DONE]")))
+			}
+			raw.Write(s.Finish())
+
+			events := parseSSE(t, raw.Bytes())
+			last := events[len(events)-1]
+			if last.name != tt.wantEvent {
+				t.Errorf("最终事件 = %s，期望 %s", last.name, tt.wantEvent)
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("是否保存 = %v，期望 %v", saved, tt.wantSaved)
+			}
+			for i, event := range events {
+				if seq, _ := toInt(event.data["sequence_number"]); seq != i {
+					t.Fatalf("事件 %d 的 sequence_number = %d", i, seq)
+				}
+			}
+		})
+	}
+}
+
+func TestChatToResponsesStreamFail(t *testing.T) {
+	saved := false
+	s := NewChatToResponsesStream("resp_test", map[string]any{"model": "gpt-test"}, func(map[string]any, map[string]any) {
+		saved = true
+	})
+
+	var raw bytes.Buffer
+	raw.Write(s.ConvertLine(chatChunk(t, map[string]any{"content": "你好"}, nil)))
+	raw.Write(s.Fail("server_error", "读取流式响应失败"))
+	if extra := s.Finish(); extra != nil {
+		t.Errorf("失败后 Finish 不应再发送事件: %s", extra)
+	}
+	if extra := s.Fail("server_error", "again"); extra != nil {
+		t.Errorf("重复 Fail 不应再发送事件: %s", extra)
+	}
+
+	events := parseSSE(t, raw.Bytes())
+	last := events[len(events)-1]
+	if last.name != "response.failed" {
+		t.Fatalf("最终事件 = %s，期望 response.failed", last.name)
+	}
+	resp, _ := last.data["response"].(map[string]any)
+	errObj, _ := resp["error"].(map[string]any)
+	if resp["status"] != "failed" || errObj["code"] != "server_error" || errObj["message"] != "读取流式响应失败" {
+		t.Errorf("response = %v", resp)
+	}
+	if saved {
+		t.Error("失败的响应不应保存")
+	}
+	for i, event := range events {
+		if seq, _ := toInt(event.data["sequence_number"]); seq != i {
+			t.Fatalf("事件 %d 的 sequence_number = %d", i, seq)
+		}
+	}
+}
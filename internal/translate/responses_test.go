@@ -0,0 +1,125 @@
+package translate
+
+import "testing"
+
+func TestResponsesToChatRequest(t *testing.T) {
+	tests := []struct {
+		name             string
+		req              string
+		history          []any
+		want             string
+		wantConversation string
+	}{
+		{
+			name:             "字符串输入和 instructions",
+			req:              `{"model":"glm-4.6","instructions":"be brief","input":"hi","max_output_tokens":100,"stream":true,"user":"u1"}`,
+			want:             `{"model":"glm-4.6","max_tokens":100,"stream":true,"stream_options":{"include_usage":true},"user":"u1","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`,
+			wantConversation: `[{"role":"user","content":"hi"}]`,
+		},
+		{
+			name:             "延续历史消息（instructions 不保存）",
+			req:              `{"instructions":"be brief","input":"again"}`,
+			history:          []any{map[string]any{"role": "user", "content": "hi"}, map[string]any{"role": "assistant", "content": "hello"}},
+			want:             `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"again"}]}`,
+			wantConversation: `[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"again"}]`,
+		},
+		{
+			name: "消息、图片和工具调用",
+			req: `{"input":[
+				{"role":"developer","content":"use chinese"},
+				{"type":"message","role":"user","content":[{"type":"input_text","text":"look"},{"type":"input_image","image_url":"https://example.com/cat.png","detail":"low"}]},
+				{"type":"reasoning","summary":[]},
+				{"type":"function_call","call_id":"call_1","name":"weather","arguments":"{}"},
+				{"type":"function_call","call_id":"call_2","name":"ls","arguments":"{}"},
+				{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+				{"type":"message","role":"assistant","content":[{"type":"output_text","text":"done"}]}],
+				"tools":[{"type":"function","name":"weather","description":"get weather","parameters":{"type":"object"},"strict":true},{"type":"web_search"}],
+				"tool_choice":{"type":"function","name":"weather"},
+				"text":{"format":{"type":"json_schema","name":"answer","schema":{"type":"object"},"strict":true}}}`,
+			want: `{"messages":[
+				{"role":"system","content":"use chinese"},
+				{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"low"}}]},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}},
+					{"id":"call_2","type":"function","function":{"name":"ls","arguments":"{}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"},
+				{"role":"assistant","content":"done"}],
+				"tools":[{"type":"function","function":{"name":"weather","description":"get weather","parameters":{"type":"object"},"strict":true}}],
+				"tool_choice":{"type":"function","function":{"name":"weather"}},
+				"response_format":{"type":"json_schema","json_schema":{"name":"answer","schema":{"type":"object"},"strict":true}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, conversation, err := ResponsesToChatRequest(decodeJSON(t, tt.req), tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkJSON(t, out, tt.want)
+			if tt.wantConversation != "" {
+				checkJSON(t, conversation, tt.wantConversation)
+			}
+		})
+	}
+
+	for _, input := range []any{42, []any{map[string]any{"type": "computer_call"}}} {
+		if _, _, err := ResponsesToChatRequest(map[string]any{"input": input}, nil); err == nil {
+			t.Errorf("input %v 期望返回错误", input)
+		}
+	}
+}
+
+func TestChatToResponsesResponse(t *testing.T) {
+	req := decodeJSON(t, `{"model":"glm-4.6","instructions":"be brief","max_output_tokens":50}`)
+	tests := []struct {
+		name        string
+		chatResp    string
+		wantStatus  string
+		wantOutput  []string // 输出项类型
+		wantMessage string
+	}{
+		{
+			name:        "文本",
+			chatResp:    `{"choices":[{"finish_reason":"stop","message":{"content":"hello"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`,
+			wantStatus:  "completed",
+			wantOutput:  []string{"message"},
+			wantMessage: `{"role":"assistant","content":"hello"}`,
+		},
+		{
+			name:        "工具调用",
+			chatResp:    `{"choices":[{"finish_reason":"tool_calls","message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]}}]}`,
+			wantStatus:  "completed",
+			wantOutput:  []string{"function_call"},
+			wantMessage: `{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]}`,
+		},
+		{
+			name:        "达到长度上限",
+			chatResp:    `{"choices":[{"finish_reason":"length","message":{"content":"hel"}}]}`,
+			wantStatus:  "incomplete",
+			wantOutput:  []string{"message"},
+			wantMessage: `{"role":"assistant","content":"hel"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, message := ChatToResponsesResponse(decodeJSON(t, tt.chatResp), "resp_1", req)
+			if resp["id"] != "resp_1" || resp["model"] != "glm-4.6" || resp["instructions"] != "be brief" || resp["status"] != tt.wantStatus {
+				t.Errorf("response = %v", resp)
+			}
+			output := resp["output"].([]any)
+			if len(output) != len(tt.wantOutput) {
+				t.Fatalf("输出 %d 项，期望 %d 项", len(output), len(tt.wantOutput))
+			}
+			for i, item := range output {
+				if got := item.(map[string]any)["type"]; got != tt.wantOutput[i] {
+					t.Errorf("第 %d 项类型 = %v，期望 %s", i, got, tt.wantOutput[i])
+				}
+			}
+			checkJSON(t, message, tt.wantMessage)
+		})
+	}
+
+	resp, _ := ChatToResponsesResponse(decodeJSON(t, `{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,
+		"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":2}}}`), "resp_1", req)
+	checkJSON(t, resp["usage"], `{"input_tokens":10,"input_tokens_details":{"cached_tokens":4},"output_tokens":5,"output_tokens_details":{"reasoning_tokens":2},"total_tokens":15}`)
+}
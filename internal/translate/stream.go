@@ -0,0 +1,501 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseSSEData 从一行 SSE 数据中提取 data 字段内容，非 data 行返回 false
+func parseSSEData(line []byte) ([]byte, bool) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil, false
+	}
+	return bytes.TrimSpace(line[len("data:"):]), true
+}
+
+// formatSSEEvent 生成带事件名的 SSE 事件（Anthropic 格式）
+func formatSSEEvent(event string, data any) []byte {
+	payload, _ := json.Marshal(data)
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload))
+}
+
+// formatSSEData 生成仅包含 data 的 SSE 事件（OpenAI 格式）
+func formatSSEData(data any) []byte {
+	payload, _ := json.Marshal(data)
+	return []byte(fmt.Sprintf("data: %s\n\n", payload))
+}
+
+// truncatedStreamMessage 上游流在正常结束前中断时返回给客户端的错误信息
+const truncatedStreamMessage = "upstream stream ended before completion"
+
+// OpenAIToAnthropicStream 将 OpenAI chat.completion.chunk 流转换为 Anthropic Messages 事件流
+type OpenAIToAnthropicStream struct {
+	model string
+	id    string
+
+	started  bool
+	finished bool
+
+	// 当前打开的内容块：thinking / text / tool_use
+	openBlock string
+	nextIndex int
+	// OpenAI tool_calls 的 index -> 工具调用状态
+	toolCalls map[int]*anthropicToolCall
+	// 当前打开的 tool_use 块对应的 tool_calls index，-1 表示没有
+	openTool int
+	// 已开始但还没有打开内容块的工具调用（上一个工具调用的参数还不完整）
+	toolQueue    []int
+	finishReason string
+	usage        map[string]any
+}
+
+// anthropicToolCall 流式过程中的工具调用
+// Anthropic 的内容块依次打开和关闭，上游交替发送多个工具调用的参数时，
+// 后开始的工具调用先缓冲，等前一个的参数成为完整的 JSON 后再打开
+type anthropicToolCall struct {
+	id         string
+	name       any
+	blockIndex int
+	opened     bool
+	arguments  strings.Builder // 全部参数
+	pending    strings.Builder // 内容块打开前缓冲的参数
+}
+
+// NewOpenAIToAnthropicStream 创建 OpenAI -> Anthropic 流式转换器
+func NewOpenAIToAnthropicStream(model string) *OpenAIToAnthropicStream {
+	return &OpenAIToAnthropicStream{
+		model:     model,
+		id:        newID("msg_"),
+		toolCalls: make(map[int]*anthropicToolCall),
+		openTool:  -1,
+	}
+}
+
+// ConvertLine 处理一行 OpenAI SSE 数据
+func (s *OpenAIToAnthropicStream) ConvertLine(line []byte) []byte {
+	data, ok := parseSSEData(line)
+	if !ok || len(data) == 0 || s.finished {
+		return nil
+	}
+	if string(data) == "[DONE]" {
+		return s.finish()
+	}
+
+	var chunk map[string]any
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil
+	}
+
+	var out bytes.Buffer
+
+	// 上游在流中返回错误
+	if errObj, ok := chunk["error"].(map[string]any); ok {
+		return s.fail(errObj["message"])
+	}
+
+	if model, ok := chunk["model"].(string); ok && model != "" && !s.started {
+		s.model = model
+	}
+	out.Write(s.start())
+
+	if usage, ok := chunk["usage"].(map[string]any); ok {
+		s.usage = usage
+	}
+
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return out.Bytes()
+	}
+	choice, _ := choices[0].(map[string]any)
+	delta, _ := choice["delta"].(map[string]any)
+
+	if reasoning, ok := delta["reasoning_content"].(string); ok && reasoning != "" {
+		out.Write(s.ensureBlock("thinking", map[string]any{"type": "thinking", "thinking": ""}))
+		out.Write(s.blockDelta(map[string]any{"type": "thinking_delta", "thinking": reasoning}))
+	}
+
+	if text, ok := delta["content"].(string); ok && text != "" {
+		out.Write(s.ensureBlock("text", map[string]any{"type": "text", "text": ""}))
+		out.Write(s.blockDelta(map[string]any{"type": "text_delta", "text": text}))
+	}
+
+	if toolCalls, ok := delta["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			call, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			toolIndex, _ := toInt(call["index"])
+			fn, _ := call["function"].(map[string]any)
+
+			state, known := s.toolCalls[toolIndex]
+			if !known {
+				id, _ := call["id"].(string)
+				if id == "" {
+					id = newID("toolu_")
+				}
+				state = &anthropicToolCall{id: id, name: fn["name"]}
+				s.toolCalls[toolIndex] = state
+				s.toolQueue = append(s.toolQueue, toolIndex)
+			}
+
+			if args, ok := fn["arguments"].(string); ok && args != "" {
+				state.arguments.WriteString(args)
+				switch {
+				case s.openTool == toolIndex:
+					out.Write(s.toolDelta(state.blockIndex, args))
+				case !state.opened:
+					state.pending.WriteString(args)
+				}
+				// 内容块已关闭后到达的参数无法再发送（前面的参数已是完整的 JSON，不会出现在正常的流中）
+			}
+			out.Write(s.openQueuedTools(false))
+		}
+	}
+
+	if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+		s.finishReason = reason
+	}
+
+	return out.Bytes()
+}
+
+// Finish 上游流结束时补发结束事件
+// 没有收到 [DONE] 和 finish_reason 说明上游流被截断，以 error 事件结束，避免客户端当作完整的回复
+func (s *OpenAIToAnthropicStream) Finish() []byte {
+	if s.finished {
+		return nil
+	}
+	if s.finishReason == "" {
+		return s.fail(truncatedStreamMessage)
+	}
+	return s.finish()
+}
+
+// fail 以 error 事件结束流
+func (s *OpenAIToAnthropicStream) fail(message any) []byte {
+	var out bytes.Buffer
+	out.Write(s.start())
+	out.Write(formatSSEEvent("error", map[string]any{
+		"type": "error",
+		"error": map[string]any{
+			"type":    "api_error",
+			"message": message,
+		},
+	}))
+	s.finished = true
+	return out.Bytes()
+}
+
+func (s *OpenAIToAnthropicStream) start() []byte {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return formatSSEEvent("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            s.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         s.model,
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]any{"input_tokens": 0, "output_tokens": 0},
+		},
+	})
+}
+
+// ensureBlock 确保当前打开的是指定类型的块，否则关闭当前块并打开新块
+func (s *OpenAIToAnthropicStream) ensureBlock(kind string, contentBlock map[string]any) []byte {
+	if s.openBlock == kind {
+		return nil
+	}
+	var out bytes.Buffer
+	out.Write(s.openQueuedTools(true))
+	out.Write(s.closeBlock())
+	out.Write(s.openBlockAt(kind, contentBlock))
+	return out.Bytes()
+}
+
+// openQueuedTools 依次为等待中的工具调用打开 tool_use 块并发送缓冲的参数
+// 当前打开的工具调用参数还不完整时等待，force 为 true 时（其他内容或流结束）不再等待
+func (s *OpenAIToAnthropicStream) openQueuedTools(force bool) []byte {
+	var out bytes.Buffer
+	for len(s.toolQueue) > 0 {
+		if !force && s.openTool >= 0 && !json.Valid([]byte(s.toolCalls[s.openTool].arguments.String())) {
+			break
+		}
+		toolIndex := s.toolQueue[0]
+		s.toolQueue = s.toolQueue[1:]
+		state := s.toolCalls[toolIndex]
+
+		out.Write(s.closeBlock())
+		state.blockIndex = s.nextIndex
+		state.opened = true
+		out.Write(s.openBlockAt("tool_use", map[string]any{
+			"type":  "tool_use",
+			"id":    state.id,
+			"name":  state.name,
+			"input": map[string]any{},
+		}))
+		s.openTool = toolIndex
+		if state.pending.Len() > 0 {
+			out.Write(s.toolDelta(state.blockIndex, state.pending.String()))
+			state.pending.Reset()
+		}
+	}
+	return out.Bytes()
+}
+
+// toolDelta 发送工具调用参数片段
+func (s *OpenAIToAnthropicStream) toolDelta(blockIndex int, args string) []byte {
+	return formatSSEEvent("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": blockIndex,
+		"delta": map[string]any{"type": "input_json_delta", "partial_json": args},
+	})
+}
+
+func (s *OpenAIToAnthropicStream) openBlockAt(kind string, contentBlock map[string]any) []byte {
+	s.openBlock = kind
+	s.nextIndex++
+	return formatSSEEvent("content_block_start", map[string]any{
+		"type":          "content_block_start",
+		"index":         s.nextIndex - 1,
+		"content_block": contentBlock,
+	})
+}
+
+func (s *OpenAIToAnthropicStream) blockDelta(delta map[string]any) []byte {
+	return formatSSEEvent("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.nextIndex - 1,
+		"delta": delta,
+	})
+}
+
+func (s *OpenAIToAnthropicStream) closeBlock() []byte {
+	if s.openBlock == "" {
+		return nil
+	}
+	s.openTool = -1
+	s.openBlock = ""
+	return formatSSEEvent("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": s.nextIndex - 1,
+	})
+}
+
+func (s *OpenAIToAnthropicStream) finish() []byte {
+	var out bytes.Buffer
+	out.Write(s.start())
+	out.Write(s.openQueuedTools(true))
+	out.Write(s.closeBlock())
+
+	usage := OpenAIUsageToAnthropic(s.usage)
+	out.Write(formatSSEEvent("message_delta", map[string]any{
+		"type": "message_delta",
+		"delta": map[string]any{
+			"stop_reason":   OpenAIFinishReasonToAnthropic(s.finishReason),
+			"stop_sequence": nil,
+		},
+		"usage": usage,
+	}))
+	out.Write(formatSSEEvent("message_stop", map[string]any{"type": "message_stop"}))
+	s.finished = true
+	return out.Bytes()
+}
+
+// AnthropicToOpenAIStream 将 Anthropic Messages 事件流转换为 OpenAI chat.completion.chunk 流
+type AnthropicToOpenAIStream struct {
+	model        string
+	id           string
+	created      int64
+	includeUsage bool
+
+	done bool
+	// 是否收到 stop_reason
+	stopped bool
+	// Anthropic 内容块 index -> OpenAI tool_calls index
+	toolIndexes  map[int]int
+	inputTokens  int
+	outputTokens int
+	cacheRead    int
+}
+
+// NewAnthropicToOpenAIStream 创建 Anthropic -> OpenAI 流式转换器
+// includeUsage: 是否在结束前发送 usage chunk（对应 stream_options.include_usage）
+func NewAnthropicToOpenAIStream(model string, includeUsage bool) *AnthropicToOpenAIStream {
+	return &AnthropicToOpenAIStream{
+		model:        model,
+		id:           newID("chatcmpl-"),
+		created:      time.Now().Unix(),
+		includeUsage: includeUsage,
+		toolIndexes:  make(map[int]int),
+	}
+}
+
+// ConvertLine 处理一行 Anthropic SSE 数据（event 行忽略，事件类型从 data 中读取）
+func (s *AnthropicToOpenAIStream) ConvertLine(line []byte) []byte {
+	data, ok := parseSSEData(line)
+	if !ok || len(data) == 0 || s.done {
+		return nil
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil
+	}
+
+	switch event["type"] {
+	case "message_start":
+		message, _ := event["message"].(map[string]any)
+		if id, ok := message["id"].(string); ok && id != "" {
+			s.id = id
+		}
+		if model, ok := message["model"].(string); ok && model != "" {
+			s.model = model
+		}
+		s.addUsage(message["usage"])
+		return s.chunk(map[string]any{"role": "assistant", "content": ""}, nil)
+
+	case "content_block_start":
+		block, _ := event["content_block"].(map[string]any)
+		if block["type"] != "tool_use" {
+			return nil
+		}
+		blockIndex, _ := toInt(event["index"])
+		toolIndex := len(s.toolIndexes)
+		s.toolIndexes[blockIndex] = toolIndex
+		return s.chunk(map[string]any{
+			"tool_calls": []any{map[string]any{
+				"index": toolIndex,
+				"id":    block["id"],
+				"type":  "function",
+				"function": map[string]any{
+					"name":      block["name"],
+					"arguments": "",
+				},
+			}},
+		}, nil)
+
+	case "content_block_delta":
+		delta, _ := event["delta"].(map[string]any)
+		switch delta["type"] {
+		case "text_delta":
+			return s.chunk(map[string]any{"content": delta["text"]}, nil)
+		case "thinking_delta":
+			return s.chunk(map[string]any{"reasoning_content": delta["thinking"]}, nil)
+		case "input_json_delta":
+			blockIndex, _ := toInt(event["index"])
+			toolIndex, ok := s.toolIndexes[blockIndex]
+			if !ok {
+				return nil
+			}
+			return s.chunk(map[string]any{
+				"tool_calls": []any{map[string]any{
+					"index":    toolIndex,
+					"function": map[string]any{"arguments": delta["partial_json"]},
+				}},
+			}, nil)
+		}
+
+	case "message_delta":
+		s.addUsage(event["usage"])
+		delta, _ := event["delta"].(map[string]any)
+		if stopReason, ok := delta["stop_reason"].(string); ok && stopReason != "" {
+			s.stopped = true
+			reason := AnthropicStopReasonToOpenAI(stopReason)
+			return s.chunk(map[string]any{}, reason)
+		}
+
+	case "message_stop":
+		return s.finish()
+
+	case "error":
+		errObj, _ := event["error"].(map[string]any)
+		var out bytes.Buffer
+		out.Write(formatSSEData(map[string]any{"error": errObj}))
+		out.Write(s.finish())
+		return out.Bytes()
+	}
+
+	return nil
+}
+
+// Finish 上游流结束时补发 [DONE]
+// 没有收到 message_stop 和 stop_reason 说明上游流被截断，先发送错误 chunk，避免客户端当作完整的回复
+func (s *AnthropicToOpenAIStream) Finish() []byte {
+	if s.done {
+		return nil
+	}
+	if !s.stopped {
+		var out bytes.Buffer
+		out.Write(formatSSEData(map[string]any{"error": map[string]any{
+			"message": truncatedStreamMessage,
+			"type":    "server_error",
+			"param":   nil,
+			"code":    nil,
+		}}))
+		out.Write(s.finish())
+		return out.Bytes()
+	}
+	return s.finish()
+}
+
+func (s *AnthropicToOpenAIStream) addUsage(usage any) {
+	u, ok := usage.(map[string]any)
+	if !ok {
+		return
+	}
+	if v, ok := toInt(u["input_tokens"]); ok && v > 0 {
+		s.inputTokens = v
+	}
+	if v, ok := toInt(u["output_tokens"]); ok && v > 0 {
+		s.outputTokens = v
+	}
+	if v, ok := toInt(u["cache_read_input_tokens"]); ok && v > 0 {
+		s.cacheRead = v
+	}
+}
+
+func (s *AnthropicToOpenAIStream) chunk(delta map[string]any, finishReason any) []byte {
+	return formatSSEData(map[string]any{
+		"id":      s.id,
+		"object":  "chat.completion.chunk",
+		"created": s.created,
+		"model":   s.model,
+		"choices": []any{map[string]any{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+}
+
+func (s *AnthropicToOpenAIStream) finish() []byte {
+	var out bytes.Buffer
+	if s.includeUsage {
+		out.Write(formatSSEData(map[string]any{
+			"id":      s.id,
+			"object":  "chat.completion.chunk",
+			"created": s.created,
+			"model":   s.model,
+			"choices": []any{},
+			"usage": AnthropicUsageToOpenAI(map[string]any{
+				"input_tokens":            float64(s.inputTokens),
+				"output_tokens":           float64(s.outputTokens),
+				"cache_read_input_tokens": float64(s.cacheRead),
+			}),
+		}))
+	}
+	out.WriteString("data: [DONE]\n\n")
+	s.done = true
+	return out.Bytes()
+}
@@ -0,0 +1,261 @@
+package translate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// sseEvent 测试中解析出的 SSE 事件
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// parseSSE 解析转换器输出的 SSE 事件
+func parseSSE(t *testing.T, raw []byte) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var name string
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			payload := strings.TrimPrefix(line, "data: ")
+			event := sseEvent{name: name}
+			if payload != "[DONE]" {
+				if err := json.Unmarshal([]byte(payload), &event.data); err != nil {
+					t.Fatalf("无效的事件数据 %q: %v", payload, err)
+				}
+			} else {
+				event.name = "[DONE]"
+			}
+			events = append(events, event)
+			name = ""
+		}
+	}
+	return events
+}
+
+// chatChunk 生成一行 chat.completion.chunk SSE 数据
+func chatChunk(t *testing.T, delta map[string]any, finishReason any) []byte {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"object":  "chat.completion.chunk",
+		"model":   "gpt-test",
+		"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finishReason}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte("data: "), payload...)
+}
+
+// toolCallDelta 生成 tool_calls 增量
+func toolCallDelta(index int, id, name, args string) map[string]any {
+	call := map[string]any{"index": index, "function": map[string]any{"arguments": args}}
+	if id != "" {
+		call["id"] = id
+		call["type"] = "function"
+		call["function"].(map[string]any)["name"] = name
+	}
+	return map[string]any{"tool_calls": []any{call}}
+}
+
+// checkBlockOrder 检查内容块依次打开和关闭，关闭后不再收到增量，返回每个块收到的参数
+func checkBlockOrder(t *testing.T, events []sseEvent) map[int]string {
+	t.Helper()
+	open := -1
+	closed := make(map[int]bool)
+	args := make(map[int]string)
+	for _, event := range events {
+		index, _ := toInt(event.data["index"])
+		switch event.name {
+		case "content_block_start":
+			if open >= 0 {
+				t.Fatalf("块 %d 打开时块 %d 尚未关闭", index, open)
+			}
+			open = index
+		case "content_block_delta":
+			if index != open || closed[index] {
+				t.Fatalf("块 %d 不是当前打开的块（当前 %d）却收到增量", index, open)
+			}
+			delta, _ := event.data["delta"].(map[string]any)
+			if partial, ok := delta["partial_json"].(string); ok {
+				args[index] += partial
+			}
+		case "content_block_stop":
+			if index != open {
+				t.Fatalf("关闭的块 %d 不是当前打开的块 %d", index, open)
+			}
+			closed[index] = true
+			open = -1
+		}
+	}
+	if open >= 0 {
+		t.Fatalf("块 %d 在流结束时没有关闭", open)
+	}
+	return args
+}
+
+func TestOpenAIToAnthropicStreamToolCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []map[string]any
+		want   map[int]string // 内容块 index -> 完整参数
+	}{
+		{
+			name: "依次发送",
+			deltas: []map[string]any{
+				toolCallDelta(0, "call_a", "read", ""),
+				toolCallDelta(0, "", "", `{"path":`),
+				toolCallDelta(0, "", "", `"a.go"}`),
+				toolCallDelta(1, "call_b", "read", `{"path":"b.go"}`),
+			},
+			want: map[int]string{0: `{"path":"a.go"}`, 1: `{"path":"b.go"}`},
+		},
+		{
+			name: "交替发送",
+			deltas: []map[string]any{
+				toolCallDelta(0, "call_a", "read", `{"path":`),
+				toolCallDelta(1, "call_b", "grep", `{"q":`),
+				toolCallDelta(0, "", "", `"a.go"}`),
+				toolCallDelta(1, "", "", `"x"}`),
+			},
+			want: map[int]string{0: `{"path":"a.go"}`, 1: `{"q":"x"}`},
+		},
+		{
+			name: "文本之后的工具调用",
+			deltas: []map[string]any{
+				{"content": "先读文件"},
+				toolCallDelta(0, "call_a", "read", `{"path":"a.go"}`),
+			},
+			want: map[int]string{1: `{"path":"a.go"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOpenAIToAnthropicStream("claude-test")
+			var raw bytes.Buffer
+			for _, delta := range tt.deltas {
+				raw.Write(s.ConvertLine(chatChunk(t, delta, nil)))
+			}
+			raw.Write(s.ConvertLine(chatChunk(t, map[string]any{}, "tool_calls")))
+			raw.Write(s.ConvertLine([]byte("data: [DONE]")))
+
+			events := parseSSE(t, raw.Bytes())
+			args := checkBlockOrder(t, events)
+			for index, want := range tt.want {
+				if args[index] != want {
+					t.Errorf("块 %d 的参数 = %q，期望 %q", index, args[index], want)
+				}
+			}
+
+			last := events[len(events)-2]
+			delta, _ := last.data["delta"].(map[string]any)
+			if last.name != "message_delta" || delta["stop_reason"] != "tool_use" {
+				t.Errorf("结束事件 = %s %v，期望 stop_reason tool_use", last.name, delta)
+			}
+		})
+	}
+}
+
+func TestOpenAIToAnthropicStreamFinish(t *testing.T) {
+	tests := []struct {
+		name      string
+		finish    any  // 最后一个 chunk 的 finish_reason
+		done      bool // 是否收到 [DONE]
+		wantEvent string
+	}{
+		{name: "正常结束", finish: "stop", done: true, wantEvent: "message_stop"},
+		{name: "只有 finish_reason", finish: "stop", wantEvent: "message_stop"},
+		{name: "只有 [DONE]", done: true, wantEvent: "message_stop"},
+		{name: "流被截断", wantEvent: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOpenAIToAnthropicStream("claude-test")
+			var raw bytes.Buffer
+			raw.Write(s.ConvertLine(chatChunk(t, map[string]any{"content": "你好"}, nil)))
+			if tt.finish != nil {
+				raw.Write(s.ConvertLine(chatChunk(t, map[string]any{}, tt.finish)))
+			}
+			if tt.done {
+				raw.Write(s.ConvertLine([]byte("data: [DONE]")))
+			}
+			raw.Write(s.Finish())
+
+			events := parseSSE(t, raw.Bytes())
+			last := events[len(events)-1]
+			if last.name != tt.wantEvent {
+				t.Errorf("最终事件 = %s，期望 %s", last.name, tt.wantEvent)
+			}
+			for _, event := range events {
+				if tt.wantEvent == "error" && event.name == "message_delta" {
+					t.Errorf("流被截断时不应发送 message_delta")
+				}
+			}
+		})
+	}
+}
+
+// anthropicEvent 生成一行 Anthropic SSE 数据
+func anthropicEvent(t *testing.T, event map[string]any) []byte {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte("data: "), payload...)
+}
+
+func TestAnthropicToOpenAIStreamFinish(t *testing.T) {
+	tests := []struct {
+		name      string
+		stop      bool // 是否收到带 stop_reason 的 message_delta
+		done      bool // 是否收到 message_stop
+		wantError bool
+	}{
+		{name: "正常结束", stop: true, done: true},
+		{name: "只有 stop_reason", stop: true},
+		{name: "流被截断", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAnthropicToOpenAIStream("gpt-test", false)
+			var raw bytes.Buffer
+			raw.Write(s.ConvertLine(anthropicEvent(t, map[string]any{"type": "message_start", "message": map[string]any{"id": "msg_1"}})))
+			raw.Write(s.ConvertLine(anthropicEvent(t, map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}})))
+			raw.Write(s.ConvertLine(anthropicEvent(t, map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": "你好"}})))
+			if tt.stop {
+				raw.Write(s.ConvertLine(anthropicEvent(t, map[string]any{"type": "message_delta", "delta": map[string]any{"stop_reason": "end_turn"}})))
+			}
+			if tt.done {
+				raw.Write(s.ConvertLine(anthropicEvent(t, map[string]any{"type": "message_stop"})))
+			}
+			raw.Write(s.Finish())
+
+			events := parseSSE(t, raw.Bytes())
+			if last := events[len(events)-1]; last.name != "[DONE]" {
+				t.Errorf("最终事件 = %s，期望 [DONE]", last.name)
+			}
+			gotError := false
+			for _, event := range events {
+				if _, ok := event.data["error"]; ok {
+					gotError = true
+				}
+			}
+			if gotError != tt.wantError {
+				t.Errorf("是否发送错误 chunk = %v，期望 %v", gotError, tt.wantError)
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package vision
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
+
+	"glm-tool/internal/imageutil"
 )
 
 // ImageAnalysisRequest 图片分析请求
@@ -21,16 +25,20 @@ type ImageAnalysisResponse struct {
 	Success bool   `json:"success"`
 	Data    string `json:"data,omitempty"`
 	Error   string `json:"error,omitempty"`
+	Usage   Usage  `json:"usage"` // 识别消耗的 token
 }
 
 // VisionConfig 视觉模型配置
 type VisionConfig struct {
-	BaseURL     string
-	Model       string
-	Temperature float64
-	TopP        float64
-	MaxTokens   int
-	Timeout     time.Duration
+	BaseURL       string
+	Model         string
+	Temperature   float64
+	TopP          float64
+	MaxTokens     int
+	Thinking      bool // 是否开启深度思考
+	Timeout       time.Duration
+	APIKey        string // 视觉模型专用 API Key，非空时替代请求中的 Key
+	ClassifyModel string // 自动选择提示词模板时分类使用的模型，为空时使用 Model
 }
 
 // DefaultVisionConfig 默认配置（完全按照 MCP 配置）
@@ -40,6 +48,7 @@ var DefaultVisionConfig = VisionConfig{
 	Temperature: 0.8,
 	TopP:        0.6,
 	MaxTokens:   16384,
+	Thinking:    true,
 	Timeout:     300 * time.Second,
 }
 
@@ -95,13 +104,13 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-// AnalyzeImage 分析图片
-func AnalyzeImage(request ImageAnalysisRequest) (*ImageAnalysisResponse, error) {
-	return AnalyzeImageWithConfig(request, DefaultVisionConfig)
+// AnalyzeImage 分析图片，ctx 取消时中止识别请求
+func AnalyzeImage(ctx context.Context, request ImageAnalysisRequest) (*ImageAnalysisResponse, error) {
+	return AnalyzeImageWithConfig(ctx, request, DefaultVisionConfig)
 }
 
 // AnalyzeImageWithConfig 使用自定义配置分析图片（完全按照 MCP 实现）
-func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (*ImageAnalysisResponse, error) {
+func AnalyzeImageWithConfig(ctx context.Context, request ImageAnalysisRequest, config VisionConfig) (*ImageAnalysisResponse, error) {
 	// 验证输入
 	if request.ImageBase64 == "" {
 		return &ImageAnalysisResponse{
@@ -112,17 +121,12 @@ func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (
 
 	// 如果没有提供 Prompt，使用默认的全面描述提示词
 	if request.Prompt == "" {
-		request.Prompt = "请详细全面地描述这张图片的内容，包括但不限于：\n" +
-			"1. 整体场景和环境（室内/室外、时间、天气等）\n" +
-			"2. 主要物体和人物（位置、大小、特征、动作、表情等）\n" +
-			"3. 颜色搭配和光影效果\n" +
-			"4. 构图和布局（前景、中景、背景）\n" +
-			"5. 文字内容（如果图片中包含文字，请完整识别并提取）\n" +
-			"6. 整体氛围、情绪和风格\n" +
-			"7. 其他值得注意的细节\n\n" +
-			"请用清晰、结构化的方式组织描述，确保信息准确完整。"
+		request.Prompt = DefaultPrompt
 	}
 
+	if config.APIKey != "" {
+		request.APIKey = config.APIKey
+	}
 	if request.APIKey == "" {
 		return &ImageAnalysisResponse{
 			Success: false,
@@ -132,9 +136,13 @@ func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (
 
 	// 构建图片内容（支持 base64）
 	imageURL := request.ImageBase64
-	if len(imageURL) > 0 && imageURL[:5] != "data:" {
-		// 如果不是 data: 开头，添加前缀
-		imageURL = "data:image/jpeg;base64," + imageURL
+	if !strings.HasPrefix(imageURL, "data:") {
+		// 如果不是 data: 开头，按文件头识别类型后添加前缀
+		mediaType := imageutil.DetectBase64MediaType(imageURL)
+		if mediaType == "" {
+			mediaType = imageutil.MediaTypeJPEG
+		}
+		imageURL = imageutil.DataURI(mediaType, imageURL)
 	}
 
 	// 构建消息（完全按照 MCP 的 createMultiModalMessage）
@@ -157,12 +165,14 @@ func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (
 	}
 
 	// 构建请求体（完全按照 MCP 的 visionCompletions）
+	thinking := &Thinking{Type: "enabled"}
+	if !config.Thinking {
+		thinking.Type = "disabled"
+	}
 	reqBody := ChatCompletionRequest{
-		Model:    config.Model,
-		Messages: messages,
-		Thinking: &Thinking{
-			Type: "enabled",
-		},
+		Model:       config.Model,
+		Messages:    messages,
+		Thinking:    thinking,
 		Stream:      false,
 		Temperature: config.Temperature,
 		TopP:        config.TopP,
@@ -179,8 +189,8 @@ func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (
 	}
 
 	// 创建 HTTP 请求
-	url := fmt.Sprintf("%s/chat/completions", config.BaseURL)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("%s/chat/completions", strings.TrimRight(config.BaseURL, "/"))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return &ImageAnalysisResponse{
 			Success: false,
@@ -248,5 +258,6 @@ func AnalyzeImageWithConfig(request ImageAnalysisRequest, config VisionConfig) (
 	return &ImageAnalysisResponse{
 		Success: true,
 		Data:    result,
+		Usage:   chatResp.Usage,
 	}, nil
 }
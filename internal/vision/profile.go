@@ -0,0 +1,113 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 识别提示词模板
+const (
+	ProfileGeneral = "general" // 全面描述（默认）
+	ProfileOCR     = "ocr"     // 逐字提取文字
+	ProfileUI      = "ui"      // 界面元素清单
+	ProfileDiagram = "diagram" // 架构图/流程图转 Mermaid
+	ProfileChart   = "chart"   // 图表转数据表格
+	ProfileCode    = "code"    // 代码/终端截图转源码
+	ProfileAuto    = "auto"    // 先用一次低成本调用分类，再选择对应模板
+)
+
+// profilePrompts 各模板的识别要求
+var profilePrompts = map[string]string{
+	ProfileGeneral: DefaultPrompt,
+	ProfileOCR: "请逐字提取图片中的所有文字：\n" +
+		"1. 保持原有的段落、换行、缩进和阅读顺序\n" +
+		"2. 表格使用 Markdown 表格输出\n" +
+		"3. 无法辨认的字符用 [?] 标记，不要猜测补全\n\n" +
+		"只输出提取的文字，不要描述图片的外观。",
+	ProfileUI: "这是一张软件界面截图，请列出界面元素清单：\n" +
+		"1. 界面类型（网页、桌面应用、移动应用、IDE 等）和整体布局（区域划分）\n" +
+		"2. 按从上到下、从左到右的顺序列出各元素：类型（按钮、输入框、菜单、标签页、列表、对话框等）、显示的文字、状态（选中、禁用、报错、加载中等）和所在区域\n" +
+		"3. 错误提示、弹窗、高亮等需要注意的内容，请逐字转写\n\n" +
+		"不需要描述配色和视觉风格。",
+	ProfileDiagram: "这是一张架构图、流程图或时序图，请转换为 Mermaid 代码：\n" +
+		"1. 识别所有节点，保留原始文字\n" +
+		"2. 识别连线的方向和连线上的标注\n" +
+		"3. 保留分组、子图和层次关系\n\n" +
+		"使用 ```mermaid 代码块输出，之后用一两句话补充 Mermaid 无法表达的信息（如颜色含义、图例）。",
+	ProfileChart: "这是一张统计图表，请：\n" +
+		"1. 说明图表类型、标题、坐标轴及单位、图例\n" +
+		"2. 将数据转换为 Markdown 表格，读取每个数据点的数值（估计值请用 ≈ 标注）\n" +
+		"3. 用一两句话概括趋势和关键结论",
+	ProfileCode: "这是一张代码、终端或日志截图，请逐字转写：\n" +
+		"1. 完整保留缩进、换行、符号和大小写，不要修改、补全或格式化代码\n" +
+		"2. 代码放入标注语言的代码块，终端命令和输出放入 ```text 代码块\n" +
+		"3. 看不清的字符用 [?] 标记\n" +
+		"4. 最后注明可见的附加信息，如文件名、行号、光标或报错标记的位置\n\n" +
+		"只输出转写内容和附加信息，不要描述界面的外观。",
+}
+
+// classifyPrompt 自动分类使用的提示词
+const classifyPrompt = "判断这张图片属于以下哪一类，只回答类别名称，不要输出其他内容：\n" +
+	"code：代码、终端、日志截图\n" +
+	"ui：软件界面、网页截图\n" +
+	"diagram：架构图、流程图、时序图\n" +
+	"chart：折线图、柱状图、饼图等统计图表\n" +
+	"ocr：文档、文章、表格等以文字为主的图片\n" +
+	"general：照片及其他图片"
+
+// classifyMaxTokens 自动分类的最大输出 token 数
+const classifyMaxTokens = 16
+
+// IsProfile 是否为支持的提示词模板名称（包括 auto）
+func IsProfile(name string) bool {
+	if name == ProfileAuto {
+		return true
+	}
+	_, ok := profilePrompts[name]
+	return ok
+}
+
+// ProfileNames 支持的提示词模板名称
+func ProfileNames() []string {
+	return []string{ProfileGeneral, ProfileOCR, ProfileUI, ProfileDiagram, ProfileChart, ProfileCode, ProfileAuto}
+}
+
+// ClassifyImage 用一次低成本的调用（关闭深度思考、限制输出长度）判断图片应使用的提示词模板
+// 无法判断时返回 ProfileGeneral
+func ClassifyImage(ctx context.Context, imageBase64 string, apiKey string, config VisionConfig) (string, error) {
+	if config.ClassifyModel != "" {
+		config.Model = config.ClassifyModel
+	}
+	config.Thinking = false
+	config.MaxTokens = classifyMaxTokens
+
+	result, err := AnalyzeImageWithConfig(ctx, ImageAnalysisRequest{
+		ImageBase64: imageBase64,
+		Prompt:      classifyPrompt,
+		APIKey:      apiKey,
+	}, config)
+	if err != nil {
+		return ProfileGeneral, err
+	}
+	if !result.Success {
+		return ProfileGeneral, fmt.Errorf("%s", result.Error)
+	}
+	return parseProfile(result.Data), nil
+}
+
+// parseProfile 从分类结果中解析模板名称：取回答的第一个词（忽略前后的标点和 Markdown 标记），
+// 与模板名称完全一致时返回该模板，否则返回 ProfileGeneral
+func parseProfile(answer string) string {
+	words := strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ProfileGeneral
+	}
+	if _, ok := profilePrompts[words[0]]; ok {
+		return words[0]
+	}
+	return ProfileGeneral
+}
@@ -0,0 +1,42 @@
+package vision
+
+import "testing"
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{answer: "code", want: ProfileCode},
+		{answer: "  UI\n", want: ProfileUI},
+		{answer: "**diagram**", want: ProfileDiagram},
+		{answer: "`chart`。", want: ProfileChart},
+		{answer: "ocr：文档截图", want: ProfileOCR},
+		{answer: "general", want: ProfileGeneral},
+		{answer: "\n\nocr\ncode", want: ProfileOCR},
+		{answer: "", want: ProfileGeneral},
+		{answer: "这是一张代码截图", want: ProfileGeneral},
+		{answer: "not code", want: ProfileGeneral},
+		{answer: "encode", want: ProfileGeneral},
+		{answer: "guide", want: ProfileGeneral},
+		{answer: "auto", want: ProfileGeneral},
+	}
+	for _, tt := range tests {
+		if got := parseProfile(tt.answer); got != tt.want {
+			t.Errorf("parseProfile(%q) = %q，期望 %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestIsProfile(t *testing.T) {
+	for _, name := range ProfileNames() {
+		if !IsProfile(name) {
+			t.Errorf("IsProfile(%q) = false，期望 true", name)
+		}
+	}
+	for _, name := range []string{"", "OCR", " ocr", "photo", "code,ui"} {
+		if IsProfile(name) {
+			t.Errorf("IsProfile(%q) = true，期望 false", name)
+		}
+	}
+}
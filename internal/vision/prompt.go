@@ -0,0 +1,54 @@
+package vision
+
+import "strings"
+
+// DefaultPrompt 默认的全面描述提示词
+const DefaultPrompt = "请详细全面地描述这张图片的内容，包括但不限于：\n" +
+	"1. 整体场景和环境（室内/室外、时间、天气等）\n" +
+	"2. 主要物体和人物（位置、大小、特征、动作、表情等）\n" +
+	"3. 颜色搭配和光影效果\n" +
+	"4. 构图和布局（前景、中景、背景）\n" +
+	"5. 文字内容（如果图片中包含文字，请完整识别并提取）\n" +
+	"6. 整体氛围、情绪和风格\n" +
+	"7. 其他值得注意的细节\n\n" +
+	"请用清晰、结构化的方式组织描述，确保信息准确完整。"
+
+// BuildPrompt 根据提示词模板和用户随图片发送的文字（以及可选的系统提示词）构建识别提示词
+// 上下文都为空时直接返回模板提示词；未知模板按 ProfileGeneral 处理
+func BuildPrompt(profile string, userContext string, systemContext string) string {
+	instructions, ok := profilePrompts[profile]
+	if !ok {
+		profile, instructions = ProfileGeneral, DefaultPrompt
+	}
+
+	userContext = strings.TrimSpace(userContext)
+	systemContext = strings.TrimSpace(systemContext)
+	if userContext == "" && systemContext == "" {
+		return instructions
+	}
+
+	var b strings.Builder
+	b.WriteString("用户在对话中发送了这张图片。你的识别结果会替代图片交给另一个只能阅读文字的模型，由它来回答用户。\n\n")
+	if systemContext != "" {
+		b.WriteString("对话的系统设定（仅供理解场景）：\n<<<\n")
+		b.WriteString(systemContext)
+		b.WriteString("\n>>>\n\n")
+	}
+	if userContext != "" {
+		b.WriteString("用户随图片发送的文字：\n<<<\n")
+		b.WriteString(userContext)
+		b.WriteString("\n>>>\n\n")
+	}
+	if profile == ProfileGeneral {
+		b.WriteString("请结合上述上下文识别图片：\n" +
+			"1. 完整、准确地提取与上下文相关的文字内容；代码、日志、报错信息、堆栈等请逐字转写并保留格式\n" +
+			"2. 重点描述与用户问题相关的区域和细节\n" +
+			"3. 简要说明图片的整体内容和布局\n\n" +
+			"不要回答用户的问题，只客观地描述图片内容。")
+	} else {
+		b.WriteString("请结合上述上下文识别图片，要求如下：\n")
+		b.WriteString(instructions)
+		b.WriteString("\n\n不要回答用户的问题。")
+	}
+	return b.String()
+}